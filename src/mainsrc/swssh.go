@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	nwnet "nwnet"
 	"nwssh"
 	"os"
 	"strings"
@@ -175,7 +176,7 @@ var VersionInfoVendorKeys map[string]string = map[string]string{
 }
 
 func guessVendorByVesionInfo(versioninfo string) string {
-	for k, v := range WelecomInfoVendorKeys {
+	for k, v := range VersionInfoVendorKeys {
 		if strings.Contains(versioninfo, v) {
 			return k
 		}
@@ -506,21 +507,27 @@ func main() {
 			os.Exit(0)
 		}
 		for _, f := range fileinfo {
-			host := f.Name()
-
-			if !f.IsDir() && len(strings.Split(host, ".")) == 4 {
-				cmds, err = readlines(args.conffiledir + host)
-				if err != nil {
-					fmt.Println("Fialed to read command from configuration file.")
-				}
-				wait.Add(1)
-				go func(host string, cmds []string) {
-					threadchan <- struct{}{}
-					run(host, args.port, sshoptions, cmds, &args, basiscmd)
-					<-threadchan
-					wait.Done()
-				}(host, cmds)
+			name := f.Name()
+			if f.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+				continue
+			}
+			host, err := nwnet.ParseHost(name)
+			if err != nil {
+				log.Printf("Skip file '%s' in confpath. %v\n", name, err)
+				continue
+			}
+
+			cmds, err = readlines(args.conffiledir + name)
+			if err != nil {
+				fmt.Println("Fialed to read command from configuration file.")
 			}
+			wait.Add(1)
+			go func(host string, cmds []string) {
+				threadchan <- struct{}{}
+				run(host, args.port, sshoptions, cmds, &args, basiscmd)
+				<-threadchan
+				wait.Done()
+			}(host, cmds)
 		}
 	}
 
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	nwnet "nwnet"
+	"os"
+	"strings"
+	"text/template"
+)
+
+/*
+A command template is a text/template file, the commands of each vendor are
+defined in a block named by the lowercase vendor, the top level of the file is
+used for the vendors which have no block defined. For example:
+
+	{{define "h3c"}}
+	vlan {{.vlan}}
+	 name {{.hostname}}-{{.vlan}}
+	{{end}}
+	{{define "cisco"}}
+	vlan {{.vlan}}
+	 name {{.hostname}}-{{.vlan}}
+	{{end}}
+
+Variables are read from the host vars file and the csv file, '.host' and
+'.vendor' are always defined, '.hostname' is the same as '.host' if not given.
+*/
+
+var cmdtemplate *template.Template
+
+// hostvars holds the template variables of each host, it's filled before any
+// execution started and read only after that.
+var hostvars = map[string]map[string]string{}
+
+func loadTemplate(filename string) (*template.Template, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(filename).Option("missingkey=error").Parse(string(content))
+}
+
+// loadHostVars reads variables from a csv file, the first line is the title
+// line which names the variables, and the first column must be 'host'.
+func loadHostVars(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	title := records[0]
+	if strings.TrimSpace(title[0]) != "host" {
+		return fmt.Errorf("The first column of vars file '%s' must be 'host'.", filename)
+	}
+
+	for _, record := range records[1:] {
+		host, err := nwnet.ParseHost(record[0])
+		if err != nil {
+			return fmt.Errorf("Invalid host in vars file '%s'. %v", filename, err)
+		}
+		for i := 1; i < len(record) && i < len(title); i++ {
+			setHostVar(host, strings.TrimSpace(title[i]), strings.TrimSpace(record[i]))
+		}
+	}
+	return nil
+}
+
+func setHostVar(host, name, value string) {
+	if hostvars[host] == nil {
+		hostvars[host] = map[string]string{}
+	}
+	hostvars[host][name] = value
+}
+
+// renderCommands renders the commands of vendor for host, one command per
+// line, the empty lines are dropped.
+func renderCommands(tmpl *template.Template, host, vendor string) ([]string, error) {
+	data := map[string]string{
+		"host":     host,
+		"hostname": host,
+		"vendor":   vendor,
+	}
+	for k, v := range hostvars[host] {
+		data[k] = v
+	}
+
+	if t := tmpl.Lookup(strings.ToLower(vendor)); t != nil {
+		tmpl = t
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	var cmds []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			cmds = append(cmds, strings.TrimRight(line, " \t"))
+		}
+	}
+	return cmds, nil
+}
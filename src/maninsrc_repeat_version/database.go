@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nwswdb"
+	"os"
+	"runner"
+	"strings"
+	"time"
+)
+
+/*
+Database mode collects the models of hosts instead of executing commands,
+including the hardware, the interfaces, BGP and OSPF, and saves them to the
+BoltDB file given by -db as a new version of each host, printed like:
+
+	--------10.10.10.10-------
+	saved:   2026-10-19 08:00:00
+	digest:  5f0c...
+
+The versions are kept, and queried by:
+
+	-dbshow <host>[@<time>]  print the models of the host at the time as
+	                         JSON, the last ones if the time is omitted.
+	-dbchanged <time>        print the hosts changed since the time.
+
+The time is like '2026-10-18', '2026-10-18 15:04' or a duration before now like
+'24h'. A date is the end of the day for -dbshow and the start of the day for
+-dbchanged, so '-dbshow 10.10.10.10@2026-10-18' is the host on that day.
+*/
+
+var store *nwswdb.Store
+
+func checkDBArgs(args *Args) error {
+	if (args.dbshow != "" || args.dbchanged != "") && args.db == "" {
+		return fmt.Errorf("Database file is expected by 'dbshow' and 'dbchanged'.")
+	}
+	if args.db == "" || args.dbshow != "" || args.dbchanged != "" {
+		return nil
+	}
+	conflicts := []struct {
+		name string
+		set  bool
+	}{
+		{"cmd", args.cmd != ""},
+		{"cmdfile", args.cmdfile != ""},
+		{"cmd_prefix", args.cmdprefix != ""},
+		{"confpath", args.conffiledir != ""},
+		{"template", args.template != ""},
+		{"tran", args.transcation != ""},
+		{"facts", args.facts != ""},
+		{"repeat", args.repeat},
+		{"rollback", args.rollback != ""},
+		{"snapshot", args.snapshot != ""},
+		{"save", args.saveconfig},
+	}
+	for _, c := range conflicts {
+		if c.set {
+			return fmt.Errorf("Database can't be used with '%s'.", c.name)
+		}
+	}
+	return nil
+}
+
+// openStore opens the database, and runs the query and exits if there's one.
+func openStore(args *Args) {
+	if args.db == "" || (args.dryrun && args.dbshow == "" && args.dbchanged == "") {
+		return
+	}
+	var err error
+	if store, err = nwswdb.OpenStore(args.db); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	switch {
+	case args.dbshow != "":
+		err = showSnapshot(args.dbshow)
+	case args.dbchanged != "":
+		err = showChanged(args.dbchanged)
+	default:
+		return
+	}
+	store.Close()
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	os.Exit(0)
+}
+
+func closeStore() {
+	if store == nil {
+		return
+	}
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close database. Error: %v\n", err)
+	}
+}
+
+// parseTime returns the time like '2026-10-18 15:04' or '24h' before now,
+// end tells whether a date is the end or the start of the day.
+func parseTime(s string, end bool) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if end {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid time '%s'.", s)
+}
+
+// showSnapshot prints the snapshot of 'host[@time]'.
+func showSnapshot(query string) error {
+	host, at, found := strings.Cut(query, "@")
+	t := time.Now()
+	if found {
+		var err error
+		if t, err = parseTime(at, true); err != nil {
+			return err
+		}
+	}
+	snapshot, err := store.At(host, t)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return fmt.Errorf("No snapshot of '%s' at %s.", host, t.Format("2006-01-02 15:04:05"))
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// showChanged prints the hosts changed since the time.
+func showChanged(since string) error {
+	t, err := parseTime(since, false)
+	if err != nil {
+		return err
+	}
+	hosts, err := store.ChangedSince(t)
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		fmt.Println(host)
+	}
+	return nil
+}
+
+// collectModels logins to t, collects its models and saves them to the
+// database.
+func collectModels(t *runner.Target, ticket int) {
+	var block string
+	defer func() { out.Write(ticket, block) }()
+
+	host := t.Host
+	result := &runner.HostReport{Host: host, Vendor: t.Vendor, Status: runner.StatusFailed}
+	defer report.Add(result)
+
+	dev, err := runner.Connect(t)
+	defer dev.Close()
+	result.Credential = dev.Credential()
+	result.Vendor = dev.Vendor
+	if err != nil {
+		log.Printf("[%s]%v\n", host, err)
+		result.Error = err.Error()
+		return
+	}
+
+	if !dev.Driver.SessionPreparation() {
+		log.Printf("[%s]Failed to init execute envirment. Try to execute command directly.\n", host)
+	}
+	snapshot, err := nwswdb.CollectSnapshot(dev.Driver, host, parseindex)
+	if err != nil {
+		log.Printf("[%s]Failed to collect models. Error: %v\n", host, err)
+		result.Error = fmt.Sprintf("Failed to collect models. %v", err)
+		return
+	}
+	if err = store.Save(snapshot); err != nil {
+		log.Printf("[%s]Failed to save models. Error: %v\n", host, err)
+		result.Error = fmt.Sprintf("Failed to save models. %v", err)
+		return
+	}
+	block = runner.Block(host, fmt.Sprintf("saved:   %s\ndigest:  %s", snapshot.Time.Format("2006-01-02 15:04:05"), snapshot.Digest))
+	result.Status = runner.StatusOK
+	log.Printf("[%s]Execution completed!\n", host)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"nwssh"
+	"path/filepath"
+	"runner"
+	"strings"
+)
+
+/*
+Facts mode collects the facts of hosts instead of executing commands, the
+facts of each host are printed like:
+
+	--------10.10.10.10-------
+	hostname:    BJ_YF_305-A-15_S6850
+	vendor:      H3C
+	model:       S6850-56HF
+	os version:  7.1.070, Release 6616P02
+	patch:       6616P02H01
+	serials:     Slot 1:210235A2CSH19C000012
+	uptime:      1 weeks, 2 days, 3 hours, 4 minutes
+	mgmt ip:     10.10.10.10
+
+and the fleet inventory is written to the file given by -facts, it's JSON if
+the file ends with '.json', otherwise CSV.
+*/
+
+var factsreport = &runner.FactsReport{}
+
+func checkFactsArgs(args *Args) error {
+	if args.facts == "" {
+		return nil
+	}
+	conflicts := []struct {
+		name string
+		set  bool
+	}{
+		{"cmd", args.cmd != ""},
+		{"cmdfile", args.cmdfile != ""},
+		{"cmd_prefix", args.cmdprefix != ""},
+		{"confpath", args.conffiledir != ""},
+		{"template", args.template != ""},
+		{"tran", args.transcation != ""},
+		{"repeat", args.repeat},
+		{"rollback", args.rollback != ""},
+		{"snapshot", args.snapshot != ""},
+		{"save", args.saveconfig},
+	}
+	for _, c := range conflicts {
+		if c.set {
+			return fmt.Errorf("Facts can't be used with '%s'.", c.name)
+		}
+	}
+	return nil
+}
+
+// collectFacts logins to t and collects its facts, ticket is also the order
+// of the host in the facts file.
+func collectFacts(t *runner.Target, ticket int) {
+	var block string
+	defer func() { out.Write(ticket, block) }()
+
+	host := t.Host
+	result := &runner.HostReport{Host: host, Vendor: t.Vendor, Status: runner.StatusFailed}
+	defer report.Add(result)
+
+	dev, err := runner.Connect(t)
+	defer dev.Close()
+	result.Credential = dev.Credential()
+	result.Vendor = dev.Vendor
+	if err != nil {
+		log.Printf("[%s]%v\n", host, err)
+		result.Error = err.Error()
+		return
+	}
+
+	if !dev.Driver.SessionPreparation() {
+		log.Printf("[%s]Failed to init execute envirment. Try to execute command directly.\n", host)
+	}
+	facts, err := dev.Driver.GetFacts()
+	if err != nil {
+		log.Printf("[%s]%v\n", host, err)
+		result.Error = err.Error()
+		return
+	}
+	factsreport.Add(ticket, &facts)
+	block = runner.Block(host, formatFacts(&facts))
+	result.Status = runner.StatusOK
+	log.Printf("[%s]Execution completed!\n", host)
+}
+
+func formatFacts(f *nwssh.Facts) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "hostname:    %s\n", f.Hostname)
+	fmt.Fprintf(&b, "vendor:      %s\n", f.Vendor)
+	fmt.Fprintf(&b, "model:       %s\n", f.Model)
+	fmt.Fprintf(&b, "os version:  %s\n", f.OSVersion)
+	fmt.Fprintf(&b, "patch:       %s\n", f.Patch)
+	fmt.Fprintf(&b, "serials:     %s\n", f.SerialString())
+	fmt.Fprintf(&b, "uptime:      %s\n", f.Uptime)
+	fmt.Fprintf(&b, "mgmt ip:     %s", f.MgmtIP)
+	return b.String()
+}
+
+func writeFacts(args *Args) {
+	if args.facts == "" || args.dryrun {
+		return
+	}
+	var err error
+	if strings.EqualFold(filepath.Ext(args.facts), ".json") {
+		err = factsreport.WriteJSON(args.facts)
+	} else {
+		err = factsreport.WriteCSV(args.facts)
+	}
+	if err != nil {
+		log.Printf("Failed to write facts '%s'. Error: %v\n", args.facts, err)
+	}
+}
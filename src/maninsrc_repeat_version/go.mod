@@ -2,13 +2,29 @@ module main
 
 go 1.19
 
-require nwssh v0.0.0
+require (
+	nwnet v0.0.0
+	nwparse v0.0.0
+	nwssh v0.0.0
+	nwswdb v0.0.0
+	runner v0.0.0
+)
 
 require (
+	go.etcd.io/bbolt v1.3.7 // indirect
 	golang.org/x/crypto v0.5.0 // indirect
-	golang.org/x/sys v0.4.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/term v0.10.0 // indirect
 )
 
 //require "golang.org/x/crypto/ssh"  v0.5.0
 
 replace nwssh v0.0.0 => ../nwssh
+
+replace nwnet v0.0.0 => ../nwnet
+
+replace nwparse v0.0.0 => ../nwparse
+
+replace nwswdb v0.0.0 => ../nwswdb
+
+replace runner v0.0.0 => ../runner
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"nwparse"
+	"nwssh"
+)
+
+/*
+Parse mode prints the outputs of commands as JSON lines instead of text, one
+line per command of each host, the records are parsed by the TextFSM
+template of the command and the vendor:
+
+	{"host":"10.10.10.10","vendor":"H3C","command":"display version","records":[{"MODEL":"S6850-56HF",...}]}
+
+If no template matches the command, the text is kept in "output". The
+templates in -templates take precedence over the builtin ones of nwparse.
+The same templates are used to collect the models in database mode.
+*/
+
+var parseindex *nwparse.Index
+
+type parsedOutput struct {
+	Host    string                   `json:"host"`
+	Vendor  string                   `json:"vendor"`
+	Command string                   `json:"command"`
+	Records []map[string]interface{} `json:"records,omitempty"`
+	Output  string                   `json:"output,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+func loadParseIndex(args *Args) {
+	if !args.parse && args.db == "" {
+		return
+	}
+	builtin, err := nwparse.Builtin()
+	if err != nil {
+		log.Fatalf("Failed to load builtin templates. Error: %v\n", err)
+	}
+	parseindex = &nwparse.Index{}
+	parseindex.Add(builtin)
+	if args.templates != "" {
+		templates, err := nwparse.LoadIndexDir(args.templates)
+		if err != nil {
+			log.Fatalf("Failed to load templates '%s'. Error: %v\n", args.templates, err)
+		}
+		parseindex.Add(templates)
+	}
+}
+
+// parseOutput returns the JSON line of the output of cmd.
+func parseOutput(host, vendor, cmd, output string) string {
+	parsed := parsedOutput{Host: host, Vendor: vendor, Command: cmd}
+	result, err := parseindex.Parse(vendor, cmd, output)
+	switch {
+	case err == nwparse.ErrNoTemplate:
+		parsed.Output = output
+	case err != nil:
+		log.Printf("[%s]Failed to parse the output of '%s'. Error: %v\n", host, cmd, err)
+		parsed.Output, parsed.Error = output, err.Error()
+	default:
+		parsed.Records = result.Maps()
+	}
+	line, _ := json.Marshal(parsed)
+	return string(line) + "\n"
+}
+
+// commandOutput formats the output of cmd by -parse or -pretty.
+func commandOutput(device nwssh.SSHBASE, host, vendor, cmd, o string, args *Args) string {
+	switch {
+	case args.parse:
+		return parseOutput(host, vendor, cmd, device.Sanitize(cmd, o))
+	case args.prettyoutput:
+		return device.Sanitize(cmd, o) + "\n"
+	}
+	return o
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"nwssh"
+	"runner"
+	"strings"
+)
+
+/*
+Plan is printed instead of executing when -dryrun is given, for each host it
+shows the vendor, where the commands come from, the rendered commands and
+whether the configuration is saved, for example:
+
+	--------10.10.10.10-------
+	vendor:      H3C (declared)
+	port:        22
+	commands:    cmd_prefix ./swcmd/bond4.cmd.h3c
+	    interface Bridge-Aggregation 4
+	    link-aggregation mode dynamic
+	save:        yes
+
+No host is connected unless -detect is given, then the hosts without vendor
+are connected to detect the vendor only, no command is sent except the
+version command when the login messages are not enough to tell the vendor.
+The plans are always printed in the order of hosts.
+*/
+
+// commandSource describes where the commands of j come from for vendor.
+func commandSource(j *job, vendor string, args *Args) string {
+	switch {
+	case cmdtemplate != nil:
+		return "template " + args.template
+	case j.file != "":
+		return "confpath " + j.file
+	case len(j.cmds) > 0 && args.cmd != "":
+		return "cmd"
+	case len(j.cmds) > 0:
+		return "cmdfile " + args.cmdfile
+	case args.cmdprefix != "" && vendor == "":
+		return "cmd_prefix " + args.cmdprefix + ".cmd.<vendor>"
+	case args.cmdprefix != "":
+		file := args.cmdprefix + ".cmd." + strings.ToLower(vendor)
+		if j.basiscmd[vendor] == nil {
+			file += " (not found)"
+		}
+		return "cmd_prefix " + file
+	}
+	return "none"
+}
+
+// detectVendor connects t to detect its vendor, the enable password is not
+// used.
+func detectVendor(t *runner.Target) (*nwssh.Detection, error) {
+	target := *t
+	target.Enable = ""
+
+	result := &runner.HostReport{Host: t.Host, Status: runner.StatusFailed}
+	defer report.Add(result)
+
+	dev, err := runner.Connect(&target)
+	defer dev.Close()
+	result.Vendor = dev.Vendor
+	result.Credential = dev.Credential()
+	if err != nil {
+		result.Error = err.Error()
+		return nil, err
+	}
+	result.Status = runner.StatusOK
+	return dev.Detection, nil
+}
+
+// plan prints the plan of j, wave is the description of the wave of j in
+// rolling mode.
+func plan(j *job, wave string, args *Args) {
+	t := j.target
+	var b strings.Builder
+	defer func() { out.Write(j.ticket, runner.Block(t.Host, strings.TrimRight(b.String(), "\n"))) }()
+
+	vendor := t.Vendor
+	switch {
+	case vendor != "":
+		fmt.Fprintf(&b, "vendor:      %s (declared)\n", vendor)
+	case !args.detect:
+		fmt.Fprintf(&b, "vendor:      unknown (not detected without -detect)\n")
+	default:
+		d, err := detectVendor(t)
+		if err != nil {
+			log.Printf("[%s]%v\n", t.Host, err)
+			fmt.Fprintf(&b, "vendor:      unknown (detection failed: %v)\n", err)
+			break
+		}
+		vendor = d.Vendor
+		from := "detected"
+		if d.Cached {
+			from = "cached"
+		}
+		fmt.Fprintf(&b, "vendor:      %s (%s by %s, confidence %.2f)\n", vendor, from, d.Stage, d.Confidence)
+		if d.Model != "" || d.OS != "" {
+			fmt.Fprintf(&b, "model:       %s\n", d.Model)
+			fmt.Fprintf(&b, "os:          %s\n", d.OS)
+		}
+	}
+	fmt.Fprintf(&b, "port:        %s\n", t.Port)
+	if t.Options.JumpHost != "" {
+		fmt.Fprintf(&b, "jumphost:    %s\n", t.Options.JumpHost)
+	}
+
+	fmt.Fprintf(&b, "commands:    %s\n", commandSource(j, vendor, args))
+	if vendor == "" && (cmdtemplate != nil || len(j.cmds) == 0) {
+		fmt.Fprintf(&b, "    (depends on the vendor)\n")
+	} else if cmds, err := resolveCommands(t.Host, vendor, j.cmds, j.basiscmd); err != nil {
+		log.Printf("[%s]Failed to render commands. Error: %v\n", t.Host, err)
+		fmt.Fprintf(&b, "    (failed to render: %v)\n", err)
+	} else {
+		for _, cmd := range cmds {
+			fmt.Fprintf(&b, "    %s\n", cmd)
+		}
+	}
+
+	if args.transcation != "" {
+		fmt.Fprintf(&b, "transcation: %s\n", args.transcation)
+	}
+	if args.saveconfig {
+		fmt.Fprintf(&b, "save:        yes\n")
+	} else {
+		fmt.Fprintf(&b, "save:        no\n")
+	}
+	if args.rollback != "" {
+		fmt.Fprintf(&b, "rollback:    %s\n", args.rollback)
+	}
+	if args.snapshot != "" {
+		fmt.Fprintf(&b, "snapshot:    %s\n", args.snapshot)
+	}
+	if wave != "" {
+		fmt.Fprintf(&b, "wave:        %s\n", wave)
+	}
+}
+
+// planJobs prints the plan of all jobs and a summary of them.
+func planJobs(args *Args) {
+	waves := make([]string, len(jobs))
+	var sizes []string
+	if rollout.Enabled() {
+		ends := rollout.Waves(len(jobs))
+		start := 0
+		for i, end := range ends {
+			for k := start; k < end; k++ {
+				waves[k] = fmt.Sprintf("%d/%d", i+1, len(ends))
+				if i == 0 && rollout.Canary.N > 0 {
+					waves[k] += " (canary)"
+				}
+			}
+			sizes = append(sizes, fmt.Sprint(end-start))
+			start = end
+		}
+	}
+
+	for i, j := range jobs {
+		j, wave := j, waves[i]
+		pool.Go(j.target.Vars, func() {
+			plan(j, wave, args)
+		})
+	}
+	pool.Wait()
+
+	summary := fmt.Sprintf("%d hosts planned, no command is executed.", len(jobs))
+	if len(sizes) > 0 {
+		summary += fmt.Sprintf(" Waves: %s.", strings.Join(sizes, ", "))
+	}
+	out.Print(summary + "\n")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"nwssh"
+	"os"
+	"path/filepath"
+	"runner"
+	"time"
+)
+
+/*
+Rollback restores the configuration of a host when any of its commands
+failed, the configuration is not saved after rolling back. The modes are:
+
+	checkpoint  roll back to the checkpoint created before execution, it's
+	            supported by Nexus (checkpoint), Cisco (configure replace),
+	            H3C (configuration replace) and Huawei (rollback
+	            configuration).
+	undo        replay the commands of the undo file, or <dir>/<host> if the
+	            undo file is a directory like confpath.
+	auto        checkpoint if the vendor supports it, otherwise undo.
+
+The checkpoint is deleted after execution.
+*/
+
+const (
+	rollbackAuto       = "auto"
+	rollbackCheckpoint = "checkpoint"
+	rollbackUndo       = "undo"
+)
+
+// rollbackPlan is how a host is rolled back, checkpoint is the name of the
+// checkpoint, it's empty if the undo commands are used.
+type rollbackPlan struct {
+	checkpoint string
+	undo       []string
+}
+
+func checkRollbackArgs(args *Args) error {
+	switch args.rollback {
+	case "", rollbackAuto, rollbackCheckpoint:
+	case rollbackUndo:
+		if args.undofile == "" {
+			return fmt.Errorf("Undo file is expected by rollback mode 'undo' but got none.")
+		}
+	default:
+		return fmt.Errorf("Unsupported rollback mode '%s'.", args.rollback)
+	}
+	return nil
+}
+
+// undoCommands reads the undo commands of host.
+func undoCommands(host string, args *Args) ([]string, error) {
+	filename := args.undofile
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		filename = filepath.Join(filename, host)
+	}
+	return runner.ReadLines(filename)
+}
+
+// prepareRollback creates the checkpoint or reads the undo commands before
+// execution, the host must not be executed if an error is returned.
+func prepareRollback(device nwssh.SSHBASE, host, vendor string, args *Args) (*rollbackPlan, error) {
+	checkpointer, ok := device.(nwssh.Checkpointer)
+	if args.rollback == rollbackCheckpoint && !ok {
+		return nil, fmt.Errorf("Checkpoint is not supported by vendor '%s'.", vendor)
+	}
+
+	if ok && args.rollback != rollbackUndo {
+		name := "swssh_" + time.Now().Format("20060102150405")
+		if err := checkpointer.Checkpoint(name); err != nil {
+			return nil, fmt.Errorf("Failed to create checkpoint. %v", err)
+		}
+		return &rollbackPlan{checkpoint: name}, nil
+	}
+
+	if args.undofile == "" {
+		return nil, fmt.Errorf("Checkpoint is not supported by vendor '%s', undo file is expected.", vendor)
+	}
+	undo, err := undoCommands(host, args)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read undo commands. %v", err)
+	}
+	return &rollbackPlan{undo: undo}, nil
+}
+
+// rollback restores the configuration by plan.
+func rollback(device nwssh.SSHBASE, host string, plan *rollbackPlan, args *Args) error {
+	if plan.checkpoint != "" {
+		if err := device.(nwssh.Checkpointer).Rollback(plan.checkpoint); err != nil {
+			return err
+		}
+		log.Printf("[%s]Rolled back to checkpoint '%s'.\n", host, plan.checkpoint)
+		return nil
+	}
+
+	var failed int
+	for _, cmd := range plan.undo {
+		_, err := device.ExecCommandExpectPrompt(cmd, time.Second*time.Duration(args.cmdtimeout))
+		if err != nil {
+			log.Printf("[%s]Failed to exec undo cmd '%s'. Error: %v\n", host, cmd, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d undo commands failed.", failed, len(plan.undo))
+	}
+	log.Printf("[%s]Rolled back by %d undo commands.\n", host, len(plan.undo))
+	return nil
+}
+
+// dropCheckpoint deletes the checkpoint of plan if any.
+func dropCheckpoint(device nwssh.SSHBASE, host string, plan *rollbackPlan) {
+	if plan == nil || plan.checkpoint == "" {
+		return
+	}
+	if err := device.(nwssh.Checkpointer).DeleteCheckpoint(plan.checkpoint); err != nil {
+		log.Printf("[%s]Failed to delete checkpoint '%s'. Error: %v\n", host, plan.checkpoint, err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"nwssh"
+	"path/filepath"
+	"regexp"
+	"runner"
+	"strings"
+)
+
+/*
+Snapshots of the running configuration are taken before and after the
+execution when -snapshot is given, both are saved in the snapshot directory
+with the unified diff between them:
+
+	<host>.pre   <host>.post   <host>.diff
+
+The lines changed every time the configuration is shown, such as the time of
+Nexus and the byte count of IOS, are dropped before diff.
+*/
+
+var volatileConfigLine = regexp.MustCompile(`^(!Time:|!Running configuration last done at|! Last configuration change at|! NVRAM config last updated at|Building configuration|Current configuration :)`)
+
+func takeSnapshot(device nwssh.SSHBASE) (string, error) {
+	config, err := device.RunningConfig()
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(config, "\r", ""), "\n") {
+		if !volatileConfigLine.MatchString(line) {
+			lines = append(lines, strings.TrimRight(line, " "))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n", nil
+}
+
+// saveSnapshot saves the snapshots of host and the diff between them into
+// dir, it reports whether the configuration changed.
+func saveSnapshot(dir, host, pre, post string) (bool, error) {
+	name := filepath.Join(dir, runner.LogFileName(host))
+	if err := runner.WriteFile(name+".pre", pre); err != nil {
+		return false, err
+	}
+	if err := runner.WriteFile(name+".post", post); err != nil {
+		return false, err
+	}
+	diff := runner.UnifiedDiff(runner.SplitLines(pre), runner.SplitLines(post), name+".pre", name+".post", 3)
+	if err := runner.WriteFile(name+".diff", diff); err != nil {
+		return false, err
+	}
+	return diff != "", nil
+}
+
+// snapshotAfter takes the snapshot after execution and saves it with pre. The
+// commands may leave the session in the config mode, where IOS and Ruijie
+// reject 'show running-config', so it returns to the user view first.
+func snapshotAfter(device nwssh.SSHBASE, host, pre string, args *Args) error {
+	if err := device.UserView(); err != nil {
+		return err
+	}
+	post, err := takeSnapshot(device)
+	if err != nil {
+		return err
+	}
+	changed, err := saveSnapshot(args.snapshot, host, pre, post)
+	if err != nil {
+		return err
+	}
+	if changed {
+		log.Printf("[%s]Configuration changed, see '%s'.\n", host, filepath.Join(args.snapshot, runner.LogFileName(host)+".diff"))
+	} else {
+		log.Printf("[%s]No configuration changed.\n", host)
+	}
+	return nil
+}
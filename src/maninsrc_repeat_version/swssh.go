@@ -1,15 +1,17 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	nwnet "nwnet"
 	"nwssh"
+	_ "nwswdb" // The transactions of nwswdb, such as 'bgpneighbors' and 'ospf'.
 	"os"
+	"path/filepath"
+	"runner"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -21,6 +23,7 @@ type Args struct {
 	swvendor       string
 	username       string
 	password       string
+	enable         string
 	port           string
 	saveconfig     bool
 	strictmode     bool
@@ -31,24 +34,79 @@ type Args struct {
 	repeatinterval int
 	repeatduration int
 	logdir         string
+	snapshot       string
+	rollback       string
+	undofile       string
+	errpattern     string
+	onerror        string
 	conffiledir    string
 	cmdfile        string
 	csvfile        string
+	inventory      string
+	limit          string
+	template       string
+	varsfile       string
 	transcation    string
 	privatekey     string
+	credential     string
+	credsource     string
+	report         string
+	netrc          string
+	vault          string
+	vaultadd       string
+	credcmd        string
 	prettyoutput   bool
+	parse          bool
+	templates      string
 	help           bool
 	nopage         bool
+	ordered        bool
 	repeat         bool
+	dryrun         bool
+	detect         bool
+	detectcache    string
+	detectttl      int
+	facts          string
+	db             string
+	dbshow         string
+	dbchanged      string
+	concurrency    int
+	rate           float64
+	groupcap       string
+	canary         string
+	wave           string
+	maxfail        string
+	confirm        bool
 }
 
 var args = Args{}
 
+// login reports whether the hosts are logged in, in dryrun they are logged in
+// only to detect the vendor.
+func (a *Args) login() bool {
+	return !a.dryrun || a.detect
+}
+
+var credentials *runner.CredentialChain
+
+var pool *runner.Pool
+
+var report = &runner.Report{}
+
+// out prints the outputs of hosts and the log lines without mixing them.
+var out *runner.Output
+
+var rollout = &runner.Rollout{}
+
+// detectcache keeps the detected vendors of hosts, it's nil if not enabled.
+var detectcache *nwssh.DetectCache
+
 func initflag() {
 	flag.StringVar(&args.hostfile, "f", "", `Read list of targets from a file, for example:
 '10.10.10.10'
 '12.12.12.12'.`)
-	flag.StringVar(&args.host, "host", "", `Single target address or multiple targets separated by ';'.`)
+	flag.StringVar(&args.host, "host", "", `Single target address or multiple targets separated by ';'. The 
+target can be an IPv4 or IPv6 address, a hostname or a FQDN.`)
 	flag.StringVar(&args.cmdprefix, "cmd_prefix", "", `A prefix of command list file. 
 For example:
 	test.cmd.cisco
@@ -62,7 +120,25 @@ separated by ';'.`)
 	flag.StringVar(&args.swvendor, "V", "", `Vendor of target host, if not spicified, it will be detected 
 automatically.`)
 	flag.StringVar(&args.username, "u", "", "Username for login.")
-	flag.StringVar(&args.password, "p", "", "Password for login.")
+	flag.StringVar(&args.password, "p", "", `Password for login. Not recommanded, the password is visible in the 
+process list and shell history, use the credential sources instead.`)
+	flag.StringVar(&args.credsource, "credsource", "env,netrc,vault,command,prompt", `Credential sources to look up in order, netrc, vault and command 
+are used only if '-netrc', '-vault' and '-credcmd' is given. Hosts 
+reference credentials by the inventory variable 'credential', or use 
+the credential 'default'.`)
+	flag.StringVar(&args.credential, "credential", "", `Credentials tried in order until one is accepted, separated by ',', 
+for example 'tacacs,legacy2019'. It's used by the hosts which have no 
+'credential' variable in inventory, the variable is also a list.`)
+	flag.StringVar(&args.report, "report", "", `Write the result of each host to a csv file, including the vendor, 
+the credential accepted, the status and the error.`)
+	flag.StringVar(&args.netrc, "netrc", "", `Read credentials from a netrc style file, 'machine <credential> 
+login <username> password <password>'.`)
+	flag.StringVar(&args.vault, "vault", "", `Read credentials from an encrypted vault file, the passphrase is 
+read from SWSSH_VAULT_PASSPHRASE or terminal.`)
+	flag.StringVar(&args.vaultadd, "vaultadd", "", `Add or update a credential in the vault given by '-vault', username 
+and password are read from terminal.`)
+	flag.StringVar(&args.credcmd, "credcmd", "", `Command to get credentials, run by 'sh -c' with credential name as $1,
+it prints 'username=xxx' and 'password=xxx' lines, or password only.`)
 	flag.StringVar(&args.port, "port", "22", "Port to connect to on the remote host.")
 	flag.BoolVar(&args.saveconfig, "save", false, "Automatically save running-config after execution completed.")
 	flag.BoolVar(&args.strictmode, "strict", false, `Use strict mode, when enabled, host prompt is expected to 
@@ -72,20 +148,103 @@ execution.`)
 	flag.IntVar(&args.timeout, "timeout", 10, "SSH connection timeout(in seconds).")
 	flag.IntVar(&args.readwaittime, "readwaittime", 500, `The time to wait ssh channel return the respone, if readwaittime 
 reached, stop waiting, return received data. In Millisecond.`)
-	flag.StringVar(&args.logdir, "logpath", "", "Log command output to /<path>/<ip_addr> instead of stdout.")
-	flag.StringVar(&args.conffiledir, "confpath", "", `Configuration file path, the filename will be used as target hostname.`)
+	flag.StringVar(&args.logdir, "logpath", "", `Log command output to /<path>/<ip_addr> instead of stdout. The 
+characters of target not safe in filename are replaced by '_'.`)
+	flag.StringVar(&args.snapshot, "snapshot", "", `Save the running-config of each host before and after execution to 
+/<path>/<host>.pre and /<path>/<host>.post, and the unified diff 
+between them to /<path>/<host>.diff.`)
+	flag.StringVar(&args.rollback, "rollback", "", `Roll back the configuration when any command failed, the configuration 
+is not saved after rolling back. Modes:
+	checkpoint  roll back to the checkpoint created before execution, 
+	            supported by NEXUS, CISCO, H3C and HUAWEI.
+	undo        replay the commands of 'undofile'.
+	auto        checkpoint if supported by the vendor, otherwise undo.`)
+	flag.StringVar(&args.undofile, "undofile", "", `Undo commands replayed when rolling back, one command per line. If it's 
+a directory, the commands of each host are read from /<path>/<host>.`)
+	flag.StringVar(&args.errpattern, "errpattern", "", `Read the error patterns (regular expressions) of rejected commands from a 
+file, one pattern per line like 'H3C ^\s*% Wrong parameter', the pattern 
+applies to all vendors if it's not prefixed with a vendor. The patterns are 
+added to the builtin patterns of vendors.`)
+	flag.StringVar(&args.onerror, "onerror", onErrorStop, `What to do when a command is rejected by the host, 'stop' or 'continue'. 
+In both cases the host is reported failed, other errors always stop.`)
+	flag.StringVar(&args.conffiledir, "confpath", "", `Configuration file path, the filename will be used as target hostname.
+The filename can be an IPv4 or IPv6 address, a hostname or a FQDN.`)
 	flag.StringVar(&args.cmdfile, "cmdfile", "", `Read commands for a file, one command per line.`)
-	flag.StringVar(&args.transcation, "tran", "", `Run a defined transcation such as get 'ifconifg', 'bgpneighbors'.`)
+	flag.StringVar(&args.transcation, "tran", "", `Run a defined transcation, 'ifconfig' prints the interface configuration, 
+'bgpneighbors' prints the BGP peers with their state and policies as JSON, 
+'ospf' prints the OSPF areas with their networks and neighbors as JSON.`)
 	flag.StringVar(&args.privatekey, "pkey", "", `Private key used for login, if spicified, password will be ignored.`)
 	flag.IntVar(&args.cmdtimeout, "cmdtimeout", 10, `The time of waiting for the command to finish executing, if timeout 
 reached, means execution is failed.`)
 	flag.IntVar(&args.cmdinterval, "cmdinterval", 2, `The interval of sending command to remote host.`)
 	flag.BoolVar(&args.prettyoutput, "pretty", false, `Strip command line and device prompt from the respone string.`)
+	flag.BoolVar(&args.parse, "parse", false, `Print the outputs of commands as JSON lines, the outputs are parsed into 
+records by the TextFSM templates of the commands, the output is kept as text 
+if there's no template of the command.`)
+	flag.StringVar(&args.templates, "templates", "", `Read TextFSM templates from the directory in addition to the builtin ones, 
+the file 'index' in the directory maps '<template>, <vendor>, <command>'.`)
 	flag.BoolVar(&args.help, "help", false, `Usage of CLI.`)
 	flag.BoolVar(&args.nopage, "nopage", true, `Disable enter "SPACE" to show more output lines.`)
-	flag.StringVar(&args.csvfile, "csvfile", "", `Read targets from a csv file. Each record consists of host, vendor, 
-username, password separated by comma. It's recommanded to use 'cmd_prefix'
-to specify executable commands. Note that csv file has no title line.`)
+	flag.BoolVar(&args.ordered, "ordered", false, `Print the output of hosts in the order of targets (the inventory, 
+the host list) instead of the order they completed. The output of 
+'repeat' is printed as soon as it's ready.`)
+	flag.StringVar(&args.csvfile, "csvfile", "", `Read targets from a csv file. The title line names the columns, 'host' 
+is required, 'port', 'vendor', 'username', 'password', 'key', 'enable' 
+and 'jumphost' are applied to the host, other columns are template 
+variables. All rows are validated before running. The csv file without 
+title line is read as host, vendor, username, password and template 
+variables like 'vlan=100'. It's recommanded to use 'cmd_prefix' to 
+specify executable commands.`)
+	flag.StringVar(&args.inventory, "inventory", "", `Read targets from an inventory file(INI), hosts are listed under 
+'[<group>]', variables of a group under '[<group>:vars]' and sub 
+groups under '[<group>:children]'. Variables 'vendor', 'port', 
+'username', 'credential', 'jumphost' and 'transport' are applied to 
+the host, others are template variables.`)
+	flag.StringVar(&args.limit, "limit", "", `Limit the inventory hosts by groups, tags or variables, terms are 
+separated by ',' and all must be matched, for example 'dc=bj,role=tor',
+'bj_tor,!canary'.`)
+	flag.StringVar(&args.template, "template", "", `Read commands from a template file (Go text/template), commands of 
+each vendor are defined in a block named by the lowercase vendor, 
+for example '{{define "h3c"}}vlan {{.vlan}}{{end}}'. The commands 
+are rendered after the vendor is detected.`)
+	flag.StringVar(&args.varsfile, "vars", "", `Read template variables from a csv file, the first line names the 
+variables and the first column must be 'host'.`)
+	flag.BoolVar(&args.dryrun, "dryrun", false, `Print the plan instead of executing, including the hosts, the vendor, 
+the command file applied, the rendered commands and whether the 
+configuration is saved. No host is connected unless 'detect' is given.`)
+	flag.BoolVar(&args.detect, "detect", false, `In dryrun, connect the hosts without vendor to detect the vendor, no 
+other command is sent.`)
+	flag.StringVar(&args.detectcache, "detectcache", "", `Cache the detected vendor, model and OS of hosts in the directory, one 
+file per host, the cached vendor is used without detecting again.`)
+	flag.IntVar(&args.detectttl, "detectttl", 168, `The cached detections older than detectttl(in hours) are detected again, 
+0 means never expire.`)
+	flag.StringVar(&args.facts, "facts", "", `Collect the facts of hosts instead of executing commands, including the 
+hostname, vendor, model, OS version, patch, serial numbers, uptime and 
+management IP, and write them to the file, JSON if it ends with '.json', 
+otherwise CSV.`)
+	flag.StringVar(&args.db, "db", "", `Collect the models of hosts instead of executing commands, including the 
+hardware, interfaces, BGP and OSPF, and save them to the database file as a 
+new version of each host.`)
+	flag.StringVar(&args.dbshow, "dbshow", "", `Print the models of a host in the database given by '-db' and exit, the 
+host may be followed by '@<time>' to print the version at the time, the time 
+is a date like '2026-10-18', a time like '2026-10-18 15:04' or a duration 
+before now like '24h'.`)
+	flag.StringVar(&args.dbchanged, "dbchanged", "", `Print the hosts changed since the time in the database given by '-db' and 
+exit, the time is the same as '-dbshow', for example '24h'.`)
+	flag.IntVar(&args.concurrency, "concurrency", 500, `The max number of hosts running at the same time.`)
+	flag.Float64Var(&args.rate, "rate", 0, `The max number of connections started per second, 0 means no limit.
+It's useful to avoid tripping the rate limits of TACACS/RADIUS.`)
+	flag.StringVar(&args.groupcap, "groupcap", "", `The max number of hosts running at the same time for each value of 
+the inventory variables, for example 'site=20' runs at most 20 hosts 
+of each site at the same time. Multiple caps are separated by ','.`)
+	flag.StringVar(&args.canary, "canary", "", `Rolling mode, run the first N hosts (or N% of hosts like '5%') as 
+the canary wave, and then the rest in waves of 'wave'.`)
+	flag.StringVar(&args.wave, "wave", "", `Rolling mode, run the hosts in waves of N hosts or N% of hosts, each 
+wave starts after the previous one finished.`)
+	flag.StringVar(&args.maxfail, "maxfail", "0", `Rolling mode, abort the rest waves when the failed hosts exceed N or 
+N% of the finished hosts, the hosts not run are reported as skipped.`)
+	flag.BoolVar(&args.confirm, "confirm", false, `Rolling mode, ask for confirmation before each wave after the canary, 
+and pause instead of abort when 'maxfail' exceeded.`)
 	flag.BoolVar(&args.repeat, "repeat", false, `Execute the commands repeatedly at the given 'repeatinterval',
 it will end when the duration reached at 'repeatduration'.`)
 	flag.IntVar(&args.repeatinterval, "repeatinterval", 60, `Interval between commands executions(in seconds), 
@@ -95,347 +254,214 @@ it's different to the 'cmdinterval'.`)
 	flag.Parse()
 }
 
-func readlines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	defer file.Close()
-	if err != nil {
-		return nil, err
-	}
-	reader := bufio.NewReader(file)
-	var lines []string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		lines = append(lines, strings.TrimSpace(line))
-	}
-
-	return lines, nil
-}
-
-func readcsv(filename string) ([][]string, error) {
-	file, err := os.Open(filename)
-	defer file.Close()
-	if err != nil {
-		return nil, err
-	}
-	reader := csv.NewReader(file)
-	var records [][]string
-	for {
-		record, err := reader.Read()
-		if err != nil {
-			break
-		}
-		var _records []string
-		for _, _item := range record {
-			_records = append(_records, strings.TrimSpace(_item))
-		}
-		records = append(records, _records)
-	}
-
-	return records, nil
-}
-
-var BannerVendorKeys map[string]string = map[string]string{
-	"H3C":    "h3c",
-	"HUAWEI": "huawei",
-	"NEXUS":  "nexus",
-	"CISCO":  "cisco",
-	"RUIJIE": "ruijie",
-}
-
-func guessVendorByBanner(banner string) string {
-	s := strings.ToLower(banner)
-	for k, v := range BannerVendorKeys {
-		if strings.Contains(s, v) {
-			return k
-		}
+// newTarget returns the target of host with the settings of args.
+func newTarget(host, port string, sshoptions nwssh.SSHOptions, args *Args) *runner.Target {
+	return &runner.Target{
+		Host:     host,
+		Port:     port,
+		Vendor:   strings.ToUpper(args.swvendor),
+		Username: args.username,
+		Password: args.password,
+		Enable:   args.enable,
+		Timeout:  time.Duration(args.timeout) * time.Second,
+		Options:  sshoptions,
+		Cache:    detectcache,
 	}
-	return ""
 }
 
-var WelecomInfoVendorKeys map[string]string = map[string]string{
-	"H3C":    "h3c",
-	"HUAWEI": "info",
-	"NEXUS":  "nexus",
-	"CISCO":  "user",
-	"RUIJIE": "ruijie", //RUIJIE is not support welecominfo default, so we still use "ruijie" as the key even it takes no effect.
-}
-
-func guessVendorByWelecomInfo(welecominfo string) string {
-	s := strings.ToLower(welecominfo)
-	for k, v := range WelecomInfoVendorKeys {
-		if strings.Contains(s, v) {
-			return k
-		}
-	}
-	return ""
-}
-
-var VersionInfoVendorKeys map[string]string = map[string]string{
-	"H3C":    "H3C",
-	"HUAWEI": "HUAWEI",
-	"NEXUS":  "Nexus",
-	"CISCO":  "Cisco IOS",
-	"RUIJIE": "Ruijie",
-}
+const (
+	onErrorStop     = "stop"
+	onErrorContinue = "continue"
+)
 
-func guessVendorByVesionInfo(versioninfo string) string {
-	for k, v := range WelecomInfoVendorKeys {
-		if strings.Contains(versioninfo, v) {
-			return k
-		}
+// stopOnError records the error of cmd and reports whether to stop executing
+// the rest commands, only the rejected commands may continue.
+func stopOnError(host, cmd string, err error, result *runner.HostReport, args *Args) bool {
+	log.Printf("[%s]Failed to exec cmd '%s'. Error: %v\n", host, cmd, err)
+	if result.Error != "" {
+		result.Error += " "
 	}
-	return ""
-}
-
-func guessVendor(s *nwssh.SSHBase, banner string) string {
-	if s.WelecomInfo == "" {
-		time.Sleep(time.Second * 1)
-	}
-	vendor := guessVendorByWelecomInfo(s.WelecomInfo)
-	if vendor == "" {
-		vendor = guessVendorByBanner(banner)
-		if vendor == "" {
-			resp, _ := s.ExecCommand(`show version | in Ruij`)
-			if strings.Contains(resp, "Ruijie") {
-				return "RUIJIE"
-			}
-
-			resp, _ = s.ExecCommand(`show version | in Software`)
-			if strings.Contains(resp, "Nexus") {
-				return "NEXUS"
-			}
-			if strings.Contains(resp, "Cisco") {
-				return "CISCO"
-			}
-
-			resp, _ = s.ExecCommand(`display version | in Copyright`)
-			rsp := strings.ToLower(resp)
-			if strings.Contains(rsp, "h3c") {
-				return "H3C"
-			}
-			if strings.Contains(rsp, "huawei") {
-				return "HUAWEI"
-			}
-
-		}
-	}
-	return vendor
-}
-
-func pathIsExist(path string) bool {
-	_, err := os.Stat(path)
-	if err != nil {
-		if os.IsExist(err) {
-			return true
-		}
+	result.Error += fmt.Sprintf("Failed to exec cmd '%s'. %v", cmd, err)
+	var rejected *nwssh.CommandRejected
+	if args.onerror == onErrorContinue && errors.As(err, &rejected) {
 		return false
 	}
+	log.Printf("[%s]Exit execution!\n", host)
 	return true
 }
 
-func createPath(path string) error {
-	path = strings.TrimRight(path, "/")
+func run(t *runner.Target, ticket int, cmds []string, args *Args, basiscmd map[string][]string) {
+	var block string
+	defer func() { out.Write(ticket, block) }()
 
-	paths := strings.Split(path, "/")
-	if len(paths) == 0 {
-		return fmt.Errorf("Invalied file path '%s'.", path)
-	}
+	host, vendor := t.Host, t.Vendor
 
-	pathToCreate := ""
-	for _, dir := range paths[0:len(paths)] {
-		pathToCreate += dir + "/"
-		if !pathIsExist(pathToCreate) {
-			if err := os.Mkdir(pathToCreate, 0777); err != nil {
-				return err
-			}
-			if err := os.Chmod(pathToCreate, 0777); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
+	result := &runner.HostReport{Host: host, Vendor: vendor, Status: runner.StatusFailed}
+	defer report.Add(result)
 
-func writefile(file, conntent string) error {
-	return os.WriteFile(file, []byte(conntent), 0666)
-}
-
-func run(host, port string, sshoptions nwssh.SSHOptions, cmds []string, args *Args, basiscmd map[string][]string) {
-	var banner string
-	var devssh *nwssh.SSHBase
-	var err error
-	vendor := strings.ToUpper(args.swvendor)
-	if vendor == "" {
-		sshoptions.BannerCallback = func(message string) error {
-			banner = message
-			return nil
-		}
-	}
-
-	devssh, err = nwssh.SSH(host, port, args.username, args.password, time.Duration(args.timeout)*time.Second, sshoptions)
-	defer devssh.Close()
+	dev, err := runner.Connect(t)
+	defer dev.Close()
+	device, vendor := dev.Driver, dev.Vendor
+	result.Credential = dev.Credential()
+	result.Vendor = vendor
 	if err != nil {
 		log.Printf("[%s]%v\n", host, err)
+		result.Error = err.Error()
 		return
 	}
-	if err = devssh.Connect(); err != nil {
-		log.Printf("[%s]%v\n", host, err)
+
+	cmds, err = resolveCommands(host, vendor, cmds, basiscmd)
+	if err != nil {
+		log.Printf("[%s]Failed to render commands. Error: %v\n", host, err)
+		result.Error = err.Error()
 		return
 	}
 
-	if vendor == "" {
-		vendor = guessVendor(devssh, banner)
-		if vendor == "" {
-			log.Printf("[%s]Failed to parse device's vendor automatically.\n", host)
-			return
+	// The session is prepared before the snapshot and the checkpoint, or they
+	// may stall on the paging of the device.
+	prepared := false
+	if args.nopage && (args.snapshot != "" || len(cmds) > 0) {
+		if !device.SessionPreparation() {
+			log.Printf("[%s]Failed to init execute envirment. Try to execute command directly.\n", host)
 		}
+		prepared = true
 	}
 
-	var device nwssh.SSHBASE
-	if vendor == "H3C" {
-		device = &nwssh.H3cSSH{devssh}
-	} else if vendor == "HUAWEI" {
-		device = &nwssh.HuaweiSSH{devssh}
-	} else if vendor == "NEXUS" {
-		device = &nwssh.NexusSSH{devssh}
-	} else if vendor == "CISCO" {
-		device = &nwssh.CiscoSSH{devssh}
-	} else if vendor == "RUIJIE" {
-		device = &nwssh.RuijieSSH{devssh}
+	var pre string
+	if args.snapshot != "" {
+		pre, err = takeSnapshot(device)
+		if err != nil {
+			log.Printf("[%s]Failed to take the snapshot before execution. Error: %v\n", host, err)
+			result.Error = fmt.Sprintf("Failed to take the snapshot before execution. %v", err)
+			return
+		}
 	}
 
-	if len(cmds) == 0 {
-		cmds = basiscmd[vendor]
+	var plan *rollbackPlan
+	if args.rollback != "" && len(cmds) > 0 {
+		plan, err = prepareRollback(device, host, vendor, args)
+		if err != nil {
+			log.Printf("[%s]%v Exit execution!\n", host, err)
+			result.Error = err.Error()
+			return
+		}
+		defer dropCheckpoint(device, host, plan)
 	}
 
 	var output string
-	var mutex sync.Mutex
 	if args.strictmode && len(cmds) > 0 {
-		if args.nopage && !device.SessionPreparation() {
-			log.Printf("[%s]Failed to init execute envirment. Try to execute command directly.", host)
-		}
 		for _, cmd := range cmds {
 			o, err := device.ExecCommandExpectPrompt(cmd, time.Second*time.Duration(args.cmdtimeout))
-			if args.prettyoutput == true {
-				o = nwssh.SanitizeRespone(o, true, true)
-			}
+			o = commandOutput(device, host, vendor, cmd, o, args)
 			output += o
-			if err != nil {
-				log.Printf("[%s]Failed to exec cmd '%s'. Error: %v.\n", host, cmd, err)
-				log.Printf("[%s]Exit execution!\n", host)
+			if err != nil && stopOnError(host, cmd, err, result, args) {
 				break
 			}
 		}
 	}
 
 	if !args.strictmode && len(cmds) > 0 {
-		if args.nopage && !device.SessionPreparation() {
-			log.Printf("[%s]Failed to init execute envirment. Try to execute command directly.\n", host)
-		}
 		for _, cmd := range cmds {
 			o, err := device.ExecCommand(cmd)
-			if args.prettyoutput {
-				o = nwssh.SanitizeRespone(o, true, true)
-			}
+			o = commandOutput(device, host, vendor, cmd, o, args)
 			output += o
-			if err != nil {
-				log.Printf("[%s]Failed to exec cmd '%s'. Error: %v\n", host, cmd, err)
-				log.Printf("[%s]Exit execution!\n", host)
+			if err != nil && stopOnError(host, cmd, err, result, args) {
 				break
 			}
 			time.Sleep(time.Second * time.Duration(args.cmdinterval))
 		}
 	}
 
+	rolledback := false
+	if plan != nil && result.Error != "" {
+		if err := rollback(device, host, plan, args); err != nil {
+			log.Printf("[%s]Failed to roll back. Error: %v\n", host, err)
+			result.Error += fmt.Sprintf(" Failed to roll back. %v", err)
+		} else {
+			rolledback = true
+			result.Error += " Rolled back."
+		}
+	}
+
 	if args.transcation != "" {
-		if args.nopage && !device.SessionPreparation() {
+		if args.nopage && !prepared && !device.SessionPreparation() {
 			log.Printf("[%s]Failed to init executable envirment. Try to execute commands directly.\n", host)
 		}
 		output, err = device.RunTranscation(args.transcation)
 		if err != nil {
 			log.Printf("[%s]Failed exec transcation '%s'. Error: %v\n", host, args.transcation, err)
+			result.Error = fmt.Sprintf("Failed exec transcation '%s'. %v", args.transcation, err)
 		}
 	}
 
-	if args.saveconfig {
+	if args.saveconfig && !rolledback {
 		if !device.SaveRuningConfig() {
 			log.Printf("[%s]Failed save configuration.\n", host)
+			result.Error = "Failed save configuration."
+		}
+	}
+
+	if args.snapshot != "" {
+		if err := snapshotAfter(device, host, pre, args); err != nil {
+			log.Printf("[%s]Failed to take the snapshot after execution. Error: %v\n", host, err)
+			result.Error = fmt.Sprintf("Failed to take the snapshot after execution. %v", err)
 		}
 	}
 
 	if args.logdir != "" {
-		writefile(args.logdir+host, output)
+		runner.WriteFile(args.logdir+runner.LogFileName(host), output)
+	} else if args.parse {
+		block = output
 	} else {
-		mutex.Lock()
-		os.Stdout.Write([]byte(output + "\n"))
-		mutex.Unlock()
+		block = runner.Block(host, output)
 	}
 
+	if result.Error == "" {
+		result.Status = runner.StatusOK
+	} else if rolledback {
+		result.Status = runner.StatusRolledBack
+	}
 	log.Printf("[%s]Execution completed!\n", host)
 }
 
-func runRepeatedly(host, port string, sshoptions nwssh.SSHOptions, cmds []string, args *Args, basiscmd map[string][]string) {
-	var banner string
-	var devssh *nwssh.SSHBase
-	var err error
-	vendor := strings.ToUpper(args.swvendor)
-	if vendor == "" {
-		sshoptions.BannerCallback = func(message string) error {
-			banner = message
-			return nil
-		}
-	}
+func runRepeatedly(t *runner.Target, ticket int, cmds []string, args *Args, basiscmd map[string][]string) {
+	var block string
+	defer func() { out.Write(ticket, block) }()
+
+	host, vendor := t.Host, t.Vendor
 
-	devssh, err = nwssh.SSH(host, port, args.username, args.password, time.Duration(args.timeout)*time.Second, sshoptions)
-	defer devssh.Close()
+	result := &runner.HostReport{Host: host, Vendor: vendor, Status: runner.StatusFailed}
+	defer report.Add(result)
+
+	dev, err := runner.Connect(t)
+	defer dev.Close()
+	device, vendor := dev.Driver, dev.Vendor
+	result.Credential = dev.Credential()
+	result.Vendor = vendor
 	if err != nil {
 		log.Printf("[%s]%v\n", host, err)
+		result.Error = err.Error()
 		return
 	}
-	if err = devssh.Connect(); err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return
-	}
-
-	if vendor == "" {
-		vendor = guessVendor(devssh, banner)
-		if vendor == "" {
-			log.Printf("[%s]Failed to parse device's vendor automatically.\n", host)
-			return
-		}
-	}
-
-	var device nwssh.SSHBASE
-	if vendor == "H3C" {
-		device = &nwssh.H3cSSH{devssh}
-	} else if vendor == "HUAWEI" {
-		device = &nwssh.HuaweiSSH{devssh}
-	} else if vendor == "NEXUS" {
-		device = &nwssh.NexusSSH{devssh}
-	} else if vendor == "CISCO" {
-		device = &nwssh.CiscoSSH{devssh}
-	} else if vendor == "RUIJIE" {
-		device = &nwssh.RuijieSSH{devssh}
-	}
 
-	if len(cmds) == 0 {
-		cmds = basiscmd[vendor]
+	cmds, err = resolveCommands(host, vendor, cmds, basiscmd)
+	if err != nil {
+		log.Printf("[%s]Failed to render commands. Error: %v\n", host, err)
+		result.Error = err.Error()
+		return
 	}
 
 	var output string
-	var mutex sync.Mutex
 	var outputFile *os.File
 	var startTime time.Time
 
 	duration := time.Duration(args.repeatduration) * time.Second
 
 	if args.logdir != "" {
-		outputFile, err = os.OpenFile(args.logdir+host, os.O_CREATE|os.O_WRONLY, 0666)
+		outputFile, err = os.OpenFile(args.logdir+runner.LogFileName(host), os.O_CREATE|os.O_WRONLY, 0666)
 		if err != nil {
-			log.Printf("[%s]Failed to create the output file '%s', task stopped.", host, args.logdir+host)
+			log.Printf("[%s]Failed to create the output file '%s', task stopped.", host, args.logdir+runner.LogFileName(host))
+			result.Error = err.Error()
 			return
 		}
 		defer outputFile.Close()
@@ -450,13 +476,9 @@ REPEAT:
 	if args.strictmode && len(cmds) > 0 {
 		for _, cmd := range cmds {
 			o, err := device.ExecCommandExpectPrompt(cmd, time.Second*time.Duration(args.cmdtimeout))
-			if args.prettyoutput == true {
-				o = nwssh.SanitizeRespone(o, true, true)
-			}
+			o = commandOutput(device, host, vendor, cmd, o, args)
 			output += o
-			if err != nil {
-				log.Printf("[%s]Failed to exec cmd '%s'. Error: %v.\n", host, cmd, err)
-				log.Printf("[%s]Exit execution!\n", host)
+			if err != nil && stopOnError(host, cmd, err, result, args) {
 				break
 			}
 		}
@@ -465,13 +487,9 @@ REPEAT:
 	if !args.strictmode && len(cmds) > 0 {
 		for _, cmd := range cmds {
 			o, err := device.ExecCommand(cmd)
-			if args.prettyoutput {
-				o = nwssh.SanitizeRespone(o, true, true)
-			}
+			o = commandOutput(device, host, vendor, cmd, o, args)
 			output += o
-			if err != nil {
-				log.Printf("[%s]Failed to exec cmd '%s'. Error: %v\n", host, cmd, err)
-				log.Printf("[%s]Exit execution!\n", host)
+			if err != nil && stopOnError(host, cmd, err, result, args) {
 				break
 			}
 			time.Sleep(time.Second * time.Duration(args.cmdinterval))
@@ -482,22 +500,24 @@ REPEAT:
 		output, err = device.RunTranscation(args.transcation)
 		if err != nil {
 			log.Printf("[%s]Failed exec transcation '%s'. Error: %v\n", host, args.transcation, err)
+			result.Error = fmt.Sprintf("Failed exec transcation '%s'. %v", args.transcation, err)
 		}
 	}
 
 	if args.saveconfig {
 		if !device.SaveRuningConfig() {
 			log.Printf("[%s]Failed save configuration.\n", host)
+			result.Error = "Failed save configuration."
 		}
 	}
 
 	if args.logdir != "" {
 		outputFile.Write([]byte(output))
 
+	} else if args.parse {
+		out.Print(output)
 	} else {
-		mutex.Lock()
-		os.Stdout.Write([]byte(output + "\n"))
-		mutex.Unlock()
+		out.Print(runner.Block(host, output))
 	}
 
 	if args.repeatduration == 0 || time.Now().Sub(startTime) < duration {
@@ -506,19 +526,43 @@ REPEAT:
 		goto REPEAT
 	}
 
+	if result.Error == "" {
+		result.Status = runner.StatusOK
+	}
 	log.Printf("[%s]Execution completed!\n", host)
 }
 
+func resolveCommands(host, vendor string, cmds []string, basiscmd map[string][]string) ([]string, error) {
+	if cmdtemplate != nil {
+		return renderCommands(cmdtemplate, host, vendor)
+	}
+	if len(cmds) == 0 {
+		return basiscmd[vendor], nil
+	}
+	return cmds, nil
+}
+
+func loadCommandTemplate(args *Args) {
+	var err error
+	if args.template != "" {
+		cmdtemplate, err = loadTemplate(args.template)
+		if err != nil {
+			log.Fatalf("Failed to load template. Error: %v\n", err)
+		}
+	}
+	if args.varsfile != "" {
+		if err = loadHostVars(args.varsfile); err != nil {
+			log.Fatalf("Failed to load vars file. Error: %v\n", err)
+		}
+	}
+}
+
 func csvModeRunning(args *Args) {
 
 	var cmds []string
 	var err error
 	basiscmd := make(map[string][]string)
 
-	maxThread := 500
-	threadchan := make(chan struct{}, maxThread)
-	wait := sync.WaitGroup{}
-
 	if args.cmd != "" {
 		cmds = strings.Split(args.cmd, ";")
 	} else if args.cmdprefix != "" {
@@ -530,57 +574,181 @@ func csvModeRunning(args *Args) {
 			"RUIJIE": nil,
 		}
 		for k, _ := range basiscmd {
-			cmds_t, err := readlines(args.cmdprefix + ".cmd." + strings.ToLower(k))
+			cmds_t, err := runner.ReadLines(args.cmdprefix + ".cmd." + strings.ToLower(k))
 			if err == nil {
 				basiscmd[k] = cmds_t
 			}
 		}
 	} else if args.cmdfile != "" {
-		cmds, err = readlines(args.cmdfile)
+		cmds, err = runner.ReadLines(args.cmdfile)
 		if err != nil {
 			log.Printf("%v\n", err)
 			os.Exit(0)
 		}
 	}
 
-	sshoptions := nwssh.SSHOptions{
-		PrivateKeyFile: args.privatekey,
-		IgnorHostKey:   true,
-		BannerCallback: func(msg string) error {
-			return nil
-		},
-		TermType:     "vt100",
-		TermHeight:   560,
-		TermWidht:    480,
-		ReadWaitTime: time.Duration(args.readwaittime) * time.Millisecond, //Read data from a ssh channel timeout
+	sshoptions := runner.DefaultSSHOptions(time.Duration(args.readwaittime) * time.Millisecond)
+	sshoptions.PrivateKeyFile = args.privatekey
+
+	hosts, err := runner.LoadCSVInventory(args.csvfile)
+	if err != nil {
+		log.Fatalf("Failed to read host infomation from csv file.\n%v\n", err)
 	}
 
-	records, err := readcsv(args.csvfile)
+	runHosts(args, sshoptions, cmds, basiscmd, hosts)
+	runJobs(args)
+}
+
+func inventoryModeRunning(args *Args, sshoptions nwssh.SSHOptions, cmds []string, basiscmd map[string][]string) {
+	inv, err := runner.LoadInventory(args.inventory)
+	if err != nil {
+		log.Fatalf("Failed to load inventory. Error: %v\n", err)
+	}
+	hosts, err := inv.Select(args.limit)
 	if err != nil {
-		fmt.Println("Fialed to read host infomation from csv file.")
+		log.Fatalf("%v\n", err)
+	}
+	if len(hosts) == 0 {
+		log.Printf("No host in inventory matches '%s'.\n", args.limit)
+		return
+	}
+
+	runHosts(args, sshoptions, cmds, basiscmd, hosts)
+}
+
+// runHosts adds a job for each of hosts with the variables applied.
+func runHosts(args *Args, sshoptions nwssh.SSHOptions, cmds []string, basiscmd map[string][]string, hosts []*runner.InventoryHost) {
+	// Variables from the vars file take precedence over the inventory's.
+	for _, h := range hosts {
+		for k, v := range h.Vars {
+			if _, ok := hostvars[h.Address][k]; !ok {
+				setHostVar(h.Address, k, v)
+			}
+		}
+	}
+
+	for _, h := range hosts {
+		if transport := h.Var("transport"); transport != "" && strings.ToLower(transport) != "ssh" {
+			log.Printf("[%s]Unsupported transport '%s'.\n", h.Address, transport)
+			continue
+		}
+
+		port := args.port
+		if p := h.Var("port"); p != "" {
+			port = p
+		}
+		t := newTarget(h.Address, port, sshoptions, args)
+		t.Vars = h.Vars
+		if vendor := h.Var("vendor"); vendor != "" {
+			t.Vendor = strings.ToUpper(vendor)
+		}
+		if username := h.Var("username"); username != "" {
+			t.Username = username
+		}
+		if password := h.Var("password"); password != "" {
+			t.Password = password
+		}
+		if enable := h.Var("enable"); enable != "" {
+			t.Enable = enable
+		}
+		if key := h.Var("key"); key != "" {
+			t.Options.PrivateKeyFile = key
+		}
+
+		// The referenced credentials take precedence over the password.
+		names := h.Var("credential")
+		if names == "" {
+			names = args.credential
+		}
+		if (names != "" || t.Password == "") && t.Options.PrivateKeyFile == "" && args.login() {
+			creds, err := credentials.Resolve(names, t.Username)
+			if err != nil {
+				log.Printf("[%s]%v\n", h.Address, err)
+				continue
+			}
+			t.Options.Credentials = creds
+		}
+		t.Options.JumpHost = h.Var("jumphost")
+
+		addJob(t, cmds, basiscmd)
 	}
+}
+
+// job is a target to run, the jobs are started after all targets are read,
+// all at once or in waves of the rollout.
+type job struct {
+	target   *runner.Target
+	ticket   int
+	cmds     []string
+	basiscmd map[string][]string
+	file     string //The confpath file of the commands.
+}
+
+var jobs []*job
 
-	for _, record := range records {
-		_args := args
-		_args.host = record[0]
-		_args.swvendor = record[1]
-		_args.username = record[2]
-		_args.password = record[3]
+func addJob(t *runner.Target, cmds []string, basiscmd map[string][]string) *job {
+	j := &job{target: t, ticket: out.Reserve(), cmds: cmds, basiscmd: basiscmd}
+	jobs = append(jobs, j)
+	return j
+}
 
-		wait.Add(1)
-		go func(host string) {
-			threadchan <- struct{}{}
-			if args.repeat {
-				runRepeatedly(host, args.port, sshoptions, cmds, _args, basiscmd)
+func startJobs(jobs []*job, args *Args) {
+	for _, j := range jobs {
+		j := j
+		pool.Go(j.target.Vars, func() {
+			if args.facts != "" {
+				collectFacts(j.target, j.ticket)
+			} else if args.db != "" {
+				collectModels(j.target, j.ticket)
+			} else if args.repeat {
+				runRepeatedly(j.target, j.ticket, j.cmds, args, j.basiscmd)
 			} else {
-				run(host, args.port, sshoptions, cmds, _args, basiscmd)
+				run(j.target, j.ticket, j.cmds, args, j.basiscmd)
 			}
-			<-threadchan
-			wait.Done()
-		}(_args.host)
+		})
+	}
+}
+
+// runJobs runs the jobs and waits for them finished, in rolling mode the
+// jobs not started are reported as skipped.
+func runJobs(args *Args) {
+	if args.dryrun {
+		planJobs(args)
+		return
+	}
+	if !rollout.Enabled() {
+		startJobs(jobs, args)
+		pool.Wait()
+		return
+	}
 
+	started, err := rollout.Run(len(jobs), func(start, end int) int {
+		failed := report.Failed()
+		log.Printf("Rolling out to hosts %d-%d of %d.\n", start+1, end, len(jobs))
+		startJobs(jobs[start:end], args)
+		pool.Wait()
+		return report.Failed() - failed
+	})
+	if err != nil {
+		log.Printf("%v %d hosts are skipped.\n", err, len(jobs)-started)
+		for _, j := range jobs[started:] {
+			report.Add(&runner.HostReport{Host: j.target.Host, Vendor: j.target.Vendor, Status: runner.StatusSkipped})
+			out.Write(j.ticket, "")
+		}
+	}
+}
+
+// writeReport writes the report and the facts of hosts, and closes the
+// database.
+func writeReport(args *Args) {
+	writeFacts(args)
+	closeStore()
+	if args.report == "" {
+		return
+	}
+	if err := report.WriteCSV(args.report); err != nil {
+		log.Printf("Failed to write report '%s'. Error: %v\n", args.report, err)
 	}
-	wait.Wait()
 }
 
 func main() {
@@ -591,99 +759,148 @@ func main() {
 		os.Exit(0)
 	}
 
+	out = runner.NewOutput(os.Stdout, args.ordered || args.dryrun)
+	log.SetOutput(out.LogWriter(os.Stderr))
+
+	if args.vaultadd != "" {
+		if args.vault == "" {
+			fmt.Println("Vault file is expected but got none. See help docs.")
+			os.Exit(0)
+		}
+		if err := runner.AddVaultCredential(args.vault, args.vaultadd); err != nil {
+			log.Fatalf("Failed to add credential to vault. Error: %v\n", err)
+		}
+		os.Exit(0)
+	}
+
+	var err error
+	credentials, err = runner.NewCredentialChain(runner.CredentialSources{
+		Order:   args.credsource,
+		Netrc:   args.netrc,
+		Vault:   args.vault,
+		Command: args.credcmd,
+	})
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	groupcaps, err := runner.ParseGroupCaps(args.groupcap)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	pool = runner.NewPool(args.concurrency, args.rate, groupcaps)
+
+	if rollout.Canary, err = runner.ParseSize(args.canary); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if rollout.Wave, err = runner.ParseSize(args.wave); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if rollout.MaxFail, err = runner.ParseSize(args.maxfail); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	rollout.Confirm = args.confirm
+	if err = checkRollbackArgs(&args); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if err = checkFactsArgs(&args); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if err = checkDBArgs(&args); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if args.onerror != onErrorStop && args.onerror != onErrorContinue {
+		log.Fatalf("Unsupported onerror policy '%s'.\n", args.onerror)
+	}
+	if args.errpattern != "" {
+		if err = runner.LoadErrorPatterns(args.errpattern); err != nil {
+			log.Fatalf("Failed to load error patterns. Error: %v\n", err)
+		}
+	}
+	if args.rollback != "" && args.repeat {
+		log.Fatalf("Rollback can't be used with 'repeat'.\n")
+	}
+	if args.snapshot != "" && args.repeat {
+		log.Fatalf("Snapshot can't be used with 'repeat'.\n")
+	}
+	if args.detectcache != "" {
+		if err = runner.CreatePath(args.detectcache); err != nil {
+			log.Fatalf("Failed to create detect cache path '%s'. Error: %v\n", args.detectcache, err)
+		}
+		detectcache = &nwssh.DetectCache{Dir: args.detectcache, TTL: time.Duration(args.detectttl) * time.Hour}
+	}
+	if args.snapshot != "" {
+		if err = runner.CreatePath(args.snapshot); err != nil {
+			log.Fatalf("Failed to create snapshot path '%s'. Error: %v\n", args.snapshot, err)
+		}
+	}
+	if rollout.Enabled() && args.repeat {
+		log.Fatalf("Rolling mode can't be used with 'repeat'.\n")
+	}
+
+	loadCommandTemplate(&args)
+	loadParseIndex(&args)
+	openStore(&args)
+
 	if args.csvfile != "" {
 		csvModeRunning(&args)
+		writeReport(&args)
 		os.Exit(1)
 	}
 
-	if args.host == "" && args.hostfile == "" && args.conffiledir == "" {
+	if args.host == "" && args.hostfile == "" && args.conffiledir == "" && args.inventory == "" {
 		fmt.Println("Traget host is expected but got none. See help docs.")
 		flag.PrintDefaults()
 		os.Exit(0)
 	}
 
-	if args.username == "" {
+	var creds []nwssh.Credential
+	if (args.password == "" || args.credential != "") && args.privatekey == "" && args.login() && args.inventory == "" {
+		creds, err = credentials.Resolve(args.credential, args.username)
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+		if args.username == "" {
+			args.username = creds[0].Username
+		}
+	}
+
+	if args.username == "" && args.inventory == "" && args.login() {
 		fmt.Println("Username is expected but got none. See help docs.")
 		flag.PrintDefaults()
 		os.Exit(0)
 	}
 
-	if args.password == "" {
-		fmt.Printf("Please input the password:")
-		fmt.Scanf("%s", &args.password)
-	}
-
-	var err error
 	if args.logdir != "" {
 		if !strings.HasSuffix(args.logdir, "/") {
 			args.logdir += "/"
 		}
-		err = createPath(args.logdir)
+		err = runner.CreatePath(args.logdir)
 		if err != nil {
-			fmt.Println("Failed to create logpath '%s', error: %v", args.logdir, err)
+			fmt.Printf("Failed to create logpath '%s', error: %v\n", args.logdir, err)
 			os.Exit(0)
 		}
 
 	}
-	sshoptions := nwssh.SSHOptions{
-		PrivateKeyFile: args.privatekey,
-		IgnorHostKey:   true,
-		BannerCallback: func(msg string) error {
-			return nil
-		},
-		TermType:     "vt100",
-		TermHeight:   560,
-		TermWidht:    480,
-		ReadWaitTime: time.Duration(args.readwaittime) * time.Millisecond, //Read data from a ssh channel timeout
-	}
+	sshoptions := runner.DefaultSSHOptions(time.Duration(args.readwaittime) * time.Millisecond)
+	sshoptions.PrivateKeyFile = args.privatekey
+	sshoptions.Credentials = creds
 
 	var cmds []string
 
 	basiscmd := make(map[string][]string)
 
-	maxThread := 500
-	threadchan := make(chan struct{}, maxThread)
-	wait := sync.WaitGroup{}
-	if args.conffiledir != "" {
-		fileinfo, err := os.ReadDir(args.conffiledir)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(0)
-		}
-		for _, f := range fileinfo {
-			host := f.Name()
-
-			if !f.IsDir() && len(strings.Split(host, ".")) == 4 {
-				cmds, err = readlines(args.conffiledir + host)
-				if err != nil {
-					fmt.Println("Fialed to read command from configuration file.")
-				}
-				wait.Add(1)
-				go func(host string, cmds []string) {
-					threadchan <- struct{}{}
-					if args.repeat {
-						runRepeatedly(host, args.port, sshoptions, cmds, &args, basiscmd)
-					} else {
-						run(host, args.port, sshoptions, cmds, &args, basiscmd)
-					}
-
-					<-threadchan
-					wait.Done()
-				}(host, cmds)
-			}
-		}
-	}
-
 	var hosts []string
 	if args.host != "" {
 		hosts = strings.Split(args.host, ";")
 
 	} else if args.hostfile != "" {
-		hosts, err = readlines(args.hostfile)
+		hosts, err = runner.ReadLines(args.hostfile)
 		if err != nil {
-			log.Fatal("%v", err)
+			log.Fatalf("%v", err)
 		}
 	}
+	hosts = runner.ParseHosts(hosts)
 
 	if args.cmd != "" {
 		cmds = strings.Split(args.cmd, ";")
@@ -696,31 +913,56 @@ func main() {
 			"RUIJIE": nil,
 		}
 		for k, _ := range basiscmd {
-			cmds_t, err := readlines(args.cmdprefix + ".cmd." + strings.ToLower(k))
+			cmds_t, err := runner.ReadLines(args.cmdprefix + ".cmd." + strings.ToLower(k))
 			if err == nil {
 				basiscmd[k] = cmds_t
 			}
 		}
 	} else if args.cmdfile != "" {
-		cmds, err = readlines(args.cmdfile)
+		cmds, err = runner.ReadLines(args.cmdfile)
 		if err != nil {
 			log.Printf("%v\n", err)
 			os.Exit(0)
 		}
 	}
 
-	for _, host := range hosts {
-		wait.Add(1)
-		go func(host string) {
-			threadchan <- struct{}{}
-			if args.repeat {
-				runRepeatedly(host, args.port, sshoptions, cmds, &args, basiscmd)
-			} else {
-				run(host, args.port, sshoptions, cmds, &args, basiscmd)
+	// The hosts of confpath fall back to the commands of -cmdprefix by their
+	// vendors if their files are empty.
+	if args.conffiledir != "" {
+		fileinfo, err := os.ReadDir(args.conffiledir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(0)
+		}
+		for _, f := range fileinfo {
+			name := f.Name()
+			if f.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+				continue
 			}
-			<-threadchan
-			wait.Done()
-		}(host)
+			host, err := nwnet.ParseHost(name)
+			if err != nil {
+				log.Printf("Skip file '%s' in confpath. %v\n", name, err)
+				continue
+			}
+
+			confcmds, err := runner.ReadLines(filepath.Join(args.conffiledir, name))
+			if err != nil {
+				fmt.Println("Fialed to read command from configuration file.")
+			}
+			t := newTarget(host, args.port, sshoptions, &args)
+			j := addJob(t, confcmds, basiscmd)
+			j.file = filepath.Join(args.conffiledir, name)
+		}
+	}
+
+	if args.inventory != "" {
+		inventoryModeRunning(&args, sshoptions, cmds, basiscmd)
+	}
+
+	for _, host := range hosts {
+		t := newTarget(host, args.port, sshoptions, &args)
+		addJob(t, cmds, basiscmd)
 	}
-	wait.Wait()
+	runJobs(&args)
+	writeReport(&args)
 }
@@ -0,0 +1,3 @@
+module nwnet
+
+go 1.19
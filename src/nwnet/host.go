@@ -0,0 +1,106 @@
+package iptool
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseHost validates a target host and returns it in the form used to
+// connect, the host can be an IPv4 address, an IPv6 address with or without
+// brackets, a hostname or a FQDN. For example:
+//
+//	10.10.10.10
+//	2001:db8::1
+//	[2001:db8::1]
+//	BJ_YF_305-A-15_CE5810
+//	sw1.bj.example.com
+//
+// The brackets of IPv6 address are stripped, hostname is lowercased.
+func ParseHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return "", errors.New("Host is empty.")
+	}
+
+	if strings.HasPrefix(host, "[") {
+		if !strings.HasSuffix(host, "]") {
+			return "", fmt.Errorf("Invalid host '%s', missing ']'.", host)
+		}
+		if !IsIP(host[1 : len(host)-1]) {
+			return "", fmt.Errorf("Invalid IPv6 address '%s'.", host)
+		}
+		return host[1 : len(host)-1], nil
+	}
+
+	if IsIP(host) {
+		return host, nil
+	}
+	if strings.Contains(host, ":") {
+		return "", fmt.Errorf("Invalid IPv6 address '%s'.", host)
+	}
+	if !IsHostname(host) {
+		return "", fmt.Errorf("Invalid hostname '%s'.", host)
+	}
+	return strings.ToLower(host), nil
+}
+
+// IsIP reports whether s is an IPv4 or IPv6 address, IPv6 address may have
+// a zone like 'fe80::1%eth0'.
+func IsIP(s string) bool {
+	if i := strings.LastIndex(s, "%"); i > 0 && strings.Contains(s[:i], ":") {
+		s = s[:i]
+	}
+	return net.ParseIP(s) != nil
+}
+
+// IsHostname reports whether s is a valid hostname or FQDN. Labels consist
+// of letters, digits, '-' and '_' which is used widely in switch names though
+// it's not allowed by RFC 1123. A name looks like an IPv4 address but not is
+// rejected, such as '10.10.10.256'.
+func IsHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if len(s) == 0 || len(s) > 253 {
+		return false
+	}
+
+	allDigits := true
+	for _, label := range strings.Split(s, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= '0' && c <= '9':
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-', c == '_':
+				allDigits = false
+			default:
+				return false
+			}
+		}
+	}
+	return !allDigits
+}
+
+// JoinHostPort joins host and port into 'host:port', IPv6 address is
+// enclosed in brackets like '[2001:db8::1]:22'.
+func JoinHostPort(host, port string) string {
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// FileName returns a filesystem safe name of host, the characters other than
+// letters, digits, '.', '-' and '_' are replaced by '_', for example,
+// '2001:db8::1' is '2001_db8__1'.
+func FileName(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, host)
+}
@@ -22,6 +22,28 @@ func (ipv4 *V4Prefix) NetAddress() net.IP {
 	return ipv4.IP.Mask(ipv4.Mask)
 }
 
+func (ipv4 V4Prefix) String() string {
+	ipnet := net.IPNet(ipv4)
+	return ipnet.String()
+}
+
+func (ipv4 V4Prefix) MarshalText() ([]byte, error) {
+	return []byte(ipv4.String()), nil
+}
+
+func (ipv4 *V4Prefix) UnmarshalText(text []byte) error {
+	if len(text) == 0 || string(text) == "<nil>" {
+		*ipv4 = V4Prefix{}
+		return nil
+	}
+	p, err := IPv4(string(text))
+	if err != nil {
+		return err
+	}
+	*ipv4 = *p
+	return nil
+}
+
 func IPv4(ipv4 string) (*V4Prefix, error) {
 
 	if !strings.Contains(ipv4, "/") {
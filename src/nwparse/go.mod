@@ -0,0 +1,3 @@
+module nwparse
+
+go 1.19
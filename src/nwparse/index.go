@@ -0,0 +1,170 @@
+package nwparse
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+/*
+Index maps the commands of vendors to the templates, it's read from a file
+named 'index' in the template directory, each line is:
+
+	<template file>, <vendor>, <command>
+
+The command can be abbreviated like the CLI, the part in '[[...]]' is
+optional, for example 'di[[splay]] ver[[sion]]' matches 'dis version' and
+'display ver'. The commands are matched case-insensitively after the spaces
+are collapsed, the first matched line is used.
+*/
+type Index struct {
+	entries []indexEntry
+}
+
+type indexEntry struct {
+	vendor   string
+	command  *regexp.Regexp
+	template *Template
+}
+
+// ErrNoTemplate is returned by Parse if no template matches the command.
+var ErrNoTemplate = errors.New("No template for the command.")
+
+//go:embed templates
+var builtinFS embed.FS
+
+var (
+	builtin     *Index
+	builtinErr  error
+	builtinOnce sync.Once
+)
+
+// Builtin returns the index of the templates shipped with nwparse.
+func Builtin() (*Index, error) {
+	builtinOnce.Do(func() {
+		var templates fs.FS
+		templates, builtinErr = fs.Sub(builtinFS, "templates")
+		if builtinErr == nil {
+			builtin, builtinErr = LoadIndex(templates)
+		}
+	})
+	return builtin, builtinErr
+}
+
+// LoadIndexDir loads the index and the templates in dir.
+func LoadIndexDir(dir string) (*Index, error) {
+	return LoadIndex(os.DirFS(dir))
+}
+
+// LoadIndex loads the index and the templates in fsys.
+func LoadIndex(fsys fs.FS) (*Index, error) {
+	file, err := fsys.Open("index")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	index := &Index{}
+	templates := map[string]*Template{}
+	scanner := bufio.NewScanner(file)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ",", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("Invalid index line %d '%s'.", lineno, line)
+		}
+		name, vendor, command := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2])
+		t, ok := templates[name]
+		if !ok {
+			if t, err = loadTemplate(fsys, name); err != nil {
+				return nil, err
+			}
+			templates[name] = t
+		}
+		r, err := commandRegexp(command)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid command of index line %d '%s'. %v", lineno, line, err)
+		}
+		index.entries = append(index.entries, indexEntry{strings.ToUpper(vendor), r, t})
+	}
+	return index, scanner.Err()
+}
+
+func loadTemplate(fsys fs.FS, name string) (*Template, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseTemplate(name, file)
+}
+
+// commandRegexp converts 'sh[[ow]] ver[[sion]]' to a regexp.
+func commandRegexp(command string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString(`(?i)^`)
+	for i, word := range strings.Fields(command) {
+		if i > 0 {
+			b.WriteString(`\s+`)
+		}
+		for word != "" {
+			start := strings.Index(word, "[[")
+			if start < 0 {
+				b.WriteString(regexp.QuoteMeta(word))
+				break
+			}
+			end := strings.Index(word, "]]")
+			if end < start {
+				return nil, fmt.Errorf("Unclosed '[['.")
+			}
+			b.WriteString(regexp.QuoteMeta(word[:start]))
+			optional := word[start+2 : end]
+			for _, c := range optional {
+				b.WriteString(`(?:` + regexp.QuoteMeta(string(c)))
+			}
+			b.WriteString(strings.Repeat(`)?`, len([]rune(optional))))
+			word = word[end+2:]
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.Compile(b.String())
+}
+
+// Add adds the entries of other before the entries of i, so the templates of
+// other take precedence.
+func (i *Index) Add(other *Index) {
+	i.entries = append(append([]indexEntry{}, other.entries...), i.entries...)
+}
+
+// Lookup returns the template of the command of vendor.
+func (i *Index) Lookup(vendor, command string) (*Template, bool) {
+	vendor = strings.ToUpper(vendor)
+	command = strings.Join(strings.Fields(command), " ")
+	for _, e := range i.entries {
+		if e.vendor == vendor && e.command.MatchString(command) {
+			return e.template, true
+		}
+	}
+	return nil, false
+}
+
+// Parse parses the output of the command of vendor, ErrNoTemplate is returned
+// if there's no template.
+func (i *Index) Parse(vendor, command, output string) (*Result, error) {
+	t, ok := i.Lookup(vendor, command)
+	if !ok {
+		return nil, ErrNoTemplate
+	}
+	return t.Parse(output)
+}
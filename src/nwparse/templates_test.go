@@ -0,0 +1,113 @@
+package nwparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+// templateSamples are the outputs of the devices parsed by the builtin
+// templates.
+var templateSamples = []struct {
+	name, vendor, command, output string
+	want                          []map[string]interface{}
+}{
+	{
+		name:    "h3c static and dynamic aggregations",
+		vendor:  "H3C",
+		command: "display link-aggregation verbose",
+		output: `Loadsharing Type: Shar -- Loadsharing, NonS -- Non-Loadsharing
+Port Status: S -- Selected, U -- Unselected, I -- Individual
+Port: A -- Auto port, M -- Management port, R -- Reference port
+Flags:  A -- LACP_Activity, B -- LACP_Timeout, C -- Aggregation,
+        D -- Synchronization, E -- Collecting, F -- Distributing,
+        G -- Defaulted, H -- Expired
+
+Aggregate Interface: Bridge-Aggregation1
+Aggregation Mode: Static
+Loadsharing Type: Shar
+Management VLANs: None
+  Port                Status  Priority Oper-Key
+--------------------------------------------------------------------------------
+  XGE1/0/49(R)        S       32768    1
+  XGE1/0/50           S       32768    1
+
+Aggregate Interface: Bridge-Aggregation2
+Aggregation Mode: Dynamic
+Loadsharing Type: Shar
+Management VLANs: None
+System ID: 0x8000, 3c8c-4000-0001
+Local:
+  Port                Status   Priority Index  Oper-Key               Flag
+--------------------------------------------------------------------------------
+  HGE1/0/53           S        32768    1      2                      {ACDEF}
+  HGE1/0/54           U        32768    2      2                      {AG}
+Remote:
+  Actor               Priority Index  Oper-Key SystemID               Flag
+--------------------------------------------------------------------------------
+  HGE1/0/53           32768    53     2        0x8000, 3c8c-4000-0002 {ACDEF}
+  HGE1/0/54           32768    54     2        0x8000, 3c8c-4000-0002 {ACDEF}
+
+Aggregate Interface: Bridge-Aggregation3
+Aggregation Mode: Static
+Loadsharing Type: Shar
+Management VLANs: None
+  Port                Status  Priority Oper-Key
+--------------------------------------------------------------------------------
+  GE1/0/1             U       32768    3`,
+		want: []map[string]interface{}{
+			{"AGGREGATE": "Bridge-Aggregation1", "PORTS": "XGE1/0/49(R)", "STATUS": "S"},
+			{"AGGREGATE": "Bridge-Aggregation1", "PORTS": "XGE1/0/50", "STATUS": "S"},
+			{"AGGREGATE": "Bridge-Aggregation2", "PORTS": "HGE1/0/53", "STATUS": "S"},
+			{"AGGREGATE": "Bridge-Aggregation2", "PORTS": "HGE1/0/54", "STATUS": "U"},
+			{"AGGREGATE": "Bridge-Aggregation3", "PORTS": "GE1/0/1", "STATUS": "U"},
+		},
+	},
+	{
+		name:    "huawei bgp peers",
+		vendor:  "HUAWEI",
+		command: "display bgp peer",
+		output: ` BGP local router ID : 10.255.0.1
+ Local AS number : 65001
+ Total number of peers : 2                 Peers in established state : 1
+
+  Peer            V          AS  MsgRcvd  MsgSent  OutQ  Up/Down       State  PrefRcv
+  10.0.0.2        4       65002     1234     1240     0 10d02h   Established       12
+  10.0.0.6        4       65003        0        0     0 00:01:10       Idle        0`,
+		want: []map[string]interface{}{
+			{"ROUTER_ID": "10.255.0.1", "LOCAL_AS": "65001", "NEIGHBOR": "10.0.0.2", "AS": "65002", "MSG_RCVD": "1234", "MSG_SENT": "1240", "UPTIME": "10d02h", "STATE": "Established", "PREFIXES": "12"},
+			{"ROUTER_ID": "10.255.0.1", "LOCAL_AS": "65001", "NEIGHBOR": "10.0.0.6", "AS": "65003", "MSG_RCVD": "0", "MSG_SENT": "0", "UPTIME": "00:01:10", "STATE": "Idle", "PREFIXES": "0"},
+		},
+	},
+	{
+		name:    "cisco ip interfaces",
+		vendor:  "CISCO",
+		command: "sh ip int br",
+		output: `Interface              IP-Address      OK? Method Status                Protocol
+Vlan1                  unassigned      YES NVRAM  administratively down down
+Vlan10                 10.0.10.1       YES NVRAM  up                    up
+GigabitEthernet1/0/1   unassigned      YES unset  down                  down`,
+		want: []map[string]interface{}{
+			{"INTERFACE": "Vlan1", "IP_ADDRESS": "unassigned", "STATUS": "administratively down", "PROTOCOL": "down"},
+			{"INTERFACE": "Vlan10", "IP_ADDRESS": "10.0.10.1", "STATUS": "up", "PROTOCOL": "up"},
+			{"INTERFACE": "GigabitEthernet1/0/1", "IP_ADDRESS": "unassigned", "STATUS": "down", "PROTOCOL": "down"},
+		},
+	},
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	index, err := Builtin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range templateSamples {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := index.Parse(c.vendor, c.command, c.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.Maps(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v\nwant %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package nwparse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+/*
+Template is a TextFSM template, which has the Value definitions and the
+states separated by a blank line, for example:
+
+	Value Required INTERFACE (\S+)
+	Value STATUS (up|down)
+
+	Start
+	  ^Interface\s+Status -> Interfaces
+
+	Interfaces
+	  ^${INTERFACE}\s+${STATUS}\s*$$ -> Record
+
+The options of Value are Required, Filldown, Fillup, Key and List. The rule
+actions are 'LineOp.RecordOp NewState' like the original TextFSM, LineOp is
+Next (default), Continue or Error, RecordOp is NoRecord (default), Record,
+Clear or Clearall. The states End and EOF are reserved, the record is saved
+at the end of text unless the state End is reached or an empty EOF state is
+defined.
+
+The regular expressions are of Go (RE2), so lookarounds and backreferences
+of Python are not supported.
+*/
+type Template struct {
+	values []*value
+	states map[string][]*rule
+}
+
+const (
+	optionRequired = "Required"
+	optionFilldown = "Filldown"
+	optionFillup   = "Fillup"
+	optionKey      = "Key"
+	optionList     = "List"
+)
+
+type value struct {
+	name    string
+	regex   string
+	options map[string]bool
+
+	value    string
+	list     []string
+	filldown string // The value kept by Filldown when the record is cleared.
+	fillist  []string
+}
+
+func (v *value) empty() bool {
+	if v.options[optionList] {
+		return len(v.list) == 0
+	}
+	return v.value == ""
+}
+
+func (v *value) assign(s string) {
+	if v.options[optionList] {
+		v.list = append(v.list, s)
+	} else {
+		v.value = s
+	}
+	if v.options[optionFilldown] {
+		v.filldown = v.value
+		v.fillist = v.list
+	}
+}
+
+func (v *value) clear() {
+	v.value, v.list = "", nil
+	if v.options[optionFilldown] {
+		v.value, v.list = v.filldown, v.fillist
+	}
+}
+
+func (v *value) clearAll() {
+	v.value, v.list, v.filldown, v.fillist = "", nil, "", nil
+}
+
+func (v *value) get() interface{} {
+	if v.options[optionList] {
+		return append([]string{}, v.list...)
+	}
+	return v.value
+}
+
+const (
+	lineNext     = "Next"
+	lineContinue = "Continue"
+	lineError    = "Error"
+
+	recordNone     = "NoRecord"
+	recordRecord   = "Record"
+	recordClear    = "Clear"
+	recordClearall = "Clearall"
+
+	stateStart = "Start"
+	stateEnd   = "End"
+	stateEOF   = "EOF"
+)
+
+type rule struct {
+	match    *regexp.Regexp
+	lineOp   string
+	recordOp string
+	newState string
+	message  string // The message of Error.
+	line     int
+}
+
+var (
+	valueName   = regexp.MustCompile(`^\w+$`)
+	stateName   = regexp.MustCompile(`^\w+$`)
+	ruleAction  = regexp.MustCompile(`^(.*\S)\s+->\s*(.*)$`)
+	actionOps   = regexp.MustCompile(`^(?:(Next|Continue|Error)(?:\.(NoRecord|Record|Clear|Clearall))?|(NoRecord|Record|Clear|Clearall))(?:\s+(.+))?$`)
+	placeholder = regexp.MustCompile(`\$(?:\$|\{(\w+)\}|(\w+))`)
+)
+
+// ParseTemplate reads a template, name is used in the error messages.
+func ParseTemplate(name string, r io.Reader) (*Template, error) {
+	t := &Template{states: map[string][]*rule{}}
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	fail := func(format string, a ...interface{}) error {
+		return fmt.Errorf("Invalid template '%s' at line %d. %s", name, lineno, fmt.Sprintf(format, a...))
+	}
+
+	// The values end at the first blank line.
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if line == "" {
+			break
+		}
+		if err := t.parseValue(line); err != nil {
+			return nil, fail("%v", err)
+		}
+	}
+	if len(t.values) == 0 {
+		return nil, fail("No value is defined.")
+	}
+
+	state := ""
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case line[0] != ' ' && line[0] != '\t':
+			if !stateName.MatchString(line) {
+				return nil, fail("Invalid state name '%s'.", line)
+			}
+			if _, ok := t.states[line]; ok {
+				return nil, fail("Duplicate state '%s'.", line)
+			}
+			state = line
+			t.states[state] = nil
+		case state == "":
+			return nil, fail("Rule '%s' is not in any state.", trimmed)
+		default:
+			r, err := t.parseRule(trimmed)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			r.line = lineno
+			t.states[state] = append(t.states[state], r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, t.validate(name)
+}
+
+// parseValue parses 'Value [Options] Name (Regex)'.
+func (t *Template) parseValue(line string) error {
+	if !strings.HasPrefix(line, "Value ") {
+		return fmt.Errorf("Value is expected but got '%s'.", line)
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "Value "))
+	fields := strings.Fields(rest)
+	if len(fields) < 2 {
+		return fmt.Errorf("Invalid value '%s'.", line)
+	}
+	v := &value{options: map[string]bool{}}
+	if !strings.HasPrefix(fields[1], "(") {
+		for _, option := range strings.Split(fields[0], ",") {
+			switch option {
+			case optionRequired, optionFilldown, optionFillup, optionKey, optionList:
+			default:
+				return fmt.Errorf("Unknown option '%s'.", option)
+			}
+			if v.options[option] {
+				return fmt.Errorf("Duplicate option '%s'.", option)
+			}
+			v.options[option] = true
+		}
+		rest = strings.TrimSpace(rest[len(fields[0]):])
+	}
+	name := strings.Fields(rest)[0]
+	v.name, v.regex = name, strings.TrimSpace(rest[len(name):])
+	if !valueName.MatchString(v.name) {
+		return fmt.Errorf("Invalid value name '%s'.", v.name)
+	}
+	if !strings.HasPrefix(v.regex, "(") || !strings.HasSuffix(v.regex, ")") {
+		return fmt.Errorf("The regex of value '%s' should be enclosed in parentheses.", v.name)
+	}
+	if _, err := regexp.Compile(v.regex); err != nil {
+		return fmt.Errorf("Invalid regex of value '%s'. %v", v.name, err)
+	}
+	for _, other := range t.values {
+		if other.name == v.name {
+			return fmt.Errorf("Duplicate value '%s'.", v.name)
+		}
+	}
+	t.values = append(t.values, v)
+	return nil
+}
+
+// parseRule parses '^Regex [-> Action]'.
+func (t *Template) parseRule(line string) (*rule, error) {
+	r := &rule{lineOp: lineNext, recordOp: recordNone}
+	pattern := line
+	if m := ruleAction.FindStringSubmatch(line); m != nil {
+		pattern = m[1]
+		if err := r.parseAction(strings.TrimSpace(m[2])); err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasPrefix(pattern, "^") {
+		return nil, fmt.Errorf("Rule '%s' should start with '^'.", line)
+	}
+	var err error
+	var unknown string
+	expanded := placeholder.ReplaceAllStringFunc(pattern, func(s string) string {
+		if s == "$$" {
+			return "$"
+		}
+		name := strings.Trim(s, "${}")
+		for _, v := range t.values {
+			if v.name == name {
+				return "(?P<" + name + ">" + v.regex[1:]
+			}
+		}
+		unknown = name
+		return s
+	})
+	if unknown != "" {
+		return nil, fmt.Errorf("Unknown value '%s' in rule '%s'.", unknown, line)
+	}
+	if r.match, err = regexp.Compile(expanded); err != nil {
+		return nil, fmt.Errorf("Invalid regex of rule '%s'. %v", line, err)
+	}
+	return r, nil
+}
+
+func (r *rule) parseAction(action string) error {
+	if stateName.MatchString(action) && !actionOps.MatchString(action) {
+		r.newState = action
+		return nil
+	}
+	m := actionOps.FindStringSubmatch(action)
+	if m == nil {
+		return fmt.Errorf("Invalid action '%s'.", action)
+	}
+	if m[1] != "" {
+		r.lineOp = m[1]
+	}
+	if m[2] != "" {
+		r.recordOp = m[2]
+	}
+	if m[3] != "" {
+		r.recordOp = m[3]
+	}
+	switch {
+	case r.lineOp == lineError:
+		r.message = strings.Trim(m[4], `"`)
+	case m[4] != "" && !stateName.MatchString(m[4]):
+		return fmt.Errorf("Invalid state '%s' in action '%s'.", m[4], action)
+	case m[4] != "" && r.lineOp == lineContinue:
+		return fmt.Errorf("Action '%s' can't change state with Continue.", action)
+	default:
+		r.newState = m[4]
+	}
+	return nil
+}
+
+func (t *Template) validate(name string) error {
+	if _, ok := t.states[stateStart]; !ok {
+		return fmt.Errorf("Invalid template '%s'. State 'Start' is not defined.", name)
+	}
+	for _, state := range []string{stateEnd, stateEOF} {
+		if rules, ok := t.states[state]; ok && len(rules) > 0 {
+			return fmt.Errorf("Invalid template '%s'. State '%s' should be empty.", name, state)
+		}
+	}
+	for state, rules := range t.states {
+		for _, r := range rules {
+			if _, ok := t.states[r.newState]; ok || r.newState == "" || r.newState == stateEnd || r.newState == stateEOF {
+				continue
+			}
+			return fmt.Errorf("Invalid template '%s' at line %d. State '%s' of the rule in '%s' is not defined.", name, r.line, r.newState, state)
+		}
+	}
+	return nil
+}
+
+// Header returns the names of the values.
+func (t *Template) Header() []string {
+	var header []string
+	for _, v := range t.values {
+		header = append(header, v.name)
+	}
+	return header
+}
+
+// Result is the records parsed by a template, the values are in the order of
+// Header, the value of a List is a []string, the others are string.
+type Result struct {
+	Header  []string
+	Records [][]interface{}
+}
+
+// Maps returns the records as maps keyed by the value names.
+func (r *Result) Maps() []map[string]interface{} {
+	maps := []map[string]interface{}{}
+	for _, record := range r.Records {
+		m := map[string]interface{}{}
+		for i, name := range r.Header {
+			m[name] = record[i]
+		}
+		maps = append(maps, m)
+	}
+	return maps
+}
+
+// Parse parses text by the template. The template can be used concurrently,
+// each call has its own values.
+func (t *Template) Parse(text string) (*Result, error) {
+	fsm := &fsm{template: t, result: &Result{Header: t.Header()}}
+	for _, v := range t.values {
+		fsm.values = append(fsm.values, &value{name: v.name, regex: v.regex, options: v.options})
+	}
+	return fsm.result, fsm.run(text)
+}
+
+// fsm is the state of a Parse.
+type fsm struct {
+	template *Template
+	values   []*value
+	result   *Result
+}
+
+func (f *fsm) run(text string) error {
+	state := stateStart
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r", ""), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		next, err := f.checkLine(state, line)
+		if err != nil {
+			return err
+		}
+		state = next
+		if state == stateEnd || state == stateEOF {
+			break
+		}
+	}
+	if _, ok := f.template.states[stateEOF]; state != stateEnd && !ok {
+		f.record()
+	}
+	return nil
+}
+
+// checkLine applies the rules of state to line and returns the next state.
+func (f *fsm) checkLine(state, line string) (string, error) {
+	for _, r := range f.template.states[state] {
+		m := r.match.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		for i, name := range r.match.SubexpNames() {
+			if name == "" || m[2*i] < 0 {
+				continue
+			}
+			f.assign(name, line[m[2*i]:m[2*i+1]])
+		}
+		if r.lineOp == lineError {
+			message := r.message
+			if message == "" {
+				message = "State Error raised."
+			}
+			return state, fmt.Errorf("%s Rule line %d, input '%s'.", message, r.line, line)
+		}
+		switch r.recordOp {
+		case recordRecord:
+			f.record()
+		case recordClear:
+			f.clear()
+		case recordClearall:
+			for _, v := range f.values {
+				v.clearAll()
+			}
+		}
+		if r.newState != "" {
+			state = r.newState
+		}
+		if r.lineOp != lineContinue {
+			break
+		}
+	}
+	return state, nil
+}
+
+func (f *fsm) assign(name, s string) {
+	for i, v := range f.values {
+		if v.name != name {
+			continue
+		}
+		v.assign(s)
+		// Fillup copies the value up to the records until a filled one.
+		if v.options[optionFillup] && s != "" {
+			for j := len(f.result.Records) - 1; j >= 0; j-- {
+				if old, ok := f.result.Records[j][i].(string); !ok || old != "" {
+					break
+				}
+				f.result.Records[j][i] = s
+			}
+		}
+	}
+}
+
+// record saves the current record if the Required values are set and not all
+// the values are empty.
+func (f *fsm) record() {
+	empty := true
+	for _, v := range f.values {
+		if v.options[optionRequired] && v.empty() {
+			f.clear()
+			return
+		}
+		if !v.empty() {
+			empty = false
+		}
+	}
+	if empty {
+		return
+	}
+	var record []interface{}
+	for _, v := range f.values {
+		record = append(record, v.get())
+	}
+	f.result.Records = append(f.result.Records, record)
+	f.clear()
+}
+
+func (f *fsm) clear() {
+	for _, v := range f.values {
+		v.clear()
+	}
+}
@@ -0,0 +1,227 @@
+package nwparse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateParse(t *testing.T) {
+	cases := []struct {
+		name, template, text string
+		want                 [][]interface{}
+	}{
+		{
+			// Without Required, the filled down value makes a record at the
+			// end of text like the original TextFSM.
+			name: "filldown",
+			template: `Value Filldown SLOT (\d+)
+Value Required PORT (\S+)
+Value STATUS (UP|DOWN)
+
+Start
+  ^Slot ${SLOT}:
+  ^\s+${PORT}\s+${STATUS} -> Record`,
+			text: `Slot 1:
+  GE1/0/1  UP
+  GE1/0/2  DOWN
+Slot 2:
+  GE2/0/1  UP`,
+			want: [][]interface{}{
+				{"1", "GE1/0/1", "UP"},
+				{"1", "GE1/0/2", "DOWN"},
+				{"2", "GE2/0/1", "UP"},
+			},
+		},
+		{
+			name: "required",
+			template: `Value Required NEIGHBOR (\d+\.\d+\.\d+\.\d+)
+Value STATE (\S+)
+
+Start
+  ^\s*${NEIGHBOR}\s+\d+\s+${STATE}\s*$$ -> Record
+  ^\s*Total number of peers\s*:\s*${STATE} -> Record`,
+			text: `  BGP local router ID : 1.1.1.1
+  Total number of peers : 2
+  10.0.0.2    65002    Established
+  10.0.0.3    65003    Idle`,
+			want: [][]interface{}{
+				{"10.0.0.2", "Established"},
+				{"10.0.0.3", "Idle"},
+			},
+		},
+		{
+			name: "list",
+			template: `Value AGGREGATE (Po\d+)
+Value List PORTS (Eth\S+)
+
+Start
+  ^\d+\s+Po\d+ -> Continue.Record
+  ^\d+\s+${AGGREGATE}\(\S+\) -> Continue
+  ^\d+\s+Po\d+\(\S+\)\s+\S+\s+\S+\s+${PORTS} -> Continue
+  ^\d+\s+Po\d+\(\S+\)\s+\S+\s+\S+\s+\S+\s+${PORTS}
+  ^\s+${PORTS} -> Continue
+  ^\s+\S+\s+${PORTS}`,
+			text: `1     Po1(SU)     Eth      LACP      Eth1/1(P)    Eth1/2(P)
+                                     Eth1/3(P)    Eth1/4(D)
+2     Po2(SD)     Eth      NONE      --`,
+			want: [][]interface{}{
+				{"Po1", []string{"Eth1/1(P)", "Eth1/2(P)", "Eth1/3(P)", "Eth1/4(D)"}},
+				{"Po2", []string{}},
+			},
+		},
+		{
+			name: "continue",
+			template: `Value PORT (\S+)
+Value VLAN (\d+)
+
+Start
+  ^${PORT}\s+${VLAN}\s+ -> Continue.Record
+  ^\S+\s+\d+\s+${PORT}\s+${VLAN} -> Record`,
+			text: `GE1/0/1  10   GE1/0/2  20
+GE1/0/3  30   GE1/0/4  40`,
+			want: [][]interface{}{
+				{"GE1/0/1", "10"},
+				{"GE1/0/2", "20"},
+				{"GE1/0/3", "30"},
+				{"GE1/0/4", "40"},
+			},
+		},
+		{
+			name: "fillup",
+			template: `Value PORT (\S+)
+Value Fillup VLAN (\d+)
+
+Start
+  ^port ${PORT}(\s+vlan ${VLAN})? -> Record`,
+			text: `port GE1/0/1
+port GE1/0/2 vlan 100
+port GE1/0/3`,
+			want: [][]interface{}{
+				{"GE1/0/1", "100"},
+				{"GE1/0/2", "100"},
+				{"GE1/0/3", ""},
+			},
+		},
+		{
+			name: "clear and clearall",
+			template: `Value Filldown DEVICE (\S+)
+Value PORT (\S+)
+
+Start
+  ^device ${DEVICE}
+  ^port ${PORT} -> Record
+  ^skip -> Clear
+  ^reset -> Clearall`,
+			text: `device SW1
+port GE1/0/1
+skip
+port GE1/0/2
+reset
+port GE1/0/3`,
+			want: [][]interface{}{
+				{"SW1", "GE1/0/1"},
+				{"SW1", "GE1/0/2"},
+				{"", "GE1/0/3"},
+			},
+		},
+		{
+			name: "states and end",
+			template: `Value INTERFACE (\S+)
+Value ADDRESS (\S+)
+
+Start
+  ^Interface\s+IP-Address -> Table
+
+Table
+  ^${INTERFACE}\s+${ADDRESS}\s*$$ -> Record
+  ^\s*$$ -> End`,
+			text: `Interface IP-Address
+Vlan10    10.0.10.1
+Vlan20    10.0.20.1
+
+Vlan30    10.0.30.1`,
+			want: [][]interface{}{
+				{"Vlan10", "10.0.10.1"},
+				{"Vlan20", "10.0.20.1"},
+			},
+		},
+		{
+			name: "record at eof",
+			template: `Value HOSTNAME (\S+)
+Value VERSION (\S+)
+
+Start
+  ^sysname ${HOSTNAME}
+  ^version ${VERSION}`,
+			text: "sysname SW1\r\nversion 7.1.070\r\n",
+			want: [][]interface{}{
+				{"SW1", "7.1.070"},
+			},
+		},
+		{
+			name: "empty eof",
+			template: `Value HOSTNAME (\S+)
+
+Start
+  ^sysname ${HOSTNAME}
+
+EOF`,
+			text: "sysname SW1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(c.name, strings.NewReader(c.template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tmpl.Parse(c.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Records, c.want) {
+				t.Errorf("got %q\nwant %q", result.Records, c.want)
+			}
+		})
+	}
+}
+
+func TestTemplateParseError(t *testing.T) {
+	tmpl, err := ParseTemplate("error", strings.NewReader(`Value PORT (\S+)
+
+Start
+  ^% Invalid -> Error "Command rejected."
+  ^${PORT} -> Record`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Parse("GE1/0/1\n% Invalid input detected at '^' marker."); err == nil || !strings.Contains(err.Error(), "Command rejected.") {
+		t.Errorf("error = %v, want 'Command rejected.'", err)
+	}
+}
+
+func TestParseTemplateInvalid(t *testing.T) {
+	cases := []struct {
+		name, template, err string
+	}{
+		{"no value", "\nStart\n  ^x", "No value is defined."},
+		{"unknown option", "Value Sometimes X (\\S+)\n\nStart\n  ^${X}", "Unknown option 'Sometimes'."},
+		{"duplicate value", "Value X (\\S+)\nValue X (\\d+)\n\nStart\n  ^${X}", "Duplicate value 'X'."},
+		{"no parentheses", "Value Required X \\S+\n\nStart\n  ^${X}", "should be enclosed in parentheses"},
+		{"unknown value", "Value X (\\S+)\n\nStart\n  ^${Y}", "Unknown value 'Y'"},
+		{"no caret", "Value X (\\S+)\n\nStart\n  ${X}", "should start with '^'"},
+		{"continue with state", "Value X (\\S+)\n\nStart\n  ^${X} -> Continue.Record Next", "can't change state with Continue"},
+		{"undefined state", "Value X (\\S+)\n\nStart\n  ^${X} -> Table", "State 'Table' of the rule in 'Start' is not defined."},
+		{"no start", "Value X (\\S+)\n\nTable\n  ^${X}", "State 'Start' is not defined."},
+		{"rules in eof", "Value X (\\S+)\n\nStart\n  ^${X}\n\nEOF\n  ^y", "State 'EOF' should be empty."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseTemplate(c.name, strings.NewReader(c.template))
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("error = %v, want %q", err, c.err)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package nwssh
 
 import (
-	"errors"
 	"time"
 )
 
@@ -24,9 +23,13 @@ func (s *NexusSSH) SessionPreparation() bool {
 	return true
 }
 
+func (s *NexusSSH) Enable(password string) error {
+	return s.enable("enable", password)
+}
+
 func (s *NexusSSH) SaveRuningConfig() bool {
 
-	_, err := s.ExecCommandExpectPrompt("copy running-config startup-config", time.Second*20)
+	_, err := s.execCommandExpectPrompt("copy running-config startup-config", time.Second*20)
 
 	if err != nil {
 		return false
@@ -34,8 +37,54 @@ func (s *NexusSSH) SaveRuningConfig() bool {
 	return true
 }
 
+// UserView returns to the exec mode from the config mode or deeper.
+func (s *NexusSSH) UserView() error {
+	return s.userView("end")
+}
+
+func (s *NexusSSH) RunningConfig() (string, error) {
+	return s.runningConfig("show running-config")
+}
+
+// GetFacts collects the facts by 'show version' and 'show inventory', the
+// patch is not collected.
+func (s *NexusSSH) GetFacts() (Facts, error) {
+	facts := s.newFacts("NEXUS")
+	resp, err := s.factsOutput("show version")
+	if err != nil {
+		return facts, err
+	}
+	facts.OSVersion = firstSubmatch(nexusOS, resp)
+	facts.Model = firstSubmatch(nexusModel, resp)
+	facts.Uptime = firstSubmatch(nexusUptime, resp)
+	if resp, err = s.factsOutput("show inventory"); err == nil {
+		facts.Serials = inventorySerials(resp)
+	}
+	facts.MgmtIP = s.mgmtIP("show interface mgmt0 | include Internet")
+	return facts, nil
+}
+
+// Checkpoint creates a checkpoint of the running configuration.
+func (s *NexusSSH) Checkpoint(name string) error {
+	return s.execChecked("checkpoint "+name, time.Second*60)
+}
+
+func (s *NexusSSH) Rollback(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	return s.execChecked("rollback running-config checkpoint "+name, time.Second*300)
+}
+
+func (s *NexusSSH) DeleteCheckpoint(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	return s.execChecked("no checkpoint "+name, time.Second*20)
+}
+
 func (s *NexusSSH) InterfaceConfig() (string, error) {
-	resp, err := s.ExecCommand("show run interface")
+	resp, err := s.execCommand("show run interface")
 	if err != nil {
 		return "", err
 	}
@@ -47,7 +96,7 @@ func (s *NexusSSH) RunTranscation(trans string) (string, error) {
 		return s.InterfaceConfig()
 	}
 
-	return "", errors.New("Unsupport transcation!")
+	return runTransaction(s, "NEXUS", trans)
 }
 
 type CiscoSSH struct {
@@ -69,13 +118,17 @@ func (s *CiscoSSH) SessionPreparation() bool {
 	return true
 }
 
+func (s *CiscoSSH) Enable(password string) error {
+	return s.enable("enable", password)
+}
+
 func (s *CiscoSSH) SaveRuningConfig() bool {
-	_, err := s.ExecCommandExpect("copy running-config startup-config", "]?", time.Second*5)
+	_, err := s.execCommandExpect("copy running-config startup-config", "]?", time.Second*5)
 
 	if err != nil {
 		return false
 	}
-	_, err = s.ExecCommandExpectPrompt("", time.Second*20)
+	_, err = s.execCommandExpectPrompt("", time.Second*20)
 
 	if err != nil {
 		return false
@@ -83,8 +136,55 @@ func (s *CiscoSSH) SaveRuningConfig() bool {
 	return true
 }
 
+// UserView returns to the exec mode from the config mode or deeper.
+func (s *CiscoSSH) UserView() error {
+	return s.userView("end")
+}
+
+func (s *CiscoSSH) RunningConfig() (string, error) {
+	return s.runningConfig("show running-config")
+}
+
+// GetFacts collects the facts by 'show version' and 'show inventory', the
+// patch is not collected and the management address is the host logged in.
+func (s *CiscoSSH) GetFacts() (Facts, error) {
+	facts := s.newFacts("CISCO")
+	resp, err := s.factsOutput("show version")
+	if err != nil {
+		return facts, err
+	}
+	facts.OSVersion = firstSubmatch(ciscoOS, resp)
+	facts.Model = firstSubmatch(ciscoModel, resp)
+	facts.Uptime = firstSubmatch(ciscoUptime, resp)
+	if resp, err = s.factsOutput("show inventory"); err == nil {
+		facts.Serials = inventorySerials(resp)
+	}
+	facts.MgmtIP = s.mgmtIP("")
+	return facts, nil
+}
+
+// Checkpoint copies the running configuration to flash, it's replaced back
+// by 'configure replace' when rolling back.
+func (s *CiscoSSH) Checkpoint(name string) error {
+	return s.execChecked("copy running-config flash:"+name+".cfg", time.Second*60)
+}
+
+func (s *CiscoSSH) Rollback(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	return s.execChecked("configure replace flash:"+name+".cfg force", time.Second*300)
+}
+
+func (s *CiscoSSH) DeleteCheckpoint(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	return s.execChecked("delete /force flash:"+name+".cfg", time.Second*20)
+}
+
 func (s *CiscoSSH) InterfaceConfig() (string, error) {
-	resp, err := s.ExecCommand("show running")
+	resp, err := s.execCommand("show running")
 	if err != nil {
 		return "", err
 	}
@@ -96,5 +196,5 @@ func (s *CiscoSSH) RunTranscation(trans string) (string, error) {
 		return s.InterfaceConfig()
 	}
 
-	return "", errors.New("Unsupport transcation!")
+	return runTransaction(s, "CISCO", trans)
 }
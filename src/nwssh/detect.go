@@ -0,0 +1,298 @@
+package nwssh
+
+import (
+	"encoding/json"
+	"fmt"
+	nwnet "nwnet"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+Detect identifies the vendor of a logged in device in stages, each stage
+scores the vendors by the signatures matched in its text:
+
+	banner   the SSH banner shown before authentication, it's often
+	         customized, so the weights are low.
+	welcome  the text printed after login, such as the copyright of H3C.
+	prompt   the style of the prompt, '<name>' or 'name#'.
+	version  the output of 'display version' or 'show version', it's run
+	         only if the stages above are not confident enough.
+
+The score of a vendor combines the weights of its matched signatures as
+1-(1-w1)(1-w2)..., the confidence is the best score minus the second best
+one, so the evidence shared by vendors (like the prompt style) doesn't make
+the result more confident. Ties are broken by the order of the vendors in
+DetectVendors, the result is always the same for the same texts.
+*/
+
+const (
+	StageBanner  = "banner"
+	StageWelcome = "welcome"
+	StagePrompt  = "prompt"
+	StageVersion = "version"
+)
+
+// DetectVendors are the vendors can be detected, in the order of preference.
+var DetectVendors = []string{"H3C", "HUAWEI", "NEXUS", "CISCO", "RUIJIE"}
+
+// DetectThreshold is the confidence to skip the version stage.
+var DetectThreshold = 0.6
+
+// DetectMinConfidence is the confidence needed to accept a detection.
+var DetectMinConfidence = 0.3
+
+// Signature adds Weight to the score of Vendor if Pattern matches the text of
+// Stage.
+type Signature struct {
+	Vendor  string
+	Stage   string
+	Pattern *regexp.Regexp
+	Weight  float64
+}
+
+// Signatures are used by Detect, append to it to detect the devices of your
+// own.
+var Signatures = []Signature{
+	{"H3C", StageBanner, regexp.MustCompile(`(?i)\bh3c\b`), 0.4},
+	{"HUAWEI", StageBanner, regexp.MustCompile(`(?i)\bhuawei\b`), 0.4},
+	{"NEXUS", StageBanner, regexp.MustCompile(`(?i)\bnexus\b`), 0.4},
+	{"CISCO", StageBanner, regexp.MustCompile(`(?i)\bcisco\b`), 0.3},
+	{"RUIJIE", StageBanner, regexp.MustCompile(`(?i)\bruijie\b`), 0.4},
+
+	{"H3C", StageWelcome, regexp.MustCompile(`(?i)copyright \(c\) [\d-]+ (new )?h3c technologies`), 0.7},
+	{"HUAWEI", StageWelcome, regexp.MustCompile(`Info: The max number of VTY users is`), 0.6},
+	{"HUAWEI", StageWelcome, regexp.MustCompile(`The current login time is`), 0.3},
+	{"HUAWEI", StageWelcome, regexp.MustCompile(`(?i)huawei technologies`), 0.7},
+	{"NEXUS", StageWelcome, regexp.MustCompile(`Cisco Nexus Operating System \(NX-OS\) Software`), 0.8},
+	{"RUIJIE", StageWelcome, regexp.MustCompile(`(?i)ruijie networks`), 0.7},
+
+	{"H3C", StagePrompt, regexp.MustCompile(`^<[^<>]+>$`), 0.2},
+	{"HUAWEI", StagePrompt, regexp.MustCompile(`^(<[^<>]+>|\[~[^\]]+\])$`), 0.2},
+	{"NEXUS", StagePrompt, regexp.MustCompile(`^[^<\[].*[#>]$`), 0.15},
+	{"CISCO", StagePrompt, regexp.MustCompile(`^[^<\[].*[#>]$`), 0.15},
+	{"RUIJIE", StagePrompt, regexp.MustCompile(`^[^<\[].*[#>]$`), 0.15},
+
+	{"H3C", StageVersion, regexp.MustCompile(`(?m)^\s*(H3C Comware (Platform )?Software|Copyright \(c\) .*New H3C Technologies)`), 1},
+	{"HUAWEI", StageVersion, regexp.MustCompile(`(?m)^\s*(Huawei Versatile Routing Platform|VRP \(R\) software)`), 1},
+	{"NEXUS", StageVersion, regexp.MustCompile(`(?m)^\s*(Cisco Nexus Operating System|NXOS: version)`), 1},
+	{"CISCO", StageVersion, regexp.MustCompile(`(?m)^\s*Cisco IOS( XE| XR)? Software`), 1},
+	{"RUIJIE", StageVersion, regexp.MustCompile(`(?m)^\s*System (description|software version)\s*:.*(Ruijie|RGOS)`), 1},
+}
+
+// versionFacts are the patterns to find the model and the OS in the output of
+// the version command, the first submatch is used.
+var versionFacts = map[string]struct{ model, os *regexp.Regexp }{
+	"H3C": {
+		regexp.MustCompile(`(?m)^\s*H3C (\S+) uptime is`),
+		regexp.MustCompile(`(?m)^\s*H3C (Comware (?:Platform )?Software, Version [^\n]+)`),
+	},
+	"HUAWEI": {
+		regexp.MustCompile(`(?mi)^\s*HUAWEI (\S+) (?:Routing Switch )?uptime is`),
+		regexp.MustCompile(`(?m)^\s*(VRP \(R\) software, Version [^\n]+)`),
+	},
+	"NEXUS": {
+		regexp.MustCompile(`(?m)^\s*cisco (Nexus\s?\d+ [^\n]*?)\s*[Cc]hassis`),
+		regexp.MustCompile(`(?m)^\s*(?:NXOS|system):\s+(version [^\n]+)`),
+	},
+	"CISCO": {
+		regexp.MustCompile(`(?m)^\s*[Cc]isco (\S+) \([^)]*\) processor`),
+		regexp.MustCompile(`(?m)^\s*(Cisco IOS[^\n]*Version [^\n,]+)`),
+	},
+	"RUIJIE": {
+		regexp.MustCompile(`(?m)^\s*System description\s*:[^\n]*\((\S+)\)`),
+		regexp.MustCompile(`(?m)^\s*System software version\s*:\s*([^\n]+)`),
+	},
+}
+
+// versionCommands print the lines of the version which are scored, they are
+// filtered to avoid paging. The commands of the prompt style are tried first.
+// The filters have no vendor names, and the echo is stripped before scoring,
+// so the command itself never matches a signature.
+var versionCommands = map[string]string{
+	"display": `display version | include Software|software|Version|Copyright|uptime`,
+	"show":    `show version | include Software|software|Version|version|System|processor|hassis`,
+}
+
+// Detection is the result of Detect, Vendor is empty if nothing matched. The
+// Model and the OS are found in the version output, they are empty if the
+// version stage is not run.
+type Detection struct {
+	Vendor     string    `json:"vendor"`
+	Model      string    `json:"model"`
+	OS         string    `json:"os"`
+	Confidence float64   `json:"confidence"`
+	Stage      string    `json:"stage"` // The last stage run.
+	Time       time.Time `json:"time"`
+	Cached     bool      `json:"-"` // Loaded from DetectCache.
+}
+
+// scores are the products of (1-weight) of the matched signatures by vendor.
+type scores map[string]float64
+
+func (sc scores) match(stage, text string) bool {
+	matched := false
+	for _, sig := range Signatures {
+		if sig.Stage == stage && sig.Pattern.MatchString(text) {
+			if _, ok := sc[sig.Vendor]; !ok {
+				sc[sig.Vendor] = 1
+			}
+			sc[sig.Vendor] *= 1 - sig.Weight
+			matched = true
+		}
+	}
+	return matched
+}
+
+// best returns the vendor with the best score and the confidence.
+func (sc scores) best() (string, float64) {
+	vendor, first, second := "", 0.0, 0.0
+	for _, v := range sc.vendors() {
+		product, ok := sc[v]
+		if !ok {
+			continue
+		}
+		score := 1 - product
+		switch {
+		case score > first:
+			vendor, first, second = v, score, first
+		case score > second:
+			second = score
+		}
+	}
+	return vendor, first - second
+}
+
+// vendors returns the scored vendors, DetectVendors first.
+func (sc scores) vendors() []string {
+	vendors := append([]string{}, DetectVendors...)
+	for v := range sc {
+		if !contains(vendors, v) {
+			vendors = append(vendors, v)
+		}
+	}
+	return vendors
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect detects the vendor of the logged in device, banner is the SSH banner
+// received before authentication.
+func (s *SSHBase) Detect(banner string) Detection {
+	if s.WelecomInfo == "" {
+		time.Sleep(time.Second * 1)
+		s.WelecomInfo = s.readChannel()
+	}
+
+	sc := scores{}
+	sc.match(StageBanner, banner)
+	sc.match(StageWelcome, s.WelecomInfo)
+	s.preparateWriting()
+	prompt := s.Prompt()
+	sc.match(StagePrompt, prompt)
+
+	d := Detection{Stage: StagePrompt, Time: time.Now()}
+	d.Vendor, d.Confidence = sc.best()
+	if d.Confidence >= DetectThreshold {
+		return d
+	}
+
+	order := []string{"show", "display"}
+	if strings.HasPrefix(prompt, "<") || strings.HasPrefix(prompt, "[") {
+		order = []string{"display", "show"}
+	}
+	d.Stage = StageVersion
+	for _, style := range order {
+		cmd := versionCommands[style]
+		resp, err := s.execCommandExpectPrompt(cmd, time.Second*10)
+		if err == nil && sc.version(&d, s.Sanitize(cmd, resp)) {
+			break
+		}
+	}
+	return d
+}
+
+// version scores the output of the version command and updates d, it
+// returns false if no signature matches.
+func (sc scores) version(d *Detection, output string) bool {
+	if !sc.match(StageVersion, output) {
+		return false
+	}
+	d.Vendor, d.Confidence = sc.best()
+	if facts, ok := versionFacts[d.Vendor]; ok {
+		d.Model = firstSubmatch(facts.model, output)
+		d.OS = firstSubmatch(facts.os, output)
+	}
+	return true
+}
+
+func firstSubmatch(r *regexp.Regexp, s string) string {
+	if m := r.FindStringSubmatch(s); len(m) > 1 {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+// DetectCache keeps the detections on disk, one JSON file per host in Dir.
+// The detections older than TTL are ignored, they never expire if TTL is 0.
+type DetectCache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// filename returns the file of host in Dir, host is made filesystem safe
+// like the log files of runner.
+func (c *DetectCache) filename(host string) string {
+	return filepath.Join(c.Dir, nwnet.FileName(host)+".json")
+}
+
+// Load returns the detection of host, ok is false if it's not cached or
+// expired.
+func (c *DetectCache) Load(host string) (d Detection, ok bool) {
+	data, err := os.ReadFile(c.filename(host))
+	if err != nil {
+		return d, false
+	}
+	if err = json.Unmarshal(data, &d); err != nil || d.Vendor == "" {
+		return d, false
+	}
+	if c.TTL > 0 && time.Since(d.Time) > c.TTL {
+		return d, false
+	}
+	d.Cached = true
+	return d, true
+}
+
+// Store saves the detection of host, the file is replaced atomically.
+func (c *DetectCache) Store(host string, d Detection) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, ".detect-*")
+	if err != nil {
+		return fmt.Errorf("Failed to cache the detection of '%s'. %v", host, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Failed to cache the detection of '%s'. %v", host, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("Failed to cache the detection of '%s'. %v", host, err)
+	}
+	if err = os.Rename(tmp.Name(), c.filename(host)); err != nil {
+		return fmt.Errorf("Failed to cache the detection of '%s'. %v", host, err)
+	}
+	return nil
+}
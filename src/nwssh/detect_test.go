@@ -0,0 +1,24 @@
+package nwssh
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectCacheFilename(t *testing.T) {
+	c := &DetectCache{Dir: "/var/cache/swssh"}
+	cases := map[string]string{
+		"10.10.10.10":         "10.10.10.10.json",
+		"2001:db8::1":         "2001_db8__1.json",
+		"sw1.bj.example.com":  "sw1.bj.example.com.json",
+		"../../etc/passwd":    ".._.._etc_passwd.json",
+		"sw1/ge1 0 1\\x":      "sw1_ge1_0_1_x.json",
+		"BJ_YF_305-A-15_CE58": "BJ_YF_305-A-15_CE58.json",
+	}
+	for host, want := range cases {
+		got := c.filename(host)
+		if filepath.Dir(got) != c.Dir || filepath.Base(got) != want {
+			t.Errorf("filename(%q) = %q, want %q in %s", host, got, want, c.Dir)
+		}
+	}
+}
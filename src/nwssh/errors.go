@@ -0,0 +1,113 @@
+package nwssh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// CommandRejected is returned by ExecCommand* when the output of the command
+// matches an error pattern of the vendor, Message is the matched lines.
+type CommandRejected struct {
+	Command string
+	Message string
+}
+
+func (e *CommandRejected) Error() string {
+	return fmt.Sprintf("Command '%s' is rejected by device: %s", e.Command, e.Message)
+}
+
+// errorPatterns are the patterns of the messages printed by the devices when
+// a command is rejected, keyed by vendor. Each pattern is matched against the
+// lines of output, use AddErrorPattern to add the patterns of your own.
+var errorPatterns = map[string][]*regexp.Regexp{
+	"H3C": compilePatterns(
+		`^\s*% (Unrecognized|Incomplete|Ambiguous) command found at '\^' position\.`,
+		`^\s*% (Too many parameters|Wrong parameter) found at '\^' position\.`,
+	),
+	"HUAWEI": compilePatterns(
+		`^\s*Error: `,
+		`^\s*% (Unrecognized|Incomplete|Ambiguous) command`,
+	),
+	"NEXUS": compilePatterns(
+		`^\s*% (Invalid command|Invalid parameter detected|Invalid number) at '\^' marker\.`,
+		`^\s*% Incomplete command`,
+		`^\s*ERROR: `,
+		`^\s*Syntax error while parsing `,
+	),
+	"CISCO": compilePatterns(
+		`^\s*% Invalid input detected at '\^' marker\.`,
+		`^\s*% (Incomplete|Ambiguous|Unknown) command`,
+	),
+	"RUIJIE": compilePatterns(
+		`^\s*% Invalid input detected at '\^' marker\.`,
+		`^\s*% (Incomplete|Ambiguous|Unknown) command`,
+	),
+}
+
+var errorPatternsLock sync.RWMutex
+
+func compilePatterns(patterns ...string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+	for _, p := range patterns {
+		compiled = append(compiled, regexp.MustCompile(p))
+	}
+	return compiled
+}
+
+// AddErrorPattern adds pattern to vendor, or all the vendors if vendor is
+// empty. The sessions already created keep their patterns.
+func AddErrorPattern(vendor, pattern string) error {
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("Invalid error pattern '%s'. %v", pattern, err)
+	}
+	errorPatternsLock.Lock()
+	defer errorPatternsLock.Unlock()
+	vendor = strings.ToUpper(vendor)
+	if vendor != "" {
+		errorPatterns[vendor] = append(errorPatterns[vendor], r)
+		return nil
+	}
+	for v := range errorPatterns {
+		errorPatterns[v] = append(errorPatterns[v], r)
+	}
+	return nil
+}
+
+// ErrorPatterns returns a copy of the error patterns of vendor.
+func ErrorPatterns(vendor string) []*regexp.Regexp {
+	errorPatternsLock.RLock()
+	defer errorPatternsLock.RUnlock()
+	return append([]*regexp.Regexp(nil), errorPatterns[strings.ToUpper(vendor)]...)
+}
+
+// SetErrorPatterns sets the patterns of the session checked by ExecCommand*,
+// nil disables the check.
+func (s *SSHBase) SetErrorPatterns(patterns []*regexp.Regexp) {
+	s.errorpatterns = patterns
+}
+
+// CheckRejected returns CommandRejected if resp, the output of cmd, matches
+// any error pattern. It's called by ExecCommand*, the commands run by the
+// drivers themselves, which are rejected by some devices on purpose, are not
+// checked.
+func (s *SSHBase) CheckRejected(cmd, resp string) error {
+	if len(s.errorpatterns) == 0 {
+		return nil
+	}
+	var matched []string
+	for _, line := range strings.Split(resp, "\n") {
+		for _, p := range s.errorpatterns {
+			if p.MatchString(line) {
+				matched = append(matched, strings.TrimSpace(line))
+				break
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	return &CommandRejected{Command: cmd, Message: strings.Join(matched, " ")}
+}
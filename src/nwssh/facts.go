@@ -0,0 +1,156 @@
+package nwssh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Facts are the basic facts of a device collected by GetFacts, the fields
+// not found in the output are empty. Host is the address logged in, it's
+// also the MgmtIP if the management interface has no address.
+type Facts struct {
+	Host      string   `json:"host"`
+	Hostname  string   `json:"hostname"`
+	Vendor    string   `json:"vendor"`
+	Model     string   `json:"model"`
+	OSVersion string   `json:"os_version"`
+	Patch     string   `json:"patch"`
+	Serials   []Serial `json:"serials"`
+	Uptime    string   `json:"uptime"`
+	MgmtIP    string   `json:"mgmt_ip"`
+}
+
+// Serial is the serial number of a member of the device, such as a slot of a
+// chassis or a switch of a stack.
+type Serial struct {
+	Member string `json:"member"`
+	Number string `json:"number"`
+}
+
+// SerialString returns the serials like '1:SN1;2:SN2'.
+func (f *Facts) SerialString() string {
+	var serials []string
+	for _, s := range f.Serials {
+		serials = append(serials, s.Member+":"+s.Number)
+	}
+	return strings.Join(serials, ";")
+}
+
+var mgmtAddress = regexp.MustCompile(`Internet [Aa]ddress(?: is|:)\s*(\d+\.\d+\.\d+\.\d+)`)
+
+// newFacts returns the facts known without running any command.
+func (s *SSHBase) newFacts(vendor string) Facts {
+	return Facts{Host: s.host, Hostname: promptHostname(s.Prompt()), Vendor: vendor}
+}
+
+// factsOutput runs cmd and returns the output without the command line and
+// the prompt.
+func (s *SSHBase) factsOutput(cmd string) (string, error) {
+	resp, err := s.execCommandExpectPrompt(cmd, time.Second*20)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get facts by '%s'. %v", cmd, err)
+	}
+	return s.Sanitize(cmd, resp), nil
+}
+
+// mgmtIP returns the address of the management interface printed by cmd,
+// it's the host logged in if nothing found.
+func (s *SSHBase) mgmtIP(cmd string) string {
+	if cmd != "" {
+		if resp, err := s.factsOutput(cmd); err == nil {
+			if ip := firstSubmatch(mgmtAddress, resp); ip != "" {
+				return ip
+			}
+		}
+	}
+	return s.host
+}
+
+// noPatch clears the values meaning no patch installed.
+func noPatch(patch string) string {
+	switch strings.ToLower(patch) {
+	case "none", "na", "n/a", "-":
+		return ""
+	}
+	return patch
+}
+
+// sectionSerials returns the serials of the sections like:
+//
+//	Slot 1 CPU 0:
+//	DEVICE_SERIAL_NUMBER : 210235A1JTH123000001
+//
+// header matches the first line of the members, the first submatch is the
+// member. The serials of the other sections (such as the fans) are ignored.
+func sectionSerials(resp string, header, serial *regexp.Regexp) []Serial {
+	var serials []Serial
+	member, found := "", false
+	for _, line := range strings.Split(resp, "\n") {
+		if m := header.FindStringSubmatch(line); m != nil {
+			member, found = m[1], false
+			continue
+		}
+		if strings.HasSuffix(strings.TrimSpace(line), ":") {
+			member = ""
+			continue
+		}
+		if m := serial.FindStringSubmatch(line); m != nil && member != "" && !found {
+			serials = append(serials, Serial{Member: member, Number: m[1]})
+			found = true
+		}
+	}
+	return serials
+}
+
+var (
+	inventoryName   = regexp.MustCompile(`^\s*NAME:\s*"([^"]*)"`)
+	inventorySerial = regexp.MustCompile(`SN:\s*(\S+)`)
+	inventoryMember = regexp.MustCompile(`^(Chassis|Switch \d+|\d+)$`)
+)
+
+// inventorySerials returns the serials of the chassis and the stack members
+// in the output of 'show inventory'.
+func inventorySerials(resp string) []Serial {
+	var serials []Serial
+	name := ""
+	for _, line := range strings.Split(resp, "\n") {
+		if m := inventoryName.FindStringSubmatch(line); m != nil {
+			name = m[1]
+			continue
+		}
+		if m := inventorySerial.FindStringSubmatch(line); m != nil && inventoryMember.MatchString(name) {
+			serials = append(serials, Serial{Member: name, Number: m[1]})
+			name = ""
+		}
+	}
+	return serials
+}
+
+var (
+	h3cOSVersion = regexp.MustCompile(`Comware (?:Platform )?Software, Version ([^\n]+)`)
+	h3cModel     = regexp.MustCompile(`(?m)^\s*H3C (\S+) uptime is`)
+	h3cUptime    = regexp.MustCompile(`(?m)^\s*H3C \S+ uptime is ([^\n]+)`)
+	h3cPatch     = regexp.MustCompile(`(?m)Master {2,}\d+ {2,}\S+ ?\S* {2,}(\S+(?: \S+)?)[ \t]*$`)
+	h3cSlot      = regexp.MustCompile(`^\s*(Slot \d+)(?: CPU \d+)?:\s*$`)
+	h3cSerial    = regexp.MustCompile(`^\s*DEVICE_SERIAL_NUMBER\s*:\s*(\S+)`)
+	huaweiOS     = regexp.MustCompile(`VRP \(R\) software, Version ([^\n]+)`)
+	huaweiModel  = regexp.MustCompile(`(?mi)^\s*HUAWEI (\S+)(?: Routing Switch)? uptime is`)
+	huaweiUptime = regexp.MustCompile(`(?mi)^\s*HUAWEI \S+(?: Routing Switch)? uptime is ([^\n]+)`)
+	huaweiPatch  = regexp.MustCompile(`Patch [Pp]ackage [Vv]ersion\s*:\s*(\S+)`)
+	huaweiSerial = regexp.MustCompile(`(?m)^\s*ESN of ([^:]+):\s*(\S+)`)
+	nexusOS      = regexp.MustCompile(`(?m)^\s*(?:NXOS|system):\s+version\s+(\S+)`)
+	nexusModel   = regexp.MustCompile(`(?mi)^\s*cisco (Nexus.*?)\s+(?:\(.*\)\s+)?chassis`)
+	nexusUptime  = regexp.MustCompile(`(?m)^\s*Kernel uptime is ([^\n]+)`)
+	ciscoOS      = regexp.MustCompile(`Cisco IOS[^\n]*?, Version ([^\s,]+)`)
+	ciscoModel   = regexp.MustCompile(`(?mi)^\s*cisco (\S+) \([^)]*\) processor`)
+	ciscoUptime  = regexp.MustCompile(`(?m)^\S+ uptime is ([^\n]+)`)
+	ruijieOS     = regexp.MustCompile(`System software version\s*:\s*([^\n]+)`)
+	ruijieModel  = regexp.MustCompile(`System description\s*:[^\n]*\((\S+)\)`)
+	ruijieUptime = regexp.MustCompile(`System uptime\s*:\s*([^\n]+)`)
+	ruijiePatch  = regexp.MustCompile(`System patch number\s*:\s*(\S+)`)
+	ruijieDevice = regexp.MustCompile(`^\s*(Device-\d+)\s*:\s*$`)
+	ruijieSerial = regexp.MustCompile(`^\s*(?:System )?[Ss]erial number\s*:\s*(\S+)`)
+	ruijieSystem = regexp.MustCompile(`System serial number\s*:\s*(\S+)`)
+)
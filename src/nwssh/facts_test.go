@@ -0,0 +1,216 @@
+package nwssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetFacts(t *testing.T) {
+	cases := []struct {
+		name, prompt string
+		driver       func(s *SSHBase) SSHBASE
+		replies      map[string]string
+		want         Facts
+	}{
+		{
+			name:   "h3c",
+			prompt: "<BJ-S6850>",
+			driver: func(s *SSHBase) SSHBASE { return &H3cSSH{s} },
+			replies: map[string]string{
+				"display version": `H3C Comware Software, Version 7.1.070, Release 6616P01
+Copyright (c) 2004-2022 New H3C Technologies Co., Ltd. All rights reserved.
+H3C S6850-56HF uptime is 0 weeks, 3 days, 4 hours, 11 minutes
+Last reboot reason : Cold reboot
+
+Boot image: flash:/S6850-CMW710-BOOT-R6616P01.bin`,
+				"display device": `Slot Type             State    Subslot  Soft Ver             Patch Ver
+1    S6850-56HF       Master   0        S6850-6616P01        6616P01H03
+2    S6850-56HF       Standby  0        S6850-6616P01        6616P01H03`,
+				"display device manuinfo": `Slot 1 CPU 0:
+DEVICE_NAME          : S6850-56HF
+DEVICE_SERIAL_NUMBER : 210235A2CSH201000017
+MAC_ADDRESS          : 3C8C-4000-0001
+Fan 1:
+DEVICE_SERIAL_NUMBER : 210231A1NEF194000331
+Slot 2 CPU 0:
+DEVICE_NAME          : S6850-56HF
+DEVICE_SERIAL_NUMBER : 210235A2CSH201000018`,
+				"display interface M-GigabitEthernet0/0/0 | include Internet": "Internet Address: 192.168.0.10/24 Primary",
+			},
+			want: Facts{
+				Host: "10.0.0.1", Hostname: "BJ-S6850", Vendor: "H3C",
+				Model: "S6850-56HF", OSVersion: "7.1.070, Release 6616P01", Patch: "6616P01H03",
+				Serials: []Serial{{"Slot 1", "210235A2CSH201000017"}, {"Slot 2", "210235A2CSH201000018"}},
+				Uptime:  "0 weeks, 3 days, 4 hours, 11 minutes",
+				MgmtIP:  "192.168.0.10",
+			},
+		},
+		{
+			name:   "huawei",
+			prompt: "<SH-S5720>",
+			driver: func(s *SSHBase) SSHBASE { return &HuaweiSSH{s} },
+			replies: map[string]string{
+				"display version": `Huawei Versatile Routing Platform Software
+VRP (R) software, Version 5.170 (S5720 V200R019C10SPC500)
+Copyright (C) 2000-2020 HUAWEI TECH Co., Ltd.
+HUAWEI S5720-52X-SI-AC Routing Switch uptime is 12 weeks, 1 day, 3 hours, 20 minutes`,
+				"display patch-information": `Patch Package Name    :flash:/S5720-V200R019SPH008.pat
+Patch Package Version :V200R019SPH008
+Patch Package State   :Running`,
+				"display esn": `ESN of slot 0: 2102350DLR10J1000123
+ESN of slot 1: 2102350DLR10J1000124`,
+				"display interface MEth0/0/0 | include Internet Address": "Internet Address is 10.1.1.2/24",
+			},
+			want: Facts{
+				Host: "10.0.0.1", Hostname: "SH-S5720", Vendor: "HUAWEI",
+				Model: "S5720-52X-SI-AC", OSVersion: "5.170 (S5720 V200R019C10SPC500)", Patch: "V200R019SPH008",
+				Serials: []Serial{{"slot 0", "2102350DLR10J1000123"}, {"slot 1", "2102350DLR10J1000124"}},
+				Uptime:  "12 weeks, 1 day, 3 hours, 20 minutes",
+				MgmtIP:  "10.1.1.2",
+			},
+		},
+		{
+			name:   "nexus",
+			prompt: "N9K-LEAF1#",
+			driver: func(s *SSHBase) SSHBASE { return &NexusSSH{s} },
+			replies: map[string]string{
+				"show version": `Cisco Nexus Operating System (NX-OS) Software
+TAC support: http://www.cisco.com/tac
+
+Software
+  BIOS: version 05.44
+  NXOS: version 9.3(8)
+  BIOS compile time:  09/02/2021
+
+Hardware
+  cisco Nexus9000 C93180YC-EX chassis
+  Intel(R) Xeon(R) CPU  @ 1.80GHz with 24569356 kB of memory.
+
+Kernel uptime is 120 day(s), 3 hour(s), 12 minute(s), 40 second(s)`,
+				"show inventory": `NAME: "Chassis",  DESCR: "Nexus9000 C93180YC-EX chassis"
+PID: N9K-C93180YC-EX     ,  VID: V03 ,  SN: FDO21120U8N
+
+NAME: "Slot 1",  DESCR: "48x10/25G + 6x40/100G Ethernet Module"
+PID: N9K-C93180YC-EX     ,  VID: V03 ,  SN: FDO21120U8N
+
+NAME: "Fan 1",  DESCR: "Fan Module"
+PID: NXA-FAN-30CFM-B     ,  VID: V01 ,  SN: N/A`,
+				"show interface mgmt0 | include Internet": "  Internet Address is 10.0.0.5/24",
+			},
+			want: Facts{
+				Host: "10.0.0.1", Hostname: "N9K-LEAF1", Vendor: "NEXUS",
+				Model: "Nexus9000 C93180YC-EX", OSVersion: "9.3(8)",
+				Serials: []Serial{{"Chassis", "FDO21120U8N"}},
+				Uptime:  "120 day(s), 3 hour(s), 12 minute(s), 40 second(s)",
+				MgmtIP:  "10.0.0.5",
+			},
+		},
+		{
+			name:   "cisco stack",
+			prompt: "SW1#",
+			driver: func(s *SSHBase) SSHBASE { return &CiscoSSH{s} },
+			replies: map[string]string{
+				"show version": `Cisco IOS Software, C3750E Software (C3750E-UNIVERSALK9-M), Version 15.2(4)E10, RELEASE SOFTWARE (fc2)
+Technical Support: http://www.cisco.com/techsupport
+ROM: Bootstrap program is C3750E boot loader
+SW1 uptime is 1 year, 2 weeks, 3 days, 4 hours, 5 minutes
+System returned to ROM by power-on
+cisco WS-C3750X-48P (PowerPC405) processor (revision W0) with 262144K bytes of memory.`,
+				"show inventory": `NAME: "1", DESCR: "WS-C3750X-48P"
+PID: WS-C3750X-48P-S   , VID: V05  , SN: FDO1634X1AB
+
+NAME: "Switch 1 - Power Supply 0", DESCR: "FRU Power Supply"
+PID: C3KX-PWR-715WAC   , VID: V01  , SN: LIT16300ABC
+
+NAME: "2", DESCR: "WS-C3750X-48P"
+PID: WS-C3750X-48P-S   , VID: V05  , SN: FDO1634X2CD`,
+			},
+			want: Facts{
+				Host: "10.0.0.1", Hostname: "SW1", Vendor: "CISCO",
+				Model: "WS-C3750X-48P", OSVersion: "15.2(4)E10",
+				Serials: []Serial{{"1", "FDO1634X1AB"}, {"2", "FDO1634X2CD"}},
+				Uptime:  "1 year, 2 weeks, 3 days, 4 hours, 5 minutes",
+				MgmtIP:  "10.0.0.1",
+			},
+		},
+		{
+			name:   "ruijie",
+			prompt: "RJ-S6000#",
+			driver: func(s *SSHBase) SSHBASE { return &RuijieSSH{s} },
+			replies: map[string]string{
+				"show version": `System description      : Ruijie Full 10G Routing Switch(S6000C-48GT4XS-E) By Ruijie Networks
+System start time       : 2026-01-10 09:12:44
+System uptime           : 281:05:10:11
+System hardware version : 1.00
+System software version : S6000C_RGOS 11.4(1)B12P22
+System patch number     : NA
+System serial number    : G1NT1234567
+System boot version     : 1.2.18`,
+			},
+			want: Facts{
+				Host: "10.0.0.1", Hostname: "RJ-S6000", Vendor: "RUIJIE",
+				Model: "S6000C-48GT4XS-E", OSVersion: "S6000C_RGOS 11.4(1)B12P22",
+				Serials: []Serial{{"1", "G1NT1234567"}},
+				Uptime:  "281:05:10:11",
+				MgmtIP:  "10.0.0.1",
+			},
+		},
+		{
+			name:   "ruijie vsu",
+			prompt: "RJ-VSU#",
+			driver: func(s *SSHBase) SSHBASE { return &RuijieSSH{s} },
+			replies: map[string]string{
+				"show version": `System description      : Ruijie 10G Ethernet Switch(S5750C-48GT4XS-H) By Ruijie Networks
+System uptime           : 10:01:02:03
+System software version : S5750C_RGOS 11.0(5)B9P59
+System patch number     : 11.0(5)B9P59H2
+System serial number    : G1PD10T000111
+Device information:
+  Device-1:
+    Hardware version    : 1.10
+    Serial number       : G1PD10T000111
+  Device-2:
+    Hardware version    : 1.10
+    Serial number       : G1PD10T000222`,
+			},
+			want: Facts{
+				Host: "10.0.0.1", Hostname: "RJ-VSU", Vendor: "RUIJIE",
+				Model: "S5750C-48GT4XS-H", OSVersion: "S5750C_RGOS 11.0(5)B9P59", Patch: "11.0(5)B9P59H2",
+				Serials: []Serial{{"Device-1", "G1PD10T000111"}, {"Device-2", "G1PD10T000222"}},
+				Uptime:  "10:01:02:03",
+				MgmtIP:  "10.0.0.1",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			respchan := make(chan string, 1)
+			s := &SSHBase{
+				host:      "10.0.0.1",
+				prompt:    c.prompt,
+				respchan:  respchan,
+				InChannel: &scriptedChannel{respchan: respchan, replies: c.replies, prompt: c.prompt},
+			}
+			got, err := c.driver(s).GetFacts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v\nwant %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestH3cPatch(t *testing.T) {
+	cases := map[string]string{
+		"Slot Type             State    Subslot  Soft Ver             Patch Ver\n1    S6850-56HF       Master   0        S6850-6616P01        None":                          "",
+		"Slot Type             State    Subslot  Soft Ver             Patch Ver\n1    S6850-56HF       Master   0        S6850-6616P01        6616P01H03  \n2    S6850-56HF": "6616P01H03",
+		"Slot No. Brd Type        Brd Status   Subslot Num    Sft Ver         Patch Ver\n1        S5560-30F       Master       0              S5560-7548P01   7548P01H05":    "7548P01H05",
+	}
+	for resp, want := range cases {
+		if got := noPatch(firstSubmatch(h3cPatch, resp)); got != want {
+			t.Errorf("got %q, want %q in\n%s", got, want, resp)
+		}
+	}
+}
@@ -2,6 +2,11 @@ module nwssh
 
 go 1.19
 
-require golang.org/x/crypto v0.5.0
+require (
+	golang.org/x/crypto v0.5.0
+	nwnet v0.0.0
+)
 
 require golang.org/x/sys v0.4.0 // indirect
+
+replace nwnet v0.0.0 => ../nwnet
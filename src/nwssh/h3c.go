@@ -1,7 +1,7 @@
 package nwssh
 
 import (
-	"errors"
+	"strings"
 	"time"
 )
 
@@ -24,16 +24,85 @@ func (s *H3cSSH) SessionPreparation() bool {
 	return true
 }
 
+func (s *H3cSSH) Enable(password string) error {
+	return s.enable("super", password)
+}
+
 func (s *H3cSSH) SaveRuningConfig() bool {
-	_, err := s.ExecCommandExpectPrompt("save force", time.Second*20)
+	_, err := s.execCommandExpectPrompt("save force", time.Second*20)
 	if err != nil {
 		return false
 	}
 	return true
 }
 
+// UserView returns to the user view from the system view or deeper.
+func (s *H3cSSH) UserView() error {
+	return s.userView("return")
+}
+
+func (s *H3cSSH) RunningConfig() (string, error) {
+	return s.runningConfig("display current-configuration")
+}
+
+// GetFacts collects the facts by 'display version', the patch of the master
+// in 'display device' and the serials in 'display device manuinfo'.
+func (s *H3cSSH) GetFacts() (Facts, error) {
+	facts := s.newFacts("H3C")
+	resp, err := s.factsOutput("display version")
+	if err != nil {
+		return facts, err
+	}
+	facts.OSVersion = firstSubmatch(h3cOSVersion, resp)
+	facts.Model = firstSubmatch(h3cModel, resp)
+	facts.Uptime = firstSubmatch(h3cUptime, resp)
+	if resp, err = s.factsOutput("display device"); err == nil {
+		facts.Patch = noPatch(firstSubmatch(h3cPatch, resp))
+	}
+	if resp, err = s.factsOutput("display device manuinfo"); err == nil {
+		facts.Serials = sectionSerials(resp, h3cSlot, h3cSerial)
+	}
+	facts.MgmtIP = s.mgmtIP("display interface M-GigabitEthernet0/0/0 | include Internet")
+	return facts, nil
+}
+
+// Checkpoint saves the running configuration to a file, it's replaced back
+// by 'configuration replace' when rolling back.
+func (s *H3cSSH) Checkpoint(name string) error {
+	return s.execChecked("save flash:/"+name+".cfg", time.Second*60)
+}
+
+func (s *H3cSSH) Rollback(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	if _, err := s.execCommandExpectPrompt("system-view", time.Second*5); err != nil {
+		return err
+	}
+	defer s.execCommandExpectPrompt("return", time.Second*5)
+
+	// Don't save the configuration to be discarded.
+	resp, err := s.execAnswer("configuration replace file flash:/"+name+".cfg", func(question string) string {
+		if strings.Contains(strings.ToLower(question), "save") {
+			return "N"
+		}
+		return answerYes(question)
+	}, time.Second*300)
+	if err != nil {
+		return err
+	}
+	return checkOutput("configuration replace", resp)
+}
+
+func (s *H3cSSH) DeleteCheckpoint(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	return s.execChecked("delete /unreserved flash:/"+name+".cfg", time.Second*20)
+}
+
 func (s *H3cSSH) InterfaceConfig() (string, error) {
-	resp, err := s.ExecCommand("display cu interface")
+	resp, err := s.execCommand("display cu interface")
 	if err != nil {
 		return "", err
 	}
@@ -45,5 +114,5 @@ func (s *H3cSSH) RunTranscation(trans string) (string, error) {
 		return s.InterfaceConfig()
 	}
 
-	return "", errors.New("Unsupport transcation!")
+	return runTransaction(s, "H3C", trans)
 }
@@ -1,7 +1,7 @@
 package nwssh
 
 import (
-	"errors"
+	"strings"
 	"time"
 )
 
@@ -24,13 +24,17 @@ func (s *HuaweiSSH) SessionPreparation() bool {
 	return true
 }
 
+func (s *HuaweiSSH) Enable(password string) error {
+	return s.enable("super", password)
+}
+
 func (s *HuaweiSSH) SaveRuningConfig() bool {
-	_, err := s.ExecCommandExpect("save", "[Y/N]:", time.Second*5)
+	_, err := s.execCommandExpect("save", "[Y/N]:", time.Second*5)
 
 	if err != nil {
 		return false
 	}
-	_, err = s.ExecCommandExpectPrompt("y", time.Second*20)
+	_, err = s.execCommandExpectPrompt("y", time.Second*20)
 
 	if err != nil {
 		return false
@@ -38,8 +42,60 @@ func (s *HuaweiSSH) SaveRuningConfig() bool {
 	return true
 }
 
+// UserView returns to the user view from the system view or deeper.
+func (s *HuaweiSSH) UserView() error {
+	return s.userView("return")
+}
+
+func (s *HuaweiSSH) RunningConfig() (string, error) {
+	return s.runningConfig("display current-configuration")
+}
+
+// GetFacts collects the facts by 'display version', 'display patch-information'
+// and 'display esn'.
+func (s *HuaweiSSH) GetFacts() (Facts, error) {
+	facts := s.newFacts("HUAWEI")
+	resp, err := s.factsOutput("display version")
+	if err != nil {
+		return facts, err
+	}
+	facts.OSVersion = firstSubmatch(huaweiOS, resp)
+	facts.Model = firstSubmatch(huaweiModel, resp)
+	facts.Uptime = firstSubmatch(huaweiUptime, resp)
+	if resp, err = s.factsOutput("display patch-information"); err == nil {
+		facts.Patch = noPatch(firstSubmatch(huaweiPatch, resp))
+	}
+	if resp, err = s.factsOutput("display esn"); err == nil {
+		for _, m := range huaweiSerial.FindAllStringSubmatch(resp, -1) {
+			facts.Serials = append(facts.Serials, Serial{Member: strings.TrimSpace(m[1]), Number: m[2]})
+		}
+	}
+	facts.MgmtIP = s.mgmtIP("display interface MEth0/0/0 | include Internet Address")
+	return facts, nil
+}
+
+// Checkpoint saves the running configuration to a file, it's rolled back by
+// 'rollback configuration to file'.
+func (s *HuaweiSSH) Checkpoint(name string) error {
+	return s.execChecked("save "+name+".cfg", time.Second*60)
+}
+
+func (s *HuaweiSSH) Rollback(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	return s.execChecked("rollback configuration to file "+name+".cfg", time.Second*300)
+}
+
+func (s *HuaweiSSH) DeleteCheckpoint(name string) error {
+	if err := s.UserView(); err != nil {
+		return err
+	}
+	return s.execChecked("delete /unreserved "+name+".cfg", time.Second*20)
+}
+
 func (s *HuaweiSSH) InterfaceConfig() (string, error) {
-	resp, err := s.ExecCommand("display cu interface")
+	resp, err := s.execCommand("display cu interface")
 	if err != nil {
 		return "", err
 	}
@@ -51,5 +107,5 @@ func (s *HuaweiSSH) RunTranscation(trans string) (string, error) {
 		return s.InterfaceConfig()
 	}
 
-	return "", errors.New("Unsupport transcation!")
+	return runTransaction(s, "HUAWEI", trans)
 }
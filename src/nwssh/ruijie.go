@@ -1,7 +1,6 @@
 package nwssh
 
 import (
-	"errors"
 	"log"
 	"time"
 )
@@ -25,9 +24,13 @@ func (s *RuijieSSH) SessionPreparation() bool {
 	return true
 }
 
+func (s *RuijieSSH) Enable(password string) error {
+	return s.enable("enable", password)
+}
+
 func (s *RuijieSSH) SaveRuningConfig() bool {
 
-	_, err := s.ExecCommandExpectPrompt("copy running-config startup-config", time.Second*20)
+	_, err := s.execCommandExpectPrompt("copy running-config startup-config", time.Second*20)
 
 	if err != nil {
 		log.Fatalf("%v", err)
@@ -36,8 +39,38 @@ func (s *RuijieSSH) SaveRuningConfig() bool {
 	return true
 }
 
+// UserView returns to the exec mode from the config mode or deeper.
+func (s *RuijieSSH) UserView() error {
+	return s.userView("end")
+}
+
+func (s *RuijieSSH) RunningConfig() (string, error) {
+	return s.runningConfig("show running-config")
+}
+
+// GetFacts collects the facts by 'show version', the serials are of the
+// devices of VSU if any, otherwise the system serial number. The management
+// address is the host logged in.
+func (s *RuijieSSH) GetFacts() (Facts, error) {
+	facts := s.newFacts("RUIJIE")
+	resp, err := s.factsOutput("show version")
+	if err != nil {
+		return facts, err
+	}
+	facts.OSVersion = firstSubmatch(ruijieOS, resp)
+	facts.Model = firstSubmatch(ruijieModel, resp)
+	facts.Uptime = firstSubmatch(ruijieUptime, resp)
+	facts.Patch = noPatch(firstSubmatch(ruijiePatch, resp))
+	facts.Serials = sectionSerials(resp, ruijieDevice, ruijieSerial)
+	if serial := firstSubmatch(ruijieSystem, resp); len(facts.Serials) == 0 && serial != "" {
+		facts.Serials = []Serial{{Member: "1", Number: serial}}
+	}
+	facts.MgmtIP = s.mgmtIP("")
+	return facts, nil
+}
+
 func (s *RuijieSSH) InterfaceConfig() (string, error) {
-	resp, err := s.ExecCommand("show running")
+	resp, err := s.execCommand("show running")
 	if err != nil {
 		return "", err
 	}
@@ -49,5 +82,5 @@ func (s *RuijieSSH) RunTranscation(trans string) (string, error) {
 		return s.InterfaceConfig()
 	}
 
-	return "", errors.New("Unsupport transcation!")
+	return runTransaction(s, "RUIJIE", trans)
 }
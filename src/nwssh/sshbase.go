@@ -7,6 +7,8 @@ import (
 	"golang.org/x/crypto/ssh"
 	"io"
 	"io/ioutil"
+	"net"
+	nwnet "nwnet"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -24,25 +26,47 @@ type SSHBASE interface {
 	ExecCommandTiming(string, time.Duration) (string, error)
 	ExecCommandExpect(string, string, time.Duration) (string, error)
 	ExecCommandExpectPrompt(string, time.Duration) (string, error)
+	ExecCommandUnchecked(string, time.Duration) (string, error)
+	Prompt() string
+	Sanitize(string, string) string
+	Enable(string) error
 	SaveRuningConfig() bool
+	RunningConfig() (string, error)
+	UserView() error
+	GetFacts() (Facts, error)
 	RunTranscation(string) (string, error)
 }
 
+// Checkpointer is implemented by the drivers which can save the running
+// configuration as a checkpoint and roll back to it.
+type Checkpointer interface {
+	Checkpoint(name string) error
+	Rollback(name string) error
+	DeleteCheckpoint(name string) error
+}
+
 type SSHBase struct {
-	host         string
-	port         string
-	sshconfig    *ssh.ClientConfig
-	client       *ssh.Client
-	session      *ssh.Session
-	termheight   int
-	termwidth    int
-	termtype     string
-	alive        bool
-	InChannel    io.WriteCloser
-	OutChannel   io.Reader
-	respchan     chan string
-	readwaittime time.Duration
-	WelecomInfo  string
+	host          string
+	port          string
+	sshconfig     *ssh.ClientConfig
+	credentials   []credentialConfig
+	credential    string
+	client        *ssh.Client
+	jumphost      string
+	jumpconfig    *ssh.ClientConfig
+	jumpclient    *ssh.Client
+	session       *ssh.Session
+	termheight    int
+	termwidth     int
+	termtype      string
+	alive         bool
+	InChannel     io.WriteCloser
+	OutChannel    io.Reader
+	respchan      chan string
+	readwaittime  time.Duration
+	errorpatterns []*regexp.Regexp
+	prompt        string
+	WelecomInfo   string
 }
 
 type SSHOptions struct {
@@ -53,15 +77,32 @@ type SSHOptions struct {
 	TermHeight     int
 	TermWidht      int
 	ReadWaitTime   time.Duration //Read data from a ssh channel timeout
+	JumpHost       string        //Connect to the host through a jump host, '[user@]host[:port]'
+	Credentials    []Credential  //Credentials tried in order, username and password are ignored if given.
 }
 
-func SSH(host, port, username, password string, timeout time.Duration, sshopts SSHOptions) (*SSHBase, error) {
+// Credential is a set of username and password or private key, the
+// credentials given by SSHOptions are tried in order until one of them is
+// accepted by the host.
+type Credential struct {
+	Name           string
+	Username       string
+	Password       string
+	PrivateKeyFile string
+}
 
+type credentialConfig struct {
+	name   string
+	config *ssh.ClientConfig
+}
+
+func clientConfig(username, password, keyfile string, timeout time.Duration, host string, sshopts SSHOptions) (*ssh.ClientConfig, error) {
 	config := &ssh.ClientConfig{
 		User:    username,
 		Timeout: timeout, //time.duration should be lager than 1 second.
 		Auth: []ssh.AuthMethod{
 			ssh.Password(password),
+			ssh.KeyboardInteractive(answerPassword(password)),
 		},
 		BannerCallback: sshopts.BannerCallback,
 	}
@@ -69,11 +110,11 @@ func SSH(host, port, username, password string, timeout time.Duration, sshopts S
 	config.Config.Ciphers = append(config.Config.Ciphers, "aes128-cbc")
 	config.Config.Ciphers = append(config.Config.Ciphers, "aes128-ctr")
 
-	if sshopts.PrivateKeyFile != "" {
+	if keyfile != "" {
 
-		key, err := ioutil.ReadFile(sshopts.PrivateKeyFile)
+		key, err := ioutil.ReadFile(keyfile)
 		if err != nil {
-			return nil, fmt.Errorf("Unable to opne file '%s'.%v", sshopts.PrivateKeyFile, err)
+			return nil, fmt.Errorf("Unable to opne file '%s'.%v", keyfile, err)
 		}
 		signer, err := ssh.ParsePrivateKey(key)
 		if err != nil {
@@ -91,19 +132,67 @@ func SSH(host, port, username, password string, timeout time.Duration, sshopts S
 
 		config.HostKeyCallback = ssh.FixedHostKey(getHostKey(host))
 	}
+	return config, nil
+}
+
+// SSH creates a client of host, if sshopts.Credentials is given, they are
+// tried in order instead of username and password.
+func SSH(host, port, username, password string, timeout time.Duration, sshopts SSHOptions) (*SSHBase, error) {
+
+	var credentials []credentialConfig
+	for _, cred := range sshopts.Credentials {
+		config, err := clientConfig(cred.Username, cred.Password, cred.PrivateKeyFile, timeout, host, sshopts)
+		if err != nil {
+			return nil, fmt.Errorf("Credential '%s': %v", cred.Name, err)
+		}
+		credentials = append(credentials, credentialConfig{cred.Name, config})
+	}
+	if len(credentials) == 0 {
+		config, err := clientConfig(username, password, sshopts.PrivateKeyFile, timeout, host, sshopts)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credentialConfig{"", config})
+	}
+	config := credentials[0].config
 
 	ssh_client := &SSHBase{
 		host:         host,
 		port:         port,
 		sshconfig:    config,
+		credentials:  credentials,
 		termheight:   sshopts.TermHeight,
 		termwidth:    sshopts.TermWidht,
 		termtype:     sshopts.TermType,
 		readwaittime: sshopts.ReadWaitTime,
 	}
+
+	if sshopts.JumpHost != "" {
+		jumpuser, jumphost := splitJumpHost(sshopts.JumpHost, config.User)
+		jumpconfig := *config
+		jumpconfig.User = jumpuser
+		if !sshopts.IgnorHostKey {
+			jumpname, _, _ := net.SplitHostPort(jumphost)
+			jumpconfig.HostKeyCallback = ssh.FixedHostKey(getHostKey(jumpname))
+		}
+		ssh_client.jumphost = jumphost
+		ssh_client.jumpconfig = &jumpconfig
+	}
 	return ssh_client, nil
 }
 
+// splitJumpHost splits '[user@]host[:port]' into user and 'host:port', the
+// user is the same as the target host's if not given, and port is 22.
+func splitJumpHost(jumphost, username string) (string, string) {
+	if i := strings.LastIndex(jumphost, "@"); i >= 0 {
+		username, jumphost = jumphost[:i], jumphost[i+1:]
+	}
+	if _, _, err := net.SplitHostPort(jumphost); err != nil {
+		jumphost = nwnet.JoinHostPort(jumphost, "22")
+	}
+	return username, jumphost
+}
+
 func getHostKey(host string) ssh.PublicKey {
 	// parse OpenSSH known_hosts file
 	file, err := os.Open(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"))
@@ -136,7 +225,21 @@ func (s *SSHBase) Connect() error {
 	if s.alive {
 		return errors.New("SSH Connection is opened.")
 	}
-	client, err := ssh.Dial("tcp", s.host+":"+s.port, s.sshconfig)
+	var client *ssh.Client
+	var err error
+	for _, cred := range s.credentials {
+		s.sshconfig = cred.config
+		client, err = s.dial()
+		if err == nil {
+			s.credential = cred.name
+			break
+		}
+		// Only authentication failure is worth trying the next credential.
+		var authErr *authError
+		if !errors.As(err, &authErr) {
+			break
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("%v", err)
 	}
@@ -161,6 +264,89 @@ func (s *SSHBase) Connect() error {
 	return nil
 }
 
+// Credential returns the name of the credential accepted by the host.
+func (s *SSHBase) Credential() string {
+	return s.credential
+}
+
+func (s *SSHBase) dial() (*ssh.Client, error) {
+	addr := nwnet.JoinHostPort(s.host, s.port)
+	if s.jumphost == "" {
+		conn, err := net.DialTimeout("tcp", addr, s.sshconfig.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		return handshake(conn, addr, s.sshconfig)
+	}
+
+	jumpclient, err := ssh.Dial("tcp", s.jumphost, s.jumpconfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to jump host '%s': %v", s.jumphost, err)
+	}
+	conn, err := jumpclient.Dial("tcp", addr)
+	if err != nil {
+		jumpclient.Close()
+		return nil, fmt.Errorf("Failed to connect to '%s' through jump host '%s': %v", addr, s.jumphost, err)
+	}
+	client, err := handshake(conn, addr, s.sshconfig)
+	if err != nil {
+		jumpclient.Close()
+		return nil, err
+	}
+	s.jumpclient = jumpclient
+	return client, nil
+}
+
+// authError is the failure of the SSH handshake after the host key has been
+// accepted, that is the server rejected all the authentication methods of the
+// credential, such as password, keyboard-interactive or public key.
+type authError struct {
+	err error
+}
+
+func (e *authError) Error() string {
+	return e.err.Error()
+}
+
+func (e *authError) Unwrap() error {
+	return e.err
+}
+
+// handshake establishes the SSH connection over conn, which is closed if it
+// fails. It's an authError if the handshake fails after the host key check.
+func handshake(conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	accepted := false
+	checked := *config
+	checked.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := config.HostKeyCallback(hostname, remote, key); err != nil {
+			return err
+		}
+		accepted = true
+		return nil
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &checked)
+	if err != nil {
+		conn.Close()
+		if accepted {
+			return nil, &authError{err}
+		}
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// answerPassword answers all the questions of keyboard-interactive with the
+// password, which is the way the devices ask for the password.
+func answerPassword(password string) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range questions {
+			answers[i] = password
+		}
+		return answers, nil
+	}
+}
+
 func (s *SSHBase) invokeShell() error {
 
 	term_mode := ssh.TerminalModes{
@@ -213,6 +399,9 @@ func (s *SSHBase) Close() {
 	if s.alive {
 		s.session.Close()
 		s.client.Close()
+		if s.jumpclient != nil {
+			s.jumpclient.Close()
+		}
 		s.alive = false
 	}
 }
@@ -296,6 +485,7 @@ READEND:
 				lines := strings.Split(strings.TrimSpace(respone), "\n")
 				if len_lines := len(lines); len_lines >= 1 {
 					if findPrompt(lines[len_lines-1]) {
+						s.learnPrompt(respone)
 						break READEND
 					}
 				}
@@ -331,22 +521,203 @@ func findPrompt(s string) bool {
 	return r.MatchString(s)
 }
 
-func SanitizeRespone(resp string, stripcmd bool, stripprompt bool) string {
-	resp = strings.TrimSpace(resp)
-	if stripcmd && stripprompt {
-		lines := strings.Split(resp, "\n")
-		return strings.Join(lines[1:len(lines)-1], "\n")
+// promptLine matches a whole line which consists of a prompt only, it's more
+// strict than findPrompt.
+var promptLine = regexp.MustCompile(`^[<\[]?[\w~@_\-\(\)\.\*\/:]+(>|%|#|\]|\$)$`)
+
+// promptHostname returns the hostname part of a prompt, for example:
+//
+//	<BJ_YF_305-A-15_CE5810>  => BJ_YF_305-A-15_CE5810
+//	[~BJ_YF_320-I-10_CE5810] => BJ_YF_320-I-10_CE5810
+//	BJ_YF_311-F-02_N7718-1#  => BJ_YF_311-F-02_N7718-1
+//	Router(config-if)#       => Router
+func promptHostname(prompt string) string {
+	name := strings.TrimSpace(prompt)
+	name = strings.TrimLeft(name, "<[~*")
+	name = strings.TrimRight(name, ">%#]$")
+	if i := strings.Index(name, "("); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// lastPrompt returns the last line of resp if it looks like a prompt.
+func lastPrompt(resp string) string {
+	lines := strings.Split(strings.TrimSpace(resp), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if promptLine.MatchString(last) {
+		return last
 	}
-	if stripcmd {
-		lines := strings.SplitN(resp, "\n", 1)
-		return strings.Join(lines[1:], "")
+	return ""
+}
+
+// learnPrompt keeps the first prompt seen, which is the prompt of the user
+// view, the prompts of the other views are derived from it.
+func (s *SSHBase) learnPrompt(resp string) {
+	if s.prompt != "" {
+		return
 	}
-	if stripprompt {
-		lines := strings.Split(resp, "\n")
-		return strings.Join(lines[:len(lines)-1], "\n")
+	if p := lastPrompt(resp); p != "" {
+		s.prompt = p
 	}
+}
 
-	return resp
+// Prompt returns the prompt learned from the remote host, it's empty before
+// the prompt has been seen.
+func (s *SSHBase) Prompt() string {
+	return s.prompt
+}
+
+// isPrompt reports whether line is a prompt of the host named hostname. The
+// hostname is matched as the prefix, so the prompts of other views such as
+// '[HOST-vlan100]' or 'HOST(config-if)#' are also treated as prompts. If
+// hostname is empty, no line is a prompt since the data lines like '[OK]' or
+// '100%' look like prompts too.
+func isPrompt(line, hostname string) bool {
+	line = strings.TrimSpace(line)
+	if hostname == "" || !promptLine.MatchString(line) {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimLeft(line, "<[~*"), hostname)
+}
+
+// splitPrompt splits a line like '<HOST>display clock' into the prompt and
+// the command followed. ok is false if the line is not started with a prompt
+// of hostname.
+func splitPrompt(line, hostname string) (prompt, cmd string, ok bool) {
+	if hostname == "" {
+		return "", line, false
+	}
+	trimmed := strings.TrimLeft(line, "<[~*")
+	if !strings.HasPrefix(trimmed, hostname) {
+		return "", line, false
+	}
+	rest := trimmed[len(hostname):]
+	i := strings.IndexAny(rest, ">%#]$")
+	if i < 0 || strings.ContainsAny(rest[:i], " \t") {
+		return "", line, false
+	}
+	n := len(line) - len(rest) + i + 1
+	return line[:n], line[n:], true
+}
+
+// stripEcho removes the echo of cmd from the head of lines. The echo may be
+// wrapped into several lines when it's longer than the terminal width, so the
+// leading lines are joined and compared with cmd until it's matched.
+func stripEcho(lines []string, cmd, hostname string) []string {
+	cmd = strings.TrimSpace(cmd)
+	if len(lines) == 0 {
+		return lines
+	}
+	if cmd == "" {
+		if _, rest, ok := splitPrompt(lines[0], hostname); ok && strings.TrimSpace(rest) == "" {
+			return lines[1:]
+		}
+		if strings.TrimSpace(lines[0]) == "" {
+			return lines[1:]
+		}
+		return lines
+	}
+
+	echo := ""
+	for i, line := range lines {
+		if i == 0 {
+			if _, rest, ok := splitPrompt(line, hostname); ok {
+				line = rest
+			}
+		}
+		echo += line
+		trimmed := strings.TrimSpace(echo)
+		if trimmed == cmd {
+			return lines[i+1:]
+		}
+		if !strings.HasPrefix(cmd, trimmed) {
+			break
+		}
+	}
+	return lines
+}
+
+// SanitizeOutput strips the echo of cmd and the prompts from resp, prompt is
+// the prompt learned from the host. If it's empty, only the last line is
+// stripped if it looks like a prompt.
+//
+// resp may contain the output of several commands, in which case each of the
+// following commands is echoed after a prompt such as '<HOST>display clock',
+// these lines are stripped too. The backspaces are applied as the terminal
+// does, which are sent by some devices to redraw the wrapped echo.
+func SanitizeOutput(resp, cmd, prompt string) string {
+	hostname := promptHostname(prompt)
+	lines := strings.Split(strings.Trim(normalizeLineFeeds(resp), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = applyBackspaces(line)
+	}
+	lines = stripEcho(lines, cmd, hostname)
+
+	if hostname == "" {
+		if n := len(lines); n > 0 && promptLine.MatchString(strings.TrimSpace(lines[n-1])) {
+			lines = lines[:n-1]
+		}
+		return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	}
+
+	var output []string
+	for _, line := range lines {
+		if isPrompt(line, hostname) {
+			continue
+		}
+		if _, _, ok := splitPrompt(line, hostname); ok {
+			continue
+		}
+		output = append(output, line)
+	}
+	return strings.TrimRight(strings.Join(output, "\n"), "\n")
+}
+
+// applyBackspaces returns line as shown by the terminal, a backspace moves the
+// cursor back and the following characters overwrite the ones there.
+func applyBackspaces(line string) string {
+	if !strings.Contains(line, "\b") {
+		return line
+	}
+	var shown []rune
+	cursor := 0
+	for _, r := range line {
+		if r == '\b' {
+			if cursor > 0 {
+				cursor--
+			}
+			continue
+		}
+		if cursor < len(shown) {
+			shown[cursor] = r
+		} else {
+			shown = append(shown, r)
+		}
+		cursor++
+	}
+	return strings.TrimRight(string(shown), " ")
+}
+
+// Sanitize strips the echo of cmd and the prompts from resp with the prompt
+// learned from the host.
+func (s *SSHBase) Sanitize(cmd, resp string) string {
+	return SanitizeOutput(resp, cmd, s.prompt)
+}
+
+// SanitizeRespone strips the first line (the command) and the last line (the
+// prompt) of resp blindly. Use SanitizeOutput or SSHBase.Sanitize instead if
+// the command and the prompt are known.
+func SanitizeRespone(resp string, stripcmd bool, stripprompt bool) string {
+	resp = strings.TrimSpace(resp)
+	lines := strings.Split(resp, "\n")
+	if stripcmd && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if stripprompt && len(lines) > 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
 }
 
 func (s *SSHBase) clearBuffer() {
@@ -362,28 +733,131 @@ READEND:
 
 func (s *SSHBase) preparateWriting() bool {
 	if findPrompt(s.WelecomInfo) {
+		s.learnPrompt(s.WelecomInfo)
 		return true
 	}
 
-	if findPrompt(s.readChannel()) {
+	if resp := s.readChannel(); findPrompt(resp) {
+		s.learnPrompt(resp)
 		return true
 	}
 
 	for i := 0; i < 3; i++ {
 		time.Sleep(time.Millisecond * 2)
-		resp, err := s.ExecCommand("")
+		resp, err := s.execCommand("")
 		if err != nil {
 			return false
 		}
 		if findPrompt(resp) {
+			s.learnPrompt(resp)
 			return true
 		}
 	}
 	return false
 }
 
+// enable enters the privileged mode by cmd, such as 'enable' or 'super'.
+func (s *SSHBase) enable(cmd, password string) error {
+	_, err := s.execCommandExpect(cmd, "assword:", time.Second*5)
+	if err != nil {
+		return fmt.Errorf("Failed to enter privileged mode by '%s'. %v", cmd, err)
+	}
+	resp, err := s.execCommandExpectPrompt(password, time.Second*5)
+	if err != nil {
+		return fmt.Errorf("Failed to enter privileged mode by '%s'. %v", cmd, err)
+	}
+	lower := strings.ToLower(resp)
+	for _, failed := range []string{"denied", "bad", "incorrect", "error", "assword:"} {
+		if strings.Contains(lower, failed) {
+			return fmt.Errorf("Failed to enter privileged mode by '%s', password is rejected.", cmd)
+		}
+	}
+	return nil
+}
+
+// runningConfig returns the running configuration printed by cmd, without
+// the command line and the prompt.
+func (s *SSHBase) runningConfig(cmd string) (string, error) {
+	resp, err := s.execCommandExpectPrompt(cmd, time.Second*60)
+	if err != nil {
+		return "", err
+	}
+	return s.Sanitize(cmd, resp), nil
+}
+
+// questionLine matches the last line of output which asks for an answer,
+// such as 'Continue? [Y/N]:' and 'Destination filename [x.cfg]?'.
+var questionLine = regexp.MustCompile(`(\[Y/N\]|\?)\s*:?$`)
+
+// execAnswer runs cmd and answers the questions asked by the device until the
+// prompt returns, answer returns the answer of the question line.
+func (s *SSHBase) execAnswer(cmd string, answer func(question string) string, timeout time.Duration) (string, error) {
+	output := ""
+	resp, err := s.execCommandExpectPrompt(cmd, timeout)
+	for i := 0; i < 5; i++ {
+		output += resp
+		if err != nil {
+			return output, err
+		}
+		lines := strings.Split(strings.TrimSpace(resp), "\n")
+		question := strings.TrimSpace(lines[len(lines)-1])
+		if !questionLine.MatchString(question) {
+			return output, nil
+		}
+		resp, err = s.execCommandExpectPrompt(answer(question), timeout)
+	}
+	return output, fmt.Errorf("Too many questions asked by '%s'.", cmd)
+}
+
+// answerYes answers 'Y' to all the questions, and the default value to the
+// questions like 'Destination filename [x.cfg]?'.
+func answerYes(question string) string {
+	if strings.Contains(question, "[Y/N]") {
+		return "Y"
+	}
+	return ""
+}
+
+// checkOutput returns an error if the output of cmd reports a failure.
+func checkOutput(cmd, resp string) error {
+	lower := strings.ToLower(resp)
+	for _, failed := range []string{"error", "fail", "invalid", "unrecognized", "not exist", "aborted"} {
+		if strings.Contains(lower, failed) {
+			return fmt.Errorf("Command '%s' failed. %s", cmd, strings.TrimSpace(SanitizeRespone(resp, true, true)))
+		}
+	}
+	return nil
+}
+
+// execChecked runs cmd with answerYes and checks its output.
+func (s *SSHBase) execChecked(cmd string, timeout time.Duration) error {
+	resp, err := s.execAnswer(cmd, answerYes, timeout)
+	if err != nil {
+		return err
+	}
+	return checkOutput(cmd, resp)
+}
+
+// userView leaves the system, config or interface view by cmd, 'return' or
+// 'end', and checks the prompt is of the user view again. The output of cmd is
+// ignored since it's rejected by some devices in the user view.
+func (s *SSHBase) userView(cmd string) error {
+	resp, err := s.execCommandExpectPrompt(cmd, time.Second*10)
+	if err != nil {
+		return fmt.Errorf("Failed to return to the user view. %v", err)
+	}
+	prompt := lastPrompt(resp)
+	if s.prompt != "" && prompt == s.prompt {
+		return nil
+	}
+	if s.prompt == "" && prompt != "" && !strings.HasPrefix(prompt, "[") && !strings.Contains(prompt, "(") {
+		return nil
+	}
+	return fmt.Errorf("Failed to return to the user view, the prompt is '%s'.", prompt)
+}
+
 func (s *SSHBase) disablePaging(cmd string) bool {
-	if _, err := s.ExecCommand(cmd); err != nil {
+	if _, err := s.execCommand(cmd); err != nil {
 		return false
 	}
 	return true
@@ -414,18 +888,17 @@ func (s *SSHBase) sendCommand(cmd string) (int, error) {
 	return n, err
 }
 
-func (s *SSHBase) ExecCommand(cmd string) (respone string, err error) {
+func (s *SSHBase) execCommand(cmd string) (respone string, err error) {
 	s.clearBuffer()
 	_, err = s.sendCommand(cmd)
 	if err != nil {
 		return "", err
 	}
 	respone = s.readChannel()
-	err = nil
 	return
 }
 
-func (s *SSHBase) ExecCommandTiming(cmd string, timeout time.Duration) (respone string, err error) {
+func (s *SSHBase) execCommandTiming(cmd string, timeout time.Duration) (respone string, err error) {
 	s.clearBuffer()
 	_, err = s.sendCommand(cmd)
 	if err != nil {
@@ -435,7 +908,7 @@ func (s *SSHBase) ExecCommandTiming(cmd string, timeout time.Duration) (respone
 	return
 }
 
-func (s *SSHBase) ExecCommandExpect(cmd string, expect string, timeout time.Duration) (respone string, err error) {
+func (s *SSHBase) execCommandExpect(cmd string, expect string, timeout time.Duration) (respone string, err error) {
 	s.clearBuffer()
 	_, err = s.sendCommand(cmd)
 	if err != nil {
@@ -446,7 +919,7 @@ func (s *SSHBase) ExecCommandExpect(cmd string, expect string, timeout time.Dura
 	return
 }
 
-func (s *SSHBase) ExecCommandExpectPrompt(cmd string, timeout time.Duration) (respone string, err error) {
+func (s *SSHBase) execCommandExpectPrompt(cmd string, timeout time.Duration) (respone string, err error) {
 	s.clearBuffer()
 	_, err = s.sendCommand(cmd)
 	if err != nil {
@@ -455,3 +928,49 @@ func (s *SSHBase) ExecCommandExpectPrompt(cmd string, timeout time.Duration) (re
 	respone, err = s.readChannelExpectPrompt(timeout)
 	return
 }
+
+// ExecCommand runs cmd and returns the output read before it goes quiet. If
+// the output matches an error pattern of the session, it's returned with
+// CommandRejected, so are the other ExecCommand* methods.
+func (s *SSHBase) ExecCommand(cmd string) (string, error) {
+	resp, err := s.execCommand(cmd)
+	if err != nil {
+		return resp, err
+	}
+	return resp, s.CheckRejected(cmd, resp)
+}
+
+// ExecCommandTiming runs cmd and returns the output read in timeout.
+func (s *SSHBase) ExecCommandTiming(cmd string, timeout time.Duration) (string, error) {
+	resp, err := s.execCommandTiming(cmd, timeout)
+	if err != nil {
+		return resp, err
+	}
+	return resp, s.CheckRejected(cmd, resp)
+}
+
+// ExecCommandExpect runs cmd and returns the output until expect is read.
+func (s *SSHBase) ExecCommandExpect(cmd string, expect string, timeout time.Duration) (string, error) {
+	resp, err := s.execCommandExpect(cmd, expect, timeout)
+	if err != nil {
+		return resp, err
+	}
+	return resp, s.CheckRejected(cmd, resp)
+}
+
+// ExecCommandExpectPrompt runs cmd and returns the output until the prompt
+// returns.
+func (s *SSHBase) ExecCommandExpectPrompt(cmd string, timeout time.Duration) (string, error) {
+	resp, err := s.execCommandExpectPrompt(cmd, timeout)
+	if err != nil {
+		return resp, err
+	}
+	return resp, s.CheckRejected(cmd, resp)
+}
+
+// ExecCommandUnchecked is ExecCommandExpectPrompt without checking the output
+// by the error patterns, for the commands which may be rejected on purpose,
+// such as probing the commands supported by the device.
+func (s *SSHBase) ExecCommandUnchecked(cmd string, timeout time.Duration) (string, error) {
+	return s.execCommandExpectPrompt(cmd, timeout)
+}
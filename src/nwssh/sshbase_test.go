@@ -0,0 +1,300 @@
+package nwssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSSH accepts SSH connections by config on a local port, and returns
+// the port.
+func serveSSH(t *testing.T, config *ssh.ServerConfig) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if c, _, _, err := ssh.NewServerConn(conn, config); err == nil {
+					c.Close()
+				}
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func dialWith(t *testing.T, port, password string) error {
+	s, err := SSH("127.0.0.1", port, "admin", password, 5*time.Second, SSHOptions{IgnorHostKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := s.dial()
+	if err == nil {
+		client.Close()
+	}
+	return err
+}
+
+func TestDialAuthentication(t *testing.T) {
+	password := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) == "right" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	interactive := &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(c ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"Password: "}, []bool{false})
+			if err != nil || len(answers) != 1 || answers[0] != "right" {
+				return nil, errors.New("denied")
+			}
+			return nil, nil
+		},
+	}
+	cases := []struct {
+		name     string
+		config   *ssh.ServerConfig
+		password string
+		auth     bool
+	}{
+		{"password", password, "right", false},
+		{"wrong password", password, "wrong", true},
+		{"keyboard-interactive", interactive, "right", false},
+		{"wrong keyboard-interactive", interactive, "wrong", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := dialWith(t, serveSSH(t, c.config), c.password)
+			var authErr *authError
+			if c.auth && !errors.As(err, &authErr) {
+				t.Errorf("error = %v, want authentication failure", err)
+			}
+			if !c.auth && err != nil {
+				t.Errorf("error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+// The failures before the host key is accepted are not authentication
+// failures, the other credentials are not tried.
+func TestDialNotAuthentication(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, closed, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	// The server closes the connection before the key exchange.
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, hangup, _ := net.SplitHostPort(listener.Addr().String())
+
+	for name, port := range map[string]string{"refused": closed, "hangup": hangup} {
+		err := dialWith(t, port, "right")
+		var authErr *authError
+		if err == nil || errors.As(err, &authErr) {
+			t.Errorf("%s: error = %v, want a failure other than authentication", name, err)
+		}
+	}
+}
+
+func TestSanitizeOutput(t *testing.T) {
+	more := "  ---- More ----" + strings.Repeat("\b", 16) + strings.Repeat(" ", 16) + strings.Repeat("\b", 16)
+	cases := []struct {
+		name, resp, cmd, prompt, want string
+	}{
+		{
+			name:   "echo and prompt",
+			resp:   "display clock\n10:00:00 UTC Mon 10/19/2026\n<SW1>",
+			cmd:    "display clock",
+			prompt: "<SW1>",
+			want:   "10:00:00 UTC Mon 10/19/2026",
+		},
+		{
+			name:   "wrapped echo",
+			resp:   "<SW1>display interface brief | incl\nude GE1/0/1\nGE1/0/1  UP  UP\n<SW1>",
+			cmd:    "display interface brief | include GE1/0/1",
+			prompt: "<SW1>",
+			want:   "GE1/0/1  UP  UP",
+		},
+		{
+			name:   "carriage returns",
+			resp:   "show clock\r\r\n*10:00:00.000 UTC Mon Oct 19 2026\r\nSW1#",
+			cmd:    "show clock",
+			prompt: "SW1#",
+			want:   "*10:00:00.000 UTC Mon Oct 19 2026",
+		},
+		{
+			name:   "redrawn echo",
+			resp:   "SW1#show run | i host\b\b\b\bhostname\nhostname SW1\nSW1#",
+			cmd:    "show run | i hostname",
+			prompt: "SW1#",
+			want:   "hostname SW1",
+		},
+		{
+			name:   "paging erased",
+			resp:   "display current-configuration interface\ninterface GE1/0/1\n" + more + "interface GE1/0/2\n<SW1>",
+			cmd:    "display current-configuration interface",
+			prompt: "<SW1>",
+			want:   "interface GE1/0/1\ninterface GE1/0/2",
+		},
+		{
+			name:   "prompt-like data",
+			resp:   "copy running-config startup-config\nBuilding configuration...\n[OK]\n100%\nSW1#",
+			cmd:    "copy running-config startup-config",
+			prompt: "SW1#",
+			want:   "Building configuration...\n[OK]\n100%",
+		},
+		{
+			name:   "prompt-like data without prompt",
+			resp:   "copy running-config startup-config\n[OK]\nSW2>\nSW1#",
+			cmd:    "copy running-config startup-config",
+			prompt: "",
+			want:   "[OK]\nSW2>",
+		},
+		{
+			name:   "other views and commands",
+			resp:   "system-view\nSystem View: return to User View with Ctrl+Z.\n[SW1]vlan 10\n[SW1-vlan10]",
+			cmd:    "system-view",
+			prompt: "<SW1>",
+			want:   "System View: return to User View with Ctrl+Z.",
+		},
+		{
+			name:   "other hosts",
+			resp:   "display lldp neighbor-information list\n<SW2>\n[SW2]\n<SW1>",
+			cmd:    "display lldp neighbor-information list",
+			prompt: "<SW1>",
+			want:   "<SW2>\n[SW2]",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := SanitizeOutput(c.resp, c.cmd, c.prompt); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsPrompt(t *testing.T) {
+	cases := []struct {
+		line, hostname string
+		want           bool
+	}{
+		{"<SW1>", "SW1", true},
+		{"[~SW1-GE1/0/1]", "SW1", true},
+		{"SW1(config-if)#", "SW1", true},
+		{"SW2#", "SW1", false},
+		{"[OK]", "SW1", false},
+		{"100%", "SW1", false},
+		{"<SW1>", "", false},
+		{"<SW1>display clock", "SW1", false},
+	}
+	for _, c := range cases {
+		if got := isPrompt(c.line, c.hostname); got != c.want {
+			t.Errorf("isPrompt(%q, %q) = %v, want %v", c.line, c.hostname, got, c.want)
+		}
+	}
+}
+
+// scriptedChannel replies the output of each command written to the session,
+// by the command without the line break. If prompt is set, the replies are
+// echoed and followed by the prompt like the devices.
+type scriptedChannel struct {
+	respchan chan string
+	replies  map[string]string
+	prompt   string
+}
+
+func (c *scriptedChannel) Write(p []byte) (int, error) {
+	cmd := strings.TrimSpace(string(p))
+	if c.prompt == "" {
+		c.respchan <- c.replies[cmd]
+	} else {
+		c.respchan <- cmd + "\n" + c.replies[cmd] + "\n" + c.prompt
+	}
+	return len(p), nil
+}
+
+func (c *scriptedChannel) Close() error { return nil }
+
+func TestExecCommandRejected(t *testing.T) {
+	replies := map[string]string{
+		"display clock": "display clock\n10:00:00 UTC Mon 10/19/2026\n<SW1>",
+		"display bgp":   "display bgp\n              ^\n % Incomplete command found at '^' position.\n<SW1>",
+	}
+	cases := []struct {
+		name, cmd string
+		patterns  []*regexp.Regexp
+		unchecked bool
+		rejected  bool
+	}{
+		{name: "accepted", cmd: "display clock", patterns: ErrorPatterns("h3c")},
+		{name: "rejected", cmd: "display bgp", patterns: ErrorPatterns("h3c"), rejected: true},
+		{name: "unchecked", cmd: "display bgp", patterns: ErrorPatterns("h3c"), unchecked: true},
+		{name: "no patterns", cmd: "display bgp"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			respchan := make(chan string, 1)
+			s := &SSHBase{respchan: respchan, InChannel: &scriptedChannel{respchan: respchan, replies: replies}}
+			s.SetErrorPatterns(c.patterns)
+			exec := s.ExecCommandExpectPrompt
+			if c.unchecked {
+				exec = s.ExecCommandUnchecked
+			}
+			resp, err := exec(c.cmd, time.Second)
+			if resp != replies[c.cmd] {
+				t.Errorf("output = %q, want %q", resp, replies[c.cmd])
+			}
+			var rejected *CommandRejected
+			if errors.As(err, &rejected) != c.rejected || (!c.rejected && err != nil) {
+				t.Errorf("error = %v, want rejected %v", err, c.rejected)
+			}
+			if c.rejected && rejected.Message != "% Incomplete command found at '^' position." {
+				t.Errorf("message = %q", rejected.Message)
+			}
+		})
+	}
+}
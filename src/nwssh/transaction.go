@@ -0,0 +1,22 @@
+package nwssh
+
+import (
+	"errors"
+)
+
+// Transaction runs a defined transaction on dev of vendor and returns the
+// output.
+type Transaction func(dev SSHBASE, vendor string) (string, error)
+
+// Transactions are run by RunTranscation besides the builtin ones of the
+// drivers, keyed by name. The packages built on nwssh (like nwswdb) add theirs
+// here when they are imported.
+var Transactions = map[string]Transaction{}
+
+// runTransaction runs the transaction trans of Transactions.
+func runTransaction(dev SSHBASE, vendor, trans string) (string, error) {
+	if t, ok := Transactions[trans]; ok {
+		return t(dev, vendor)
+	}
+	return "", errors.New("Unsupport transcation!")
+}
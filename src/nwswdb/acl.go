@@ -0,0 +1,512 @@
+package nwswdb
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	nwnet "nwnet"
+	"strconv"
+	"strings"
+)
+
+/*
+ParseACLs reads the IPv4 ACLs in a configuration parsed by ParseConfig:
+
+	H3C     acl number|basic|advanced <number> [name <name>]
+	HUAWEI  acl name <name> [<number>|basic|advance]
+	         rule <index> permit|deny [<protocol>] [source <address> <wildcard>|any]
+	          [source-port <op> <port>] [destination ..] [destination-port ..]
+	          [vpn-instance <vrf>]
+	         step <step>
+	CISCO   ip access-list standard|extended <name>
+	RUIJIE   [<index>] permit|deny <protocol> <source> [<op> <port>]
+	          <destination> [<op> <port>]
+	        access-list <number> permit|deny ..
+	NEXUS   ip access-list <name>
+	         the same entries as Cisco
+
+The addresses of Cisco are 'any', 'host <ip>', '<ip> <wildcard>' or
+'<ip>/<length>'. The nodes are normalized so that the ACLs of different
+vendors are comparable:
+
+	Protocol  the name like 'ip', 'tcp' or 'udp', the numbers of the well
+	          known protocols are replaced by their names.
+	Address   'any' is 0.0.0.0/0, the wildcard is converted to the length.
+	Port      the ranges like '80', '1024-65535' or '0-21,23-65535', the
+	          names like 'www' are replaced by the numbers, it's empty for
+	          any port.
+
+The other conditions, such as 'established', 'icmp-type', 'dscp' and 'log',
+are kept in Unsupported, such nodes are not comparable with the others, see
+CompareACL. The ACLs having any object group are skipped with a warning, the
+other rules that can't be normalized, like the ones with discontiguous
+wildcards, are errors.
+*/
+
+// The default index steps of the rules of H3C and Huawei and the entries of
+// Cisco.
+const (
+	ruleIndexStep   = 5
+	accessIndexStep = 10
+)
+
+const (
+	maxPort          = 65535
+	anyProtocol      = "ip"
+	anyAddressPrefix = "0.0.0.0/0"
+)
+
+var protocolNames = map[string]string{
+	"1": "icmp", "2": "igmp", "4": "ipinip", "6": "tcp", "17": "udp", "47": "gre", "50": "esp", "51": "ah",
+	"89": "ospf", "103": "pim", "112": "vrrp",
+}
+
+var portNames = map[string]int{
+	"ftp-data": 20, "ftp": 21, "ssh": 22, "telnet": 23, "smtp": 25, "tacacs": 49, "domain": 53, "dns": 53,
+	"bootps": 67, "bootpc": 68, "tftp": 69, "www": 80, "http": 80, "pop3": 110, "ntp": 123, "snmp": 161,
+	"snmptrap": 162, "bgp": 179, "https": 443, "syslog": 514,
+}
+
+// errObjectGroup is returned for the object groups, the ACLs having them are
+// skipped.
+var errObjectGroup = errors.New("Object group is not supported.")
+
+// ParseACLs returns the IPv4 ACLs in the configuration.
+func ParseACLs(root *Section) ([]*ACL, error) {
+	var acls []*ACL
+	numbered := map[string]*ACL{}
+	skipped := map[string]bool{}
+	for _, s := range root.Children {
+		words := strings.Fields(s.Line)
+		var acl *ACL
+		var err error
+		switch {
+		case words[0] == "acl" && len(words) > 1 && words[1] != "ipv6":
+			acl, err = parseRuleACL(s, words[1:])
+		case hasWords(s.Line, "ip access-list") && len(words) > 2:
+			acl, err = parseAccessList(s, words[len(words)-1], words[2] == "standard")
+		case words[0] == "access-list" && len(words) > 2:
+			if skipped[words[1]] {
+				continue
+			}
+			acl = numbered[words[1]]
+			if acl == nil {
+				acl = &ACL{AclNum: atoi(words[1]), IndexStep: accessIndexStep}
+				numbered[words[1]] = acl
+				acls = append(acls, acl)
+			}
+			err = addAccessNode(acl, words[2:], standardNumber(acl.AclNum))
+			if errors.Is(err, errObjectGroup) {
+				log.Printf("Skip 'access-list %s'. %v\n", words[1], err)
+				skipped[words[1]] = true
+				acls = removeACL(acls, acl)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			continue
+		}
+		if errors.Is(err, errObjectGroup) {
+			log.Printf("Skip '%s'. %v\n", s.Line, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if acl != nil {
+			acls = append(acls, acl)
+		}
+	}
+	return acls, nil
+}
+
+func removeACL(acls []*ACL, acl *ACL) []*ACL {
+	for i, a := range acls {
+		if a == acl {
+			return append(acls[:i], acls[i+1:]...)
+		}
+	}
+	return acls
+}
+
+// Label returns the name of the ACL, it's the number if there's no name.
+func (a *ACL) Label() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return strconv.Itoa(a.AclNum)
+}
+
+// standardNumber reports whether the number of Cisco is of a standard ACL.
+func standardNumber(n int) bool {
+	return (n >= 1 && n <= 99) || (n >= 1300 && n <= 1999)
+}
+
+// parseRuleACL reads the ACL of H3C or Huawei, words are after 'acl'.
+func parseRuleACL(s *Section, words []string) (*ACL, error) {
+	acl := &ACL{IndexStep: ruleIndexStep}
+	for i := 0; i < len(words); i++ {
+		if words[i] == "name" && i+1 < len(words) {
+			acl.Name = words[i+1]
+			i++
+		} else if n, err := strconv.Atoi(words[i]); err == nil && acl.AclNum == 0 {
+			acl.AclNum = n
+		}
+	}
+	for _, child := range s.Children {
+		fields := strings.Fields(child.Line)
+		switch {
+		case fields[0] == "step" && len(fields) > 1:
+			acl.IndexStep = atoi(fields[1])
+		case fields[0] == "rule" && len(fields) > 2 && (fields[2] == "permit" || fields[2] == "deny"):
+			node, err := parseRule(fields)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid rule '%s' of ACL '%s'. %w", child.Line, acl.Label(), err)
+			}
+			acl.Nodes = append(acl.Nodes, node)
+		}
+	}
+	return acl, nil
+}
+
+// parseRule reads a rule like 'rule 5 permit tcp source 10.0.0.0 0.0.0.255
+// destination-port eq 80'.
+func parseRule(words []string) (*ACLNode, error) {
+	index, err := strconv.Atoi(words[1])
+	if err != nil {
+		return nil, err
+	}
+	node := anyNode(index, words[2] == "permit")
+	i := 3
+	if i < len(words) && words[i] != "source" && words[i] != "destination" && words[i] != "vpn-instance" {
+		node.Protocol = protocolName(words[i])
+		i++
+	}
+	unsupported := false
+	for ; i < len(words); i++ {
+		switch words[i] {
+		case "source", "destination":
+			if i+1 >= len(words) {
+				return nil, fmt.Errorf("No address of '%s'.", words[i])
+			}
+			key := words[i]
+			var address nwnet.V4Prefix
+			if words[i+1] == "object-group" {
+				return nil, errObjectGroup
+			}
+			if words[i+1] == "any" {
+				address, i = anyAddress(), i+1
+			} else if i+2 < len(words) {
+				if address, err = wildcardPrefix(words[i+1], words[i+2]); err != nil {
+					return nil, err
+				}
+				i += 2
+			} else {
+				return nil, fmt.Errorf("No wildcard of '%s'.", words[i+1])
+			}
+			if key == "source" {
+				node.SrcAddress = address
+			} else {
+				node.DstAddress = address
+			}
+		case "source-port", "destination-port":
+			ports, n, err := portRange(words[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			if words[i] == "source-port" {
+				node.SrcPort = ports
+			} else {
+				node.DstPort = ports
+			}
+			i += n
+		case "vpn-instance":
+			if i+1 < len(words) {
+				node.VRF = words[i+1]
+				i++
+			}
+		default:
+			// The conditions not supported, the words in a row like
+			// 'icmp-type echo' are kept together.
+			if n := len(node.Unsupported); n > 0 && unsupported {
+				node.Unsupported[n-1] += " " + words[i]
+			} else {
+				node.Unsupported = append(node.Unsupported, words[i])
+			}
+			unsupported = true
+			continue
+		}
+		unsupported = false
+	}
+	return node, nil
+}
+
+// parseAccessList reads the ACL 'ip access-list' of Cisco, Nexus or Ruijie.
+func parseAccessList(s *Section, name string, standard bool) (*ACL, error) {
+	acl := &ACL{IndexStep: accessIndexStep}
+	if n, err := strconv.Atoi(name); err == nil {
+		acl.AclNum = n
+	} else {
+		acl.Name = name
+	}
+	for _, child := range s.Children {
+		if err := addAccessNode(acl, strings.Fields(child.Line), standard); err != nil {
+			return nil, err
+		}
+	}
+	return acl, nil
+}
+
+// addAccessNode adds the entry like '10 permit tcp any host 10.0.0.1 eq 80',
+// the index is the last one plus the step if it's omitted. The lines other
+// than the entries (like 'remark') are ignored.
+func addAccessNode(acl *ACL, words []string, standard bool) error {
+	line := strings.Join(words, " ")
+	index := acl.IndexStep
+	if len(acl.Nodes) > 0 {
+		index = acl.Nodes[len(acl.Nodes)-1].Index + acl.IndexStep
+	}
+	if n, err := strconv.Atoi(words[0]); err == nil {
+		index, words = n, words[1:]
+	}
+	if len(words) == 0 || (words[0] != "permit" && words[0] != "deny") {
+		return nil
+	}
+	node, err := parseAccessEntry(index, words, standard)
+	if err != nil {
+		return fmt.Errorf("Invalid entry '%s' of ACL '%s'. %w", line, acl.Label(), err)
+	}
+	acl.Nodes = append(acl.Nodes, node)
+	return nil
+}
+
+// parseAccessEntry reads 'permit|deny <protocol> <source> [ports]
+// <destination> [ports]', the standard ones have only the source.
+func parseAccessEntry(index int, words []string, standard bool) (*ACLNode, error) {
+	node := anyNode(index, words[0] == "permit")
+	i := 1
+	if !standard {
+		if len(words) < 2 {
+			return nil, fmt.Errorf("No protocol.")
+		}
+		if words[1] == "object-group" {
+			return nil, errObjectGroup
+		}
+		node.Protocol = protocolName(words[1])
+		i = 2
+	}
+	address, n, err := accessAddress(words[i:])
+	if err != nil {
+		return nil, err
+	}
+	node.SrcAddress, i = address, i+n
+	if standard {
+		if i < len(words) {
+			node.Unsupported = []string{strings.Join(words[i:], " ")}
+		}
+		return node, nil
+	}
+	if node.SrcPort, n, err = portRange(words[i:]); err != nil {
+		return nil, err
+	}
+	i += n
+	if node.DstAddress, n, err = accessAddress(words[i:]); err != nil {
+		return nil, err
+	}
+	i += n
+	if node.DstPort, n, err = portRange(words[i:]); err != nil {
+		return nil, err
+	}
+	i += n
+	if i < len(words) {
+		node.Unsupported = []string{strings.Join(words[i:], " ")}
+	}
+	return node, nil
+}
+
+// accessAddress reads the address of Cisco and returns the number of words
+// used.
+func accessAddress(words []string) (nwnet.V4Prefix, int, error) {
+	if len(words) == 0 {
+		return nwnet.V4Prefix{}, 0, fmt.Errorf("No address.")
+	}
+	switch {
+	case words[0] == "object-group" || words[0] == "addrgroup":
+		return nwnet.V4Prefix{}, 0, errObjectGroup
+	case words[0] == "any":
+		return anyAddress(), 1, nil
+	case words[0] == "host" && len(words) > 1:
+		p, err := wildcardPrefix(words[1], "0.0.0.0")
+		return p, 2, err
+	case strings.Contains(words[0], "/"):
+		p, err := nwnet.IPv4(words[0])
+		if err != nil {
+			return nwnet.V4Prefix{}, 0, err
+		}
+		return *p, 1, nil
+	case net.ParseIP(words[0]) != nil && len(words) > 1:
+		p, err := wildcardPrefix(words[0], words[1])
+		return p, 2, err
+	case net.ParseIP(words[0]) != nil:
+		p, err := wildcardPrefix(words[0], "0.0.0.0")
+		return p, 1, err
+	}
+	return nwnet.V4Prefix{}, 0, fmt.Errorf("Unsupported address '%s'.", words[0])
+}
+
+func anyNode(index int, permitted bool) *ACLNode {
+	return &ACLNode{Index: index, Permitted: permitted, Protocol: anyProtocol, SrcAddress: anyAddress(), DstAddress: anyAddress()}
+}
+
+func anyAddress() nwnet.V4Prefix {
+	p, _ := nwnet.IPv4(anyAddressPrefix)
+	return *p
+}
+
+func protocolName(p string) string {
+	if name, ok := protocolNames[p]; ok {
+		return name
+	}
+	if p == "0" {
+		return anyProtocol
+	}
+	return strings.ToLower(p)
+}
+
+// wildcardPrefix returns the prefix of the address and the wildcard like
+// '0.0.0.255', the wildcard '0' of H3C is a host.
+func wildcardPrefix(address, wildcard string) (nwnet.V4Prefix, error) {
+	if wildcard == "0" {
+		wildcard = "0.0.0.0"
+	}
+	w := net.ParseIP(wildcard).To4()
+	if w == nil {
+		return nwnet.V4Prefix{}, fmt.Errorf("Invalid wildcard '%s'.", wildcard)
+	}
+	ones, bits := net.IPMask{^w[0], ^w[1], ^w[2], ^w[3]}.Size()
+	if bits == 0 {
+		return nwnet.V4Prefix{}, fmt.Errorf("Discontiguous wildcard '%s'.", wildcard)
+	}
+	p, err := nwnet.IPv4(address + "/" + strconv.Itoa(ones))
+	if err != nil {
+		return nwnet.V4Prefix{}, err
+	}
+	p.IP = p.NetAddress()
+	return *p, nil
+}
+
+// port returns the number of the port like '80' or 'www'.
+func port(s string) (int, error) {
+	if n, ok := portNames[s]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > maxPort {
+		return 0, fmt.Errorf("Invalid port '%s'.", s)
+	}
+	return n, nil
+}
+
+// portRange reads the ports like 'eq 80' or 'range 80 90' at the start of
+// words, and returns the normalized ranges and the number of words used. It
+// returns empty ranges if words don't start with the ports.
+func portRange(words []string) (string, int, error) {
+	if len(words) < 2 {
+		return "", 0, nil
+	}
+	if words[0] == "object-group" || words[0] == "portgroup" {
+		return "", 0, errObjectGroup
+	}
+	op := words[0]
+	if op != "eq" && op != "neq" && op != "gt" && op != "lt" && op != "range" {
+		return "", 0, nil
+	}
+	lo, err := port(words[1])
+	if err != nil {
+		return "", 0, err
+	}
+	var ranges portRanges
+	switch op {
+	case "eq":
+		ranges = portRanges{{lo, lo}}
+	case "neq":
+		ranges = portRanges{{0, lo - 1}, {lo + 1, maxPort}}
+	case "gt":
+		ranges = portRanges{{lo + 1, maxPort}}
+	case "lt":
+		ranges = portRanges{{0, lo - 1}}
+	case "range":
+		if len(words) < 3 {
+			return "", 0, fmt.Errorf("No end of the port range.")
+		}
+		hi, err := port(words[2])
+		if err != nil {
+			return "", 0, err
+		}
+		return portRanges{{lo, hi}}.String(), 3, nil
+	}
+	return ranges.String(), 2, nil
+}
+
+type portRanges [][2]int
+
+// String returns the ranges like '80' or '0-21,23-65535', the empty ranges
+// are dropped.
+func (r portRanges) String() string {
+	var ranges []string
+	for _, p := range r {
+		switch {
+		case p[0] > p[1]:
+		case p[0] == p[1]:
+			ranges = append(ranges, strconv.Itoa(p[0]))
+		default:
+			ranges = append(ranges, strconv.Itoa(p[0])+"-"+strconv.Itoa(p[1]))
+		}
+	}
+	return strings.Join(ranges, ",")
+}
+
+// parsePortRanges parses the normalized ranges, the empty string is any port.
+func parsePortRanges(s string) portRanges {
+	if s == "" {
+		return portRanges{{0, maxPort}}
+	}
+	var ranges portRanges
+	for _, p := range strings.Split(s, ",") {
+		lo, hi, found := strings.Cut(p, "-")
+		if !found {
+			hi = lo
+		}
+		ranges = append(ranges, [2]int{atoi(lo), atoi(hi)})
+	}
+	return ranges
+}
+
+// String returns the node like 'rule 5 permit tcp 10.0.0.0/24 -> 0.0.0.0/0
+// port 80'.
+func (n *ACLNode) String() string {
+	action := "deny"
+	if n.Permitted {
+		action = "permit"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "rule %d %s %s %s", n.Index, action, n.Protocol, n.SrcAddress.String())
+	if n.SrcPort != "" {
+		fmt.Fprintf(&b, " port %s", n.SrcPort)
+	}
+	fmt.Fprintf(&b, " -> %s", n.DstAddress.String())
+	if n.DstPort != "" {
+		fmt.Fprintf(&b, " port %s", n.DstPort)
+	}
+	if n.VRF != "" {
+		fmt.Fprintf(&b, " vrf %s", n.VRF)
+	}
+	for _, c := range n.Unsupported {
+		fmt.Fprintf(&b, " %s", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,261 @@
+package nwswdb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseACLs(t *testing.T) {
+	cases := []struct {
+		name, config string
+		want         map[string][]string
+	}{
+		{
+			name: "h3c",
+			config: `acl advanced 3000 name WEB
+ rule 0 permit tcp source 10.0.0.0 0.0.0.255 destination 192.168.1.10 0 destination-port eq www
+ rule 5 permit udp source-port range 1024 65535 destination-port neq 53 vpn-instance A
+ rule 10 deny ip
+#`,
+			want: map[string][]string{"WEB": {
+				"rule 0 permit tcp 10.0.0.0/24 -> 192.168.1.10/32 port 80",
+				"rule 5 permit udp 0.0.0.0/0 port 1024-65535 -> 0.0.0.0/0 port 0-52,54-65535 vrf A",
+				"rule 10 deny ip 0.0.0.0/0 -> 0.0.0.0/0",
+			}},
+		},
+		{
+			name: "huawei",
+			config: `acl name MGMT 2000
+ rule 5 permit source 10.10.0.0 0.0.255.255
+ rule 10 deny
+#`,
+			want: map[string][]string{"MGMT": {
+				"rule 5 permit ip 10.10.0.0/16 -> 0.0.0.0/0",
+				"rule 10 deny ip 0.0.0.0/0 -> 0.0.0.0/0",
+			}},
+		},
+		{
+			name: "cisco",
+			config: `ip access-list extended WEB
+ permit tcp 10.0.0.0 0.0.0.255 host 192.168.1.10 eq www
+ permit 6 any gt 1023 10.1.0.0/16 range 80 81
+ deny   ip any any
+!
+access-list 10 permit 10.1.0.0 0.0.255.255
+access-list 10 deny   any`,
+			want: map[string][]string{
+				"WEB": {
+					"rule 10 permit tcp 10.0.0.0/24 -> 192.168.1.10/32 port 80",
+					"rule 20 permit tcp 0.0.0.0/0 port 1024-65535 -> 10.1.0.0/16 port 80-81",
+					"rule 30 deny ip 0.0.0.0/0 -> 0.0.0.0/0",
+				},
+				"10": {
+					"rule 10 permit ip 10.1.0.0/16 -> 0.0.0.0/0",
+					"rule 20 deny ip 0.0.0.0/0 -> 0.0.0.0/0",
+				},
+			},
+		},
+		{
+			name: "nexus",
+			config: `ip access-list WEB
+  10 permit tcp 10.0.0.0/24 192.168.1.10/32 eq 80
+  20 deny ip any any`,
+			want: map[string][]string{"WEB": {
+				"rule 10 permit tcp 10.0.0.0/24 -> 192.168.1.10/32 port 80",
+				"rule 20 deny ip 0.0.0.0/0 -> 0.0.0.0/0",
+			}},
+		},
+		{
+			name: "unsupported conditions",
+			config: `acl advanced 3001
+ rule 5 permit icmp icmp-type echo
+ rule 10 permit tcp established logging
+#
+ip access-list extended PING
+ permit icmp any any echo
+ deny ip any any log`,
+			want: map[string][]string{
+				"3001": {
+					"rule 5 permit icmp 0.0.0.0/0 -> 0.0.0.0/0 icmp-type echo",
+					"rule 10 permit tcp 0.0.0.0/0 -> 0.0.0.0/0 established logging",
+				},
+				"PING": {
+					"rule 10 permit icmp 0.0.0.0/0 -> 0.0.0.0/0 echo",
+					"rule 20 deny ip 0.0.0.0/0 -> 0.0.0.0/0 log",
+				},
+			},
+		},
+		{
+			name: "object groups skipped",
+			config: `acl advanced 3002
+ rule 5 permit tcp source object-group SERVERS
+#
+acl advanced 3003
+ rule 5 permit tcp source-port object-group PORTS
+#
+acl advanced 3004
+ rule 5 deny ip
+#
+access-list 101 permit tcp any any eq 80
+access-list 101 permit tcp any object-group SERVERS eq 80
+access-list 101 deny ip any any
+ip access-list WEB
+  10 permit tcp addrgroup SERVERS any`,
+			want: map[string][]string{"3004": {
+				"rule 5 deny ip 0.0.0.0/0 -> 0.0.0.0/0",
+			}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			acls, err := ParseACLs(ParseConfig(c.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string][]string{}
+			for _, acl := range acls {
+				got[acl.Label()] = []string{}
+				for _, n := range acl.Nodes {
+					got[acl.Label()] = append(got[acl.Label()], n.String())
+				}
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q\nwant %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseACLsErrors(t *testing.T) {
+	cases := []struct {
+		name, config, err string
+	}{
+		{"discontiguous wildcard", "acl number 3000\n rule 5 permit ip source 10.0.0.0 0.255.0.255", "Discontiguous wildcard"},
+		{"invalid port", "ip access-list extended A\n permit tcp any any eq 70000", "Invalid port"},
+		{"unsupported address", "ip access-list extended A\n permit tcp any foo", "Unsupported address"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseACLs(ParseConfig(c.config))
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("error = %v, want %q", err, c.err)
+			}
+		})
+	}
+}
+
+func parseACL(t *testing.T, config string) *ACL {
+	acls, err := ParseACLs(ParseConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acls) != 1 {
+		t.Fatalf("got %d ACLs, want 1", len(acls))
+	}
+	return acls[0]
+}
+
+func TestCompareACL(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "same across vendors",
+			a: `acl advanced 3000 name WEB
+ rule 5 permit tcp source 10.0.0.0 0.0.0.255 destination 192.168.1.10 0 destination-port eq 80
+ rule 10 permit udp destination-port eq dns
+ rule 15 deny ip`,
+			b: `ip access-list extended WEB
+ 10 permit udp any any eq 53
+ 20 permit tcp 10.0.0.0 0.0.0.255 host 192.168.1.10 eq www
+ 30 deny ip any any`,
+		},
+		{
+			name: "missing and extra",
+			a: `ip access-list extended A
+ permit tcp any any eq 22
+ permit tcp any any eq 23`,
+			b: `ip access-list extended A
+ permit tcp any any eq 22
+ permit tcp any any eq 443`,
+			want: []string{
+				"A: 'rule 20 permit tcp 0.0.0.0/0 -> 0.0.0.0/0 port 23' is missing.",
+				"A: 'rule 20 permit tcp 0.0.0.0/0 -> 0.0.0.0/0 port 443' is extra.",
+			},
+		},
+		{
+			name: "shadowed",
+			a: `ip access-list extended A
+ permit ip 10.0.0.0 0.255.255.255 any
+ deny tcp 10.1.0.0 0.0.255.255 any eq 80`,
+			b: `ip access-list extended A
+ permit ip 10.0.0.0 0.255.255.255 any
+ deny tcp 10.1.0.0 0.0.255.255 any eq 80`,
+			want: []string{
+				"A: 'rule 20 deny tcp 10.1.0.0/16 -> 0.0.0.0/0 port 80' is shadowed by 'rule 10 permit ip 10.0.0.0/8 -> 0.0.0.0/0'.",
+				"A: 'rule 20 deny tcp 10.1.0.0/16 -> 0.0.0.0/0 port 80' is shadowed by 'rule 10 permit ip 10.0.0.0/8 -> 0.0.0.0/0'.",
+			},
+		},
+		{
+			name: "order of overlapped",
+			a: `ip access-list extended A
+ deny tcp 10.1.0.0 0.0.255.255 any eq 80
+ permit ip 10.0.0.0 0.255.255.255 any`,
+			b: `ip access-list extended A
+ permit ip 10.0.0.0 0.255.255.255 any
+ deny tcp 10.1.0.0 0.0.255.255 any eq 80`,
+			want: []string{
+				"A: 'rule 20 deny tcp 10.1.0.0/16 -> 0.0.0.0/0 port 80' is shadowed by 'rule 10 permit ip 10.0.0.0/8 -> 0.0.0.0/0'.",
+				"A: 'rule 10 permit ip 10.0.0.0/8 -> 0.0.0.0/0' is in the different order with 'rule 20 deny tcp 10.1.0.0/16 -> 0.0.0.0/0 port 80'.",
+			},
+		},
+		{
+			name: "order of disjoint",
+			a: `ip access-list extended A
+ deny tcp any any eq 80
+ permit udp any any`,
+			b: `ip access-list extended A
+ permit udp any any
+ deny tcp any any eq 80`,
+		},
+		{
+			name: "unsupported not comparable",
+			a: `ip access-list extended A
+ permit tcp any any established
+ deny tcp any any eq 80`,
+			b: `ip access-list extended A
+ permit tcp any any
+ deny tcp any any eq 80`,
+			want: []string{
+				"A: 'rule 10 permit tcp 0.0.0.0/0 -> 0.0.0.0/0 established' is not comparable.",
+				"A: 'rule 20 deny tcp 0.0.0.0/0 -> 0.0.0.0/0 port 80' is shadowed by 'rule 10 permit tcp 0.0.0.0/0 -> 0.0.0.0/0'.",
+				"A: 'rule 10 permit tcp 0.0.0.0/0 -> 0.0.0.0/0' is extra.",
+			},
+		},
+		{
+			name: "same unsupported",
+			a: `acl advanced 3000 name A
+ rule 5 permit icmp icmp-type echo`,
+			b: `acl advanced 3000 name A
+ rule 10 permit icmp icmp-type echo`,
+			want: []string{
+				"A: 'rule 5 permit icmp 0.0.0.0/0 -> 0.0.0.0/0 icmp-type echo' is not comparable.",
+				"A: 'rule 10 permit icmp 0.0.0.0/0 -> 0.0.0.0/0 icmp-type echo' is not comparable.",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			for _, d := range CompareACL(parseACL(t, c.a), parseACL(t, c.b)) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q\nwant %q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package nwswdb
+
+import (
+	"fmt"
+	"net"
+	nwnet "nwnet"
+	"strings"
+)
+
+/*
+CompareACL reports the semantic differences of two ACLs, which may be of
+different vendors since the nodes are normalized by ParseACLs. The indexes are
+not compared, two nodes are the same rule if they have the same action and
+match the same packets:
+
+	shadowed  a node of either ACL never matches, the packets it matches are
+	          all matched by an earlier node.
+	missing   a node of the first ACL isn't in the second one.
+	extra     a node of the second ACL isn't in the first one.
+	order     two nodes in both ACLs are in the different order, and they
+	          match some same packets with the different actions, so the
+	          packets are permitted by one ACL but denied by the other.
+
+The reorders of the nodes that don't overlap or have the same action make no
+difference and are not reported.
+
+The nodes with the unsupported conditions are reported as unsupported instead
+of missing or extra, since the packets they match are unknown. Such a node
+never shadows the others, and it's the same rule only as the node with the
+same conditions.
+*/
+
+// The kinds of ACLDiff.
+const (
+	ACLShadowed    = "shadowed"
+	ACLMissing     = "missing"
+	ACLExtra       = "extra"
+	ACLOrder       = "order"
+	ACLUnsupported = "unsupported"
+)
+
+// ACLDiff is a difference of two ACLs. Node is in the ACL named ACL, Other
+// is the node shadowing it or reordered with it.
+type ACLDiff struct {
+	Kind  string
+	ACL   string
+	Node  *ACLNode
+	Other *ACLNode
+}
+
+func (d ACLDiff) String() string {
+	switch d.Kind {
+	case ACLShadowed:
+		return fmt.Sprintf("%s: '%s' is shadowed by '%s'.", d.ACL, d.Node, d.Other)
+	case ACLMissing:
+		return fmt.Sprintf("%s: '%s' is missing.", d.ACL, d.Node)
+	case ACLExtra:
+		return fmt.Sprintf("%s: '%s' is extra.", d.ACL, d.Node)
+	case ACLOrder:
+		return fmt.Sprintf("%s: '%s' is in the different order with '%s'.", d.ACL, d.Node, d.Other)
+	case ACLUnsupported:
+		return fmt.Sprintf("%s: '%s' is not comparable.", d.ACL, d.Node)
+	}
+	return fmt.Sprintf("%s: %s '%s'.", d.ACL, d.Kind, d.Node)
+}
+
+// CompareACL returns the differences of a and b, the missing and the extra
+// nodes are in a and b respectively.
+func CompareACL(a, b *ACL) []ACLDiff {
+	diffs := append(unsupportedNodes(a), unsupportedNodes(b)...)
+	diffs = append(diffs, ShadowedNodes(a)...)
+	diffs = append(diffs, ShadowedNodes(b)...)
+	// position of the same rule in b, the nodes only in a are -1.
+	positions := make([]int, len(a.Nodes))
+	used := make([]bool, len(b.Nodes))
+	for i, n := range a.Nodes {
+		positions[i] = -1
+		for j, m := range b.Nodes {
+			if !used[j] && sameRule(n, m) {
+				positions[i], used[j] = j, true
+				break
+			}
+		}
+		if positions[i] < 0 && len(n.Unsupported) == 0 {
+			diffs = append(diffs, ACLDiff{Kind: ACLMissing, ACL: b.Label(), Node: n})
+		}
+	}
+	for j, m := range b.Nodes {
+		if !used[j] && len(m.Unsupported) == 0 {
+			diffs = append(diffs, ACLDiff{Kind: ACLExtra, ACL: b.Label(), Node: m})
+		}
+	}
+	for i, n := range a.Nodes {
+		for k := i + 1; k < len(a.Nodes); k++ {
+			m := a.Nodes[k]
+			if positions[i] < 0 || positions[k] < 0 || positions[i] < positions[k] {
+				continue
+			}
+			if n.Permitted != m.Permitted && overlaps(n, m) {
+				diffs = append(diffs, ACLDiff{Kind: ACLOrder, ACL: b.Label(), Node: b.Nodes[positions[k]], Other: b.Nodes[positions[i]]})
+			}
+		}
+	}
+	return diffs
+}
+
+// ShadowedNodes returns the nodes of acl that never match.
+func ShadowedNodes(acl *ACL) []ACLDiff {
+	var diffs []ACLDiff
+	for i, n := range acl.Nodes {
+		for _, m := range acl.Nodes[:i] {
+			if covers(m, n) {
+				diffs = append(diffs, ACLDiff{Kind: ACLShadowed, ACL: acl.Label(), Node: n, Other: m})
+				break
+			}
+		}
+	}
+	return diffs
+}
+
+func unsupportedNodes(acl *ACL) []ACLDiff {
+	var diffs []ACLDiff
+	for _, n := range acl.Nodes {
+		if len(n.Unsupported) > 0 {
+			diffs = append(diffs, ACLDiff{Kind: ACLUnsupported, ACL: acl.Label(), Node: n})
+		}
+	}
+	return diffs
+}
+
+// sameRule reports whether n and m have the same action and match the same
+// packets.
+func sameRule(n, m *ACLNode) bool {
+	if strings.Join(n.Unsupported, " ") != strings.Join(m.Unsupported, " ") {
+		return false
+	}
+	return n.Permitted == m.Permitted && covers(n, m) && covers(m, n)
+}
+
+// covers reports whether all the packets matched by m are matched by n, it's
+// unknown if n has the unsupported conditions, unless m has the same ones.
+func covers(n, m *ACLNode) bool {
+	if n.VRF != m.VRF || (n.Protocol != anyProtocol && n.Protocol != m.Protocol) {
+		return false
+	}
+	if len(n.Unsupported) > 0 && strings.Join(n.Unsupported, " ") != strings.Join(m.Unsupported, " ") {
+		return false
+	}
+	if !prefixCovers(n.SrcAddress, m.SrcAddress) || !prefixCovers(n.DstAddress, m.DstAddress) {
+		return false
+	}
+	// The ports of m are of no use if it's not a protocol with ports.
+	if n.SrcPort == "" && n.DstPort == "" {
+		return true
+	}
+	return n.Protocol == m.Protocol && portsCover(n.SrcPort, m.SrcPort) && portsCover(n.DstPort, m.DstPort)
+}
+
+// overlaps reports whether some packets are matched by both n and m.
+func overlaps(n, m *ACLNode) bool {
+	if n.VRF != m.VRF {
+		return false
+	}
+	if n.Protocol != anyProtocol && m.Protocol != anyProtocol && n.Protocol != m.Protocol {
+		return false
+	}
+	if !prefixOverlaps(n.SrcAddress, m.SrcAddress) || !prefixOverlaps(n.DstAddress, m.DstAddress) {
+		return false
+	}
+	return portsOverlap(n.SrcPort, m.SrcPort) && portsOverlap(n.DstPort, m.DstPort)
+}
+
+func prefixCovers(p, q nwnet.V4Prefix) bool {
+	pOnes, _ := p.Mask.Size()
+	qOnes, _ := q.Mask.Size()
+	ipnet := net.IPNet(p)
+	return pOnes <= qOnes && ipnet.Contains(q.IP)
+}
+
+func prefixOverlaps(p, q nwnet.V4Prefix) bool {
+	return prefixCovers(p, q) || prefixCovers(q, p)
+}
+
+// portsCover reports whether the ranges of p contain all the ports of q.
+func portsCover(p, q string) bool {
+	ranges := parsePortRanges(p)
+	for _, r := range parsePortRanges(q) {
+		covered := false
+		for _, c := range ranges {
+			if c[0] <= r[0] && r[1] <= c[1] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func portsOverlap(p, q string) bool {
+	for _, r := range parsePortRanges(p) {
+		for _, c := range parsePortRanges(q) {
+			if r[0] <= c[1] && c[0] <= r[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,349 @@
+package nwswdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	nwnet "nwnet"
+	"nwparse"
+	"nwssh"
+	"strconv"
+	"strings"
+)
+
+/*
+CollectBGP reads the peers in the BGP peer table and merges in the peer
+groups, the remote AS, the connect interfaces and the policies in the running
+configuration:
+
+	H3C     display bgp peer ipv4        bgp <AS>
+	                                      peer <peer> group|as-number|
+	                                       connect-interface|route-policy|ignore
+	HUAWEI  display bgp peer             the same as H3C
+	CISCO   show ip bgp summary          router bgp <AS>
+	RUIJIE                                neighbor <peer> peer-group|remote-as|
+	                                       update-source|route-map|shutdown
+	NEXUS   show ip bgp summary          router bgp <AS>
+	                                      template peer <group>
+	                                      neighbor <peer>
+	                                       inherit peer|remote-as|update-source|
+	                                       route-map|shutdown
+
+The peers only in the configuration have no status, the peers of the VPN
+instances (VRF) are not read. The status of a group is the lowest of its
+peers, so Established means all of them are established.
+It's run by the transaction 'bgpneighbors', which prints the BGP as JSON.
+*/
+
+var bgpPeerCommands = map[string]string{
+	"H3C":    "display bgp peer ipv4",
+	"HUAWEI": "display bgp peer",
+	"CISCO":  "show ip bgp summary",
+	"NEXUS":  "show ip bgp summary",
+	"RUIJIE": "show ip bgp summary",
+}
+
+var bgpStatusNames = []string{"", "Idle", "Connect", "Active", "OpenSent", "OpenConfirm", "Established"}
+
+func (s BGPSTATUS) String() string {
+	if int(s) < len(bgpStatusNames) {
+		return bgpStatusNames[s]
+	}
+	return strconv.Itoa(int(s))
+}
+
+func (s BGPSTATUS) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *BGPSTATUS) UnmarshalText(text []byte) error {
+	if n, err := strconv.Atoi(string(text)); err == nil {
+		*s = BGPSTATUS(n)
+		return nil
+	}
+	*s = bgpStatus(string(text))
+	return nil
+}
+
+// bgpStatus returns the status of a state like 'Established' or
+// 'Idle(Admin)', it's 0 if unknown.
+func bgpStatus(state string) BGPSTATUS {
+	for i, name := range bgpStatusNames {
+		if name != "" && strings.HasPrefix(strings.ToLower(state), strings.ToLower(name)) {
+			return BGPSTATUS(i)
+		}
+	}
+	return 0
+}
+
+// asNumber returns the AS number in the plain or the dot format like
+// '1.10'.
+func asNumber(s string) int {
+	if high, low, ok := strings.Cut(s, "."); ok {
+		return atoi(high)<<16 + atoi(low)
+	}
+	return atoi(s)
+}
+
+func init() {
+	nwssh.Transactions["bgpneighbors"] = bgpNeighbors
+}
+
+// bgpNeighbors is the transaction 'bgpneighbors'.
+func bgpNeighbors(dev nwssh.SSHBASE, vendor string) (string, error) {
+	bgp, err := CollectBGP(dev, vendor, nil)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(bgp, "", "  ")
+	return string(data), err
+}
+
+// CollectBGP returns the BGP of dev, the session should be prepared by
+// SessionPreparation. The templates of index are used to parse the peer
+// table, the builtin templates of nwparse are used if it's nil.
+func CollectBGP(dev nwssh.SSHBASE, vendor string, index *nwparse.Index) (*BGP, error) {
+	cmd, ok := bgpPeerCommands[vendor]
+	if !ok {
+		return nil, fmt.Errorf("No BGP collector for the vendor '%s'.", vendor)
+	}
+	var err error
+	if index == nil {
+		if index, err = nwparse.Builtin(); err != nil {
+			return nil, err
+		}
+	}
+	records, err := collectRecords(dev, index, vendor, cmd)
+	if err != nil {
+		return nil, err
+	}
+	config, err := dev.RunningConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the BGP configuration. %v", err)
+	}
+	return mergeBGP(parseBGPConfig(ParseConfig(config)), records), nil
+}
+
+// bgpNeighbor is a peer or a peer group in the configuration.
+type bgpNeighbor struct {
+	name      string
+	isGroup   bool
+	groupType string
+	remoteAS  int
+	connectIf string
+	group     string
+	imports   []string
+	exports   []string
+	disabled  bool
+}
+
+type bgpConfig struct {
+	asNum     int
+	routerID  string
+	neighbors []*bgpNeighbor
+	byName    map[string]*bgpNeighbor
+}
+
+// parseBGPConfig reads the section 'bgp <AS>' or 'router bgp <AS>' of root.
+func parseBGPConfig(root *Section) *bgpConfig {
+	c := &bgpConfig{byName: map[string]*bgpNeighbor{}}
+	section := root.Lookup("bgp")
+	if section == nil {
+		section = root.Lookup("router bgp")
+	}
+	if section == nil {
+		return c
+	}
+	words := strings.Fields(section.Line)
+	c.asNum = asNumber(words[len(words)-1])
+	if words[0] == "bgp" {
+		c.asNum = asNumber(words[1])
+	}
+	c.walk(section, nil)
+	return c
+}
+
+func (c *bgpConfig) neighbor(name string) *bgpNeighbor {
+	if n, ok := c.byName[name]; ok {
+		return n
+	}
+	n := &bgpNeighbor{name: name}
+	c.byName[name] = n
+	c.neighbors = append(c.neighbors, n)
+	return n
+}
+
+// walk reads the lines under s, the lines without the peer are of subject,
+// which is the section 'neighbor <peer>' or 'template peer <group>' of Nexus.
+func (c *bgpConfig) walk(s *Section, subject *bgpNeighbor) {
+	for _, child := range s.Children {
+		words := strings.Fields(child.Line)
+		switch {
+		case words[0] == "router-id" && len(words) > 1:
+			c.routerID = words[1]
+		case words[0] == "bgp" && len(words) > 2 && words[1] == "router-id":
+			c.routerID = words[2]
+		case words[0] == "group" && len(words) > 1:
+			g := c.neighbor(words[1])
+			g.isGroup = true
+			if len(words) > 2 {
+				g.groupType = words[2]
+			}
+		case words[0] == "vrf" || hasWords(child.Line, "ip vpn-instance") ||
+			hasWords(child.Line, "ipv4-family vpn-instance") || hasWords(child.Line, "address-family ipv4 vrf"):
+			continue
+		case (words[0] == "peer" || words[0] == "neighbor") && len(words) > 2:
+			n := c.neighbor(words[1])
+			c.apply(n, words[2:])
+			c.walk(child, n)
+			continue
+		case words[0] == "neighbor" && len(words) == 2:
+			c.walk(child, c.neighbor(words[1]))
+			continue
+		case words[0] == "template" && len(words) == 3 && words[1] == "peer":
+			g := c.neighbor(words[2])
+			g.isGroup = true
+			c.walk(child, g)
+			continue
+		case subject != nil:
+			c.apply(subject, words)
+		}
+		c.walk(child, subject)
+	}
+}
+
+// apply applies the attribute of a peer or a peer group, such as
+// 'as-number 65001' or 'route-map IN in'.
+func (c *bgpConfig) apply(n *bgpNeighbor, attr []string) {
+	switch {
+	case attr[0] == "ignore" || attr[0] == "shutdown":
+		n.disabled = true
+	case attr[0] == "peer-group" && len(attr) == 1:
+		n.isGroup = true
+	case (attr[0] == "group" || attr[0] == "peer-group") && len(attr) > 1:
+		n.group = attr[1]
+		if len(attr) > 3 && attr[2] == "as-number" {
+			n.remoteAS = asNumber(attr[3])
+		}
+	case attr[0] == "inherit" && len(attr) > 2 && attr[1] == "peer":
+		n.group = attr[2]
+	case (attr[0] == "as-number" || attr[0] == "remote-as") && len(attr) > 1:
+		n.remoteAS = asNumber(attr[1])
+	case (attr[0] == "connect-interface" || attr[0] == "update-source") && len(attr) > 1:
+		n.connectIf = strings.Join(attr[1:], " ")
+	case (attr[0] == "route-policy" || attr[0] == "route-map") && len(attr) > 2:
+		switch attr[2] {
+		case "import", "in":
+			n.imports = append(n.imports, attr[1])
+		case "export", "out":
+			n.exports = append(n.exports, attr[1])
+		}
+	}
+}
+
+// peerAddress returns the address of a peer, ok is false if it's not an IP
+// address, such as the name of a group.
+func peerAddress(s string) (nwnet.V4Prefix, bool) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nwnet.V4Prefix{}, false
+	}
+	bits := 128
+	if ip.To4() != nil {
+		bits = 32
+	}
+	return nwnet.V4Prefix{IP: ip, Mask: net.CIDRMask(bits, bits)}, true
+}
+
+// mergeBGP merges the peers in the peer table into the configuration, the
+// policies and the connect interface of a group apply to its peers without
+// their own.
+func mergeBGP(c *bgpConfig, records []record) *BGP {
+	bgp := &BGP{ASNum: c.asNum, RouterID: c.routerID}
+	groups := map[string]*BGPPeerGroup{}
+	for _, n := range c.neighbors {
+		if !n.isGroup {
+			continue
+		}
+		g := &BGPPeerGroup{Name: n.name, Type: n.groupType, RemoteAS: n.remoteAS, ConnectIf: n.connectIf, ImportPolicy: n.imports, ExportPolicy: n.exports}
+		if g.Type == "" && g.RemoteAS != 0 {
+			g.Type = "external"
+			if g.RemoteAS == c.asNum {
+				g.Type = "internal"
+			}
+		}
+		groups[n.name] = g
+		bgp.PeerGroup = append(bgp.PeerGroup, g)
+	}
+
+	peers := map[string]*BGPPeer{}
+	addPeer := func(name string) *BGPPeer {
+		if p, ok := peers[name]; ok {
+			return p
+		}
+		address, ok := peerAddress(name)
+		if !ok {
+			return nil
+		}
+		p := &BGPPeer{Address: address, Enabled: true}
+		if n, ok := c.byName[name]; ok && !n.isGroup {
+			p.RemoteAS, p.Enabled, p.ConnectIf = n.remoteAS, !n.disabled, n.connectIf
+			p.ImportPolicy, p.ExportPolicy = n.imports, n.exports
+			if g, ok := groups[n.group]; ok {
+				p.PeerGroup = g
+				if p.RemoteAS == 0 {
+					p.RemoteAS = g.RemoteAS
+				}
+				if p.ConnectIf == "" {
+					p.ConnectIf = g.ConnectIf
+				}
+				if p.ImportPolicy == nil {
+					p.ImportPolicy = g.ImportPolicy
+				}
+				if p.ExportPolicy == nil {
+					p.ExportPolicy = g.ExportPolicy
+				}
+			}
+		}
+		peers[name] = p
+		bgp.Peers = append(bgp.Peers, p)
+		return p
+	}
+
+	for _, r := range records {
+		p := addPeer(str(r, "NEIGHBOR"))
+		if p == nil {
+			continue
+		}
+		if bgp.ASNum == 0 {
+			bgp.ASNum = asNumber(str(r, "LOCAL_AS"))
+		}
+		if bgp.RouterID == "" {
+			bgp.RouterID = str(r, "ROUTER_ID")
+		}
+		p.RemoteAS = asNumber(str(r, "AS"))
+		p.Uptime = str(r, "UPTIME")
+		p.Status = bgpStatus(str(r, "STATE"))
+		p.PrefixReceived = atoi(str(r, "PREFIXES"))
+		// The last column of Cisco is the prefixes if established.
+		if state, ok := r["STATE_PFXRCD"]; ok {
+			if n, err := strconv.Atoi(state.(string)); err == nil {
+				p.Status, p.PrefixReceived = Established, n
+			} else {
+				p.Status = bgpStatus(state.(string))
+			}
+		}
+	}
+	for _, n := range c.neighbors {
+		if !n.isGroup {
+			addPeer(n.name)
+		}
+	}
+
+	for _, p := range bgp.Peers {
+		if g := p.PeerGroup; g != nil && p.Status != 0 && (g.Status == 0 || p.Status < g.Status) {
+			g.Status = p.Status
+		}
+	}
+	return bgp
+}
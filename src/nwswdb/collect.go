@@ -0,0 +1,482 @@
+package nwswdb
+
+import (
+	"fmt"
+	"math"
+	"nwparse"
+	"nwssh"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Collect fills a NetworkDevice with the outputs of the commands below, they
+are parsed by the templates of nwparse:
+
+	H3C     display device manuinfo
+	        display interface brief
+	        display link-aggregation verbose
+	HUAWEI  display device manufacture-info
+	        display interface description
+	        display eth-trunk
+	CISCO   show inventory
+	        show interfaces description
+	        show etherchannel summary
+	NEXUS   show inventory
+	        show interface brief
+	        show interface description
+	        show port-channel summary
+	RUIJIE  show interface description
+	        show aggregatePort summary
+
+The member devices are the slots of a box device (the members of IRF or a
+stack) or the chassis, the physical ports are put on the line cards by the
+numbers in their names. The hardware of Ruijie is not collected, its member
+devices are the serials in the facts.
+*/
+
+// module is a piece of hardware in the hardware command, the kind is member,
+// card, fan or power. Member 0 means the first member device.
+type module struct {
+	kind    string
+	member  int
+	slot    int
+	subslot int
+	name    string
+	serial  string
+}
+
+// ifStatus is the interface merged from the records of the interface
+// commands.
+type ifStatus struct {
+	name        string
+	short       string
+	kind        string
+	description string
+	admin       bool
+	oper        bool
+}
+
+type record = map[string]interface{}
+
+type collector struct {
+	hardware   string
+	interfaces []string
+	aggregates string
+	modules    func([]record) []module
+	status     func(record, *ifStatus)
+	locate     func([]int) (portLocation, bool)
+}
+
+var collectors = map[string]collector{
+	"H3C": {
+		hardware:   "display device manuinfo",
+		interfaces: []string{"display interface brief"},
+		aggregates: "display link-aggregation verbose",
+		modules:    h3cModules,
+		status:     h3cStatus,
+		locate:     boxLocation,
+	},
+	"HUAWEI": {
+		hardware:   "display device manufacture-info",
+		interfaces: []string{"display interface description"},
+		aggregates: "display eth-trunk",
+		modules:    huaweiModules,
+		status:     huaweiStatus,
+		locate:     boxLocation,
+	},
+	"CISCO": {
+		hardware:   "show inventory",
+		interfaces: []string{"show interfaces description"},
+		aggregates: "show etherchannel summary",
+		modules:    inventoryModules,
+		status:     ciscoStatus,
+		locate:     boxLocation,
+	},
+	"NEXUS": {
+		hardware:   "show inventory",
+		interfaces: []string{"show interface brief", "show interface description"},
+		aggregates: "show port-channel summary",
+		modules:    inventoryModules,
+		status:     nexusStatus,
+		locate:     chassisLocation,
+	},
+	"RUIJIE": {
+		interfaces: []string{"show interface description"},
+		aggregates: "show aggregatePort summary",
+		status:     ruijieStatus,
+		locate:     boxLocation,
+	},
+}
+
+// Collect returns the NetworkDevice of dev, the session should be prepared by
+// SessionPreparation. The templates of index are used to parse the outputs,
+// the builtin templates of nwparse are used if it's nil.
+func Collect(dev nwssh.SSHBASE, index *nwparse.Index) (*NetworkDevice, error) {
+	facts, err := dev.GetFacts()
+	if err != nil {
+		return nil, err
+	}
+	c, ok := collectors[facts.Vendor]
+	if !ok {
+		return nil, fmt.Errorf("No collector for the vendor '%s'.", facts.Vendor)
+	}
+	if index == nil {
+		if index, err = nwparse.Builtin(); err != nil {
+			return nil, err
+		}
+	}
+
+	device := &NetworkDevice{Name: facts.Hostname, MgtAddr: facts.Host, Vendor: facts.Vendor}
+	if facts.MgmtIP != facts.Host {
+		device.ObbMgtAddr = facts.MgmtIP
+	}
+	b := &builder{device: device, vendor: facts.Vendor, locate: c.locate, byName: map[string]*ifStatus{}}
+
+	if c.hardware != "" {
+		records, err := collectRecords(dev, index, facts.Vendor, c.hardware)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range c.modules(records) {
+			b.addModule(m)
+		}
+	}
+	b.addSerials(facts.Serials)
+
+	for _, cmd := range c.interfaces {
+		records, err := collectRecords(dev, index, facts.Vendor, cmd)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			if name := str(r, "INTERFACE"); name != "" {
+				c.status(r, b.status(name))
+			}
+		}
+	}
+
+	records, err := collectRecords(dev, index, facts.Vendor, c.aggregates)
+	if err != nil {
+		return nil, err
+	}
+	members := map[string][]string{}
+	for _, r := range records {
+		aggregate := b.status(str(r, "AGGREGATE"))
+		for _, port := range aggregatePorts(r["PORTS"]) {
+			members[aggregate.name] = append(members[aggregate.name], b.status(port).name)
+		}
+	}
+
+	b.addInterfaces(members)
+	return device, nil
+}
+
+func collectRecords(dev nwssh.SSHBASE, index *nwparse.Index, vendor, cmd string) ([]record, error) {
+	resp, err := dev.ExecCommandExpectPrompt(cmd, time.Second*30)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to collect by '%s'. %w", cmd, err)
+	}
+	result, err := index.Parse(vendor, cmd, dev.Sanitize(cmd, resp))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the output of '%s'. %v", cmd, err)
+	}
+	return result.Maps(), nil
+}
+
+// str returns the value of a record, the lists are joined by ','.
+func str(r record, name string) string {
+	switch v := r[name].(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return ""
+}
+
+var portFlags = regexp.MustCompile(`\(\w*\)$`)
+
+// aggregatePorts returns the member ports in a list or separated by ',', the
+// flags like 'Gi1/0/1(P)' are removed.
+func aggregatePorts(v interface{}) []string {
+	var ports []string
+	switch v := v.(type) {
+	case string:
+		ports = strings.Split(v, ",")
+	case []string:
+		ports = v
+	}
+	var names []string
+	for _, p := range ports {
+		if p = portFlags.ReplaceAllString(strings.TrimSpace(p), ""); p != "" {
+			names = append(names, p)
+		}
+	}
+	return names
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+var firstNumber = regexp.MustCompile(`\d+`)
+
+// number returns the first number in s, it's 0 if there's no number.
+func number(s string) int {
+	return atoi(firstNumber.FindString(s))
+}
+
+func h3cModules(records []record) []module {
+	var modules []module
+	for _, r := range records {
+		chassis, slot := str(r, "CHASSIS"), atoi(str(r, "SLOT"))
+		member := slot
+		if chassis != "" {
+			member = atoi(chassis)
+		}
+		m := module{member: member, slot: atoi(str(r, "NUMBER")), name: str(r, "NAME"), serial: str(r, "SERIAL")}
+		switch str(r, "KIND") {
+		case "Slot":
+			if chassis == "" {
+				modules = append(modules, module{kind: "member", member: member, serial: m.serial})
+			}
+			m.kind = "card"
+		case "Fan":
+			m.kind = "fan"
+		case "Power":
+			m.kind = "power"
+		}
+		modules = append(modules, m)
+	}
+	return modules
+}
+
+var (
+	huaweiFan   = regexp.MustCompile(`(?i)^FAN`)
+	huaweiPower = regexp.MustCompile(`(?i)^(PWR|PSU|POWER)`)
+)
+
+func huaweiModules(records []record) []module {
+	var modules []module
+	for _, r := range records {
+		slot, card := atoi(str(r, "SLOT")), str(r, "CARD")
+		m := module{member: slot, slot: slot, name: str(r, "TYPE"), serial: str(r, "SERIAL")}
+		switch {
+		case card == "-":
+			modules = append(modules, module{kind: "member", member: slot, serial: m.serial})
+			m.kind = "card"
+		case huaweiFan.MatchString(card):
+			m.kind, m.slot = "fan", number(card)
+		case huaweiPower.MatchString(card):
+			m.kind, m.slot = "power", number(card)
+		default:
+			m.kind, m.subslot = "card", number(card)
+		}
+		modules = append(modules, m)
+	}
+	return modules
+}
+
+var (
+	inventoryStack   = regexp.MustCompile(`^(?:Switch )?(\d+)$`)
+	inventoryChassis = regexp.MustCompile(`(?i)^chassis$`)
+	inventorySlot    = regexp.MustCompile(`(?i)^slot (\d+)$`)
+	inventoryPower   = regexp.MustCompile(`(?i)power supply\D*(\d+)|\bPS-?(\d+)`)
+	inventoryFan     = regexp.MustCompile(`(?i)\bfan\b\D*(\d+)`)
+	inventorySwitch  = regexp.MustCompile(`^Switch (\d+)`)
+)
+
+// inventoryModules converts the records of 'show inventory', the switches of
+// a stack are named '1' or 'Switch 1', the line cards of Nexus 'Slot 1'.
+func inventoryModules(records []record) []module {
+	var modules []module
+	for _, r := range records {
+		name := str(r, "NAME")
+		m := module{name: str(r, "PID"), serial: str(r, "SN")}
+		if s := inventorySwitch.FindStringSubmatch(name); s != nil {
+			m.member = atoi(s[1])
+		}
+		switch {
+		case inventoryStack.MatchString(name):
+			member := number(name)
+			modules = append(modules, module{kind: "member", member: member, serial: m.serial})
+			m.kind, m.member, m.slot = "card", member, member
+		case inventoryChassis.MatchString(name):
+			m.kind = "member"
+		case inventorySlot.MatchString(name):
+			m.kind, m.slot = "card", number(name)
+		case inventoryPower.MatchString(name):
+			s := inventoryPower.FindStringSubmatch(name)
+			m.kind, m.slot = "power", atoi(s[1]+s[2])
+		case inventoryFan.MatchString(name):
+			m.kind, m.slot = "fan", atoi(inventoryFan.FindStringSubmatch(name)[1])
+		default:
+			continue
+		}
+		modules = append(modules, m)
+	}
+	return modules
+}
+
+// h3cStatus reads 'display interface brief', the link is ADM if the interface
+// is shut down.
+func h3cStatus(r record, st *ifStatus) {
+	link := strings.ToUpper(str(r, "LINK"))
+	st.admin = link != "ADM"
+	st.oper = link == "UP"
+	st.description = str(r, "DESCRIPTION")
+}
+
+// huaweiStatus reads 'display interface description', the PHY is '*down' if
+// the interface is shut down.
+func huaweiStatus(r record, st *ifStatus) {
+	phy := str(r, "PHY")
+	st.admin = !strings.HasPrefix(phy, "*")
+	st.oper = phy == "up"
+	st.description = str(r, "DESCRIPTION")
+}
+
+func ciscoStatus(r record, st *ifStatus) {
+	st.admin = str(r, "STATUS") != "admin down"
+	st.oper = str(r, "PROTOCOL") == "up"
+	st.description = str(r, "DESCRIPTION")
+}
+
+// nexusStatus reads the status in 'show interface brief' and the description
+// in 'show interface description', the empty description is '--'.
+func nexusStatus(r record, st *ifStatus) {
+	if status, ok := r["STATUS"]; ok {
+		st.admin = str(r, "REASON") != "Administratively down"
+		st.oper = status == "up"
+	}
+	if description, ok := r["DESCRIPTION"]; ok && description != "--" {
+		st.description = str(r, "DESCRIPTION")
+	}
+}
+
+func ruijieStatus(r record, st *ifStatus) {
+	st.admin = str(r, "ADMIN") == "up"
+	st.oper = str(r, "STATUS") == "up"
+	st.description = str(r, "DESCRIPTION")
+}
+
+type builder struct {
+	device   *NetworkDevice
+	vendor   string
+	locate   func([]int) (portLocation, bool)
+	statuses []*ifStatus
+	byName   map[string]*ifStatus
+}
+
+// member returns the member device numbered num, it's added if not found.
+func (b *builder) member(num int) *HardDevice {
+	devices := b.device.MemberDevice
+	if num == 0 && len(devices) > 0 {
+		return devices[0]
+	}
+	if num == 0 {
+		num = 1
+	}
+	for _, h := range devices {
+		if int(h.ChassisNum) == num {
+			return h
+		}
+	}
+	h := &HardDevice{ChassisNum: int8(num)}
+	b.device.MemberDevice = append(devices, h)
+	return h
+}
+
+// lineCard returns the line card of the member device h, it's added if not
+// found.
+func (b *builder) lineCard(h *HardDevice, slot, subslot int) *LineCard {
+	for _, lc := range h.MemberLineCard {
+		if lc.SlotNum == slot && lc.SubslotNum == subslot {
+			return lc
+		}
+	}
+	lc := &LineCard{SlotNum: slot, SubslotNum: subslot}
+	h.MemberLineCard = append(h.MemberLineCard, lc)
+	return lc
+}
+
+func (b *builder) addModule(m module) {
+	h := b.member(m.member)
+	switch m.kind {
+	case "member":
+		h.SerailNum = m.serial
+	case "card":
+		lc := b.lineCard(h, m.slot, m.subslot)
+		lc.Name, lc.SerailNum = m.name, m.serial
+	case "fan":
+		h.MemberFan = append(h.MemberFan, &FanModule{Name: m.name, SerailNum: m.serial, SlotNum: m.slot, SubslotNum: m.subslot})
+	case "power":
+		h.MemberPower = append(h.MemberPower, &PowerModule{Name: m.name, SerailNum: m.serial, SlotNum: m.slot, SubslotNum: m.subslot})
+	}
+}
+
+// addSerials sets the serials in the facts to the member devices without
+// serial, the member is the number in the name like 'Slot 1'.
+func (b *builder) addSerials(serials []nwssh.Serial) {
+	for _, s := range serials {
+		if h := b.member(number(s.Member)); h.SerailNum == "" {
+			h.SerailNum = s.Number
+		}
+	}
+}
+
+// status returns the interface of name, the short and the full names are the
+// same interface.
+func (b *builder) status(name string) *ifStatus {
+	full, short, kind := ifName(b.vendor, name)
+	if st, ok := b.byName[full]; ok {
+		return st
+	}
+	st := &ifStatus{name: full, short: short, kind: kind}
+	b.byName[full] = st
+	b.statuses = append(b.statuses, st)
+	return st
+}
+
+// addInterfaces adds the interfaces in the order of the outputs, members are
+// the full names of the ports of the aggregated interfaces.
+func (b *builder) addInterfaces(members map[string][]string) {
+	ports := map[string]*PhysicalInterface{}
+	aggregates := map[string]*AggregatedInterface{}
+	for _, st := range b.statuses {
+		switch st.kind {
+		case kindPhysical:
+			p := &PhysicalInterface{Name: st.name, Description: st.description, ShortName: st.short, OperStatus: st.oper, AdminStatus: st.admin}
+			ports[st.name] = p
+			loc, ok := b.locate(ifNumbers(st.name))
+			if !ok {
+				continue
+			}
+			// The ports numbered over int8 are put on the line card without number.
+			if loc.port <= math.MaxInt8 {
+				p.PortNum = int8(loc.port)
+			}
+			lc := b.lineCard(b.member(loc.member), loc.slot, loc.subslot)
+			lc.MemberPort = append(lc.MemberPort, p)
+		case kindAggregate:
+			a := &AggregatedInterface{AggrNum: number(st.short), Description: st.description, ShortName: st.short, OperStatus: st.oper, AdminStatus: st.admin}
+			aggregates[st.name] = a
+			b.device.MemberAggr = append(b.device.MemberAggr, a)
+		case kindVlan:
+			// The VLAN ID doesn't fit in PortNum, it's in the names.
+			v := &VlanInterface{Name: st.name, Description: st.description, ShortName: st.short, OperStatus: st.oper, AdminStatus: st.admin}
+			b.device.MemberVlanIf = append(b.device.MemberVlanIf, v)
+		}
+	}
+	for name, a := range aggregates {
+		for _, port := range members[name] {
+			if p, ok := ports[port]; ok {
+				a.MemberPort = append(a.MemberPort, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package nwswdb
+
+import (
+	"strings"
+)
+
+/*
+ParseConfig splits a running configuration into a tree of sections by the
+indentation, the lines indented under a line are its children:
+
+	#
+	interface Ten-GigabitEthernet1/0/1
+	 port link-type trunk
+	#
+	bgp 65001
+	 peer 10.0.0.1 as-number 65002
+	 #
+	 address-family ipv4 unicast
+	  peer 10.0.0.1 enable
+	#
+
+The separators ('#' of H3C and Huawei, '!' of Cisco) and the comments are
+dropped, so are 'return' and 'end' at the end. The same parser works for the
+configurations of H3C, Huawei, Cisco, Nexus and Ruijie.
+*/
+
+// Section is a line of the configuration and the lines under it. The root
+// section returned by ParseConfig has no line.
+type Section struct {
+	Line     string
+	Children []*Section
+	Parent   *Section
+	indent   int
+}
+
+// ParseConfig returns the root section of config.
+func ParseConfig(config string) *Section {
+	root := &Section{indent: -1}
+	stack := []*Section{root}
+	for _, raw := range strings.Split(config, "\n") {
+		raw = strings.TrimRight(raw, " \t\r")
+		line := strings.TrimLeft(raw, " ")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		indent := len(raw) - len(line)
+		if indent == 0 && (line == "return" || line == "end") {
+			continue
+		}
+		for stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		s := &Section{Line: line, Parent: parent, indent: indent}
+		parent.Children = append(parent.Children, s)
+		stack = append(stack, s)
+	}
+	return root
+}
+
+// hasWords reports whether the words of line start with the words of prefix,
+// 'interface GE1/0/1' starts with 'interface' but 'interfaces' doesn't.
+func hasWords(line, prefix string) bool {
+	words, prefixes := strings.Fields(line), strings.Fields(prefix)
+	if len(prefixes) > len(words) {
+		return false
+	}
+	for i, p := range prefixes {
+		if words[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// Sections returns the children starting with the words of prefix, such as
+// 'interface', 'vlan', 'bgp', 'acl', 'route-policy' or 'router bgp'.
+func (s *Section) Sections(prefix string) []*Section {
+	var sections []*Section
+	for _, c := range s.Children {
+		if hasWords(c.Line, prefix) {
+			sections = append(sections, c)
+		}
+	}
+	return sections
+}
+
+// Lookup returns the section by path, each element of the path matches the
+// first child starting with its words. For example:
+//
+//	root.Lookup("interface Ten-GigabitEthernet1/0/1")
+//	root.Lookup("bgp", "address-family ipv4")
+//
+// It returns nil if the section is not found.
+func (s *Section) Lookup(path ...string) *Section {
+	section := s
+	for _, p := range path {
+		var next *Section
+		for _, c := range section.Children {
+			if hasWords(c.Line, p) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		section = next
+	}
+	return section
+}
+
+// Path returns the lines from the top section to s.
+func (s *Section) Path() []string {
+	var path []string
+	for p := s; p != nil && p.Parent != nil; p = p.Parent {
+		path = append([]string{p.Line}, path...)
+	}
+	return path
+}
+
+// Lines returns the line of s and the lines under it with the original
+// indentation.
+func (s *Section) Lines() []string {
+	var lines []string
+	if s.Parent != nil {
+		lines = append(lines, strings.Repeat(" ", s.indent)+s.Line)
+	}
+	for _, c := range s.Children {
+		lines = append(lines, c.Lines()...)
+	}
+	return lines
+}
+
+// String returns the lines of s.
+func (s *Section) String() string {
+	return strings.Join(s.Lines(), "\n")
+}
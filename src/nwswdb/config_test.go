@@ -0,0 +1,236 @@
+package nwswdb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const h3cConfig = `#
+ version 7.1.070, Release 6616
+#
+ sysname SW1
+#
+interface Vlan-interface10
+ ip address 10.0.10.1 255.255.255.0
+#
+interface Ten-GigabitEthernet1/0/1
+ port link-type trunk
+ port trunk permit vlan 10 20
+#
+bgp 65001
+ peer 10.0.0.2 as-number 65002
+ #
+ address-family ipv4 unicast
+  peer 10.0.0.2 enable
+#
+interfaces-profile A
+#
+return`
+
+const huaweiConfig = `!Software Version V200R019C10SPC500
+#
+sysname SW2
+#
+interface GigabitEthernet0/0/1
+ port link-type access
+ port default vlan 10
+#
+interface LoopBack0
+ ip address 10.255.0.2 255.255.255.255
+#
+return`
+
+const ciscoConfig = `!
+hostname SW3
+!
+interface GigabitEthernet1/0/1
+ description uplink
+ switchport mode trunk
+!
+router bgp 65003
+ neighbor 10.0.0.1 remote-as 65001
+ !
+ address-family ipv4
+  neighbor 10.0.0.1 activate
+ exit-address-family
+!
+end`
+
+const nexusConfig = `!Command: show running-config
+version 9.3(8) Bios:version 05.44
+hostname SW4
+
+interface Ethernet1/1
+  description uplink
+  no shutdown
+
+router bgp 65004
+  neighbor 10.0.0.1
+    remote-as 65001
+    address-family ipv4 unicast`
+
+func TestParseConfig(t *testing.T) {
+	cases := []struct {
+		name, config string
+		want         []string
+	}{
+		{
+			name:   "h3c",
+			config: h3cConfig,
+			want:   []string{"version 7.1.070, Release 6616", "sysname SW1", "interface Vlan-interface10", "interface Ten-GigabitEthernet1/0/1", "bgp 65001", "interfaces-profile A"},
+		},
+		{
+			name:   "huawei",
+			config: huaweiConfig,
+			want:   []string{"sysname SW2", "interface GigabitEthernet0/0/1", "interface LoopBack0"},
+		},
+		{
+			name:   "cisco",
+			config: strings.ReplaceAll(ciscoConfig, "\n", "\r\n"),
+			want:   []string{"hostname SW3", "interface GigabitEthernet1/0/1", "router bgp 65003"},
+		},
+		{
+			name:   "nexus",
+			config: nexusConfig,
+			want:   []string{"version 9.3(8) Bios:version 05.44", "hostname SW4", "interface Ethernet1/1", "router bgp 65004"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			for _, s := range ParseConfig(c.config).Children {
+				got = append(got, s.Line)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q\nwant %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSections(t *testing.T) {
+	cases := []struct {
+		name, config, prefix string
+		want                 []string
+	}{
+		{"h3c interfaces", h3cConfig, "interface", []string{"interface Vlan-interface10", "interface Ten-GigabitEthernet1/0/1"}},
+		{"huawei interfaces", huaweiConfig, "interface", []string{"interface GigabitEthernet0/0/1", "interface LoopBack0"}},
+		{"cisco router bgp", ciscoConfig, "router bgp", []string{"router bgp 65003"}},
+		{"nexus interfaces", nexusConfig, "interface", []string{"interface Ethernet1/1"}},
+		{"not found", ciscoConfig, "vlan", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			for _, s := range ParseConfig(c.config).Sections(c.prefix) {
+				got = append(got, s.Line)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q\nwant %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	cases := []struct {
+		name, config string
+		path         []string
+		want         []string
+	}{
+		{
+			name:   "h3c address family",
+			config: h3cConfig,
+			path:   []string{"bgp", "address-family ipv4"},
+			want:   []string{" address-family ipv4 unicast", "  peer 10.0.0.2 enable"},
+		},
+		{
+			name:   "huawei interface",
+			config: huaweiConfig,
+			path:   []string{"interface LoopBack0"},
+			want:   []string{"interface LoopBack0", " ip address 10.255.0.2 255.255.255.255"},
+		},
+		{
+			name:   "cisco address family",
+			config: ciscoConfig,
+			path:   []string{"router bgp 65003", "address-family ipv4"},
+			// 'exit-address-family' is indented as a sibling by Cisco.
+			want: []string{" address-family ipv4", "  neighbor 10.0.0.1 activate"},
+		},
+		{
+			name:   "nexus neighbor",
+			config: nexusConfig,
+			path:   []string{"router bgp", "neighbor 10.0.0.1", "remote-as"},
+			want:   []string{"    remote-as 65001"},
+		},
+		{
+			name:   "not found",
+			config: nexusConfig,
+			path:   []string{"router bgp", "neighbor 10.0.0.9"},
+		},
+		{
+			name:   "partial word",
+			config: h3cConfig,
+			path:   []string{"interfaces"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := ParseConfig(c.config).Lookup(c.path...)
+			var got []string
+			if s != nil {
+				got = s.Lines()
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q\nwant %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	s := ParseConfig(ciscoConfig).Lookup("router bgp", "address-family ipv4", "neighbor")
+	want := []string{"router bgp 65003", "address-family ipv4", "neighbor 10.0.0.1 activate"}
+	if s == nil || !reflect.DeepEqual(s.Path(), want) {
+		t.Errorf("got %v, want %q", s, want)
+	}
+}
+
+func TestGetInterfaceSection(t *testing.T) {
+	cases := []struct {
+		name, config string
+		want         []string
+	}{
+		{
+			name:   "h3c",
+			config: h3cConfig,
+			want: []string{
+				"interface Vlan-interface10",
+				" ip address 10.0.10.1 255.255.255.0",
+				"interface Ten-GigabitEthernet1/0/1",
+				" port link-type trunk",
+				" port trunk permit vlan 10 20",
+			},
+		},
+		{
+			name:   "nexus",
+			config: nexusConfig,
+			want:   []string{"interface Ethernet1/1", "  description uplink", "  no shutdown"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := GetInterfaceSection(strings.Split(c.config, "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q\nwant %q", got, c.want)
+			}
+		})
+	}
+	if _, err := GetInterfaceSection([]string{"#", " sysname SW1", "#"}); err == nil {
+		t.Error("error = nil, want no interface section")
+	}
+}
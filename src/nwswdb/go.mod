@@ -0,0 +1,21 @@
+module nwswdb
+
+go 1.19
+
+require (
+	go.etcd.io/bbolt v1.3.7
+	nwnet v0.0.0
+	nwparse v0.0.0
+	nwssh v0.0.0
+)
+
+require (
+	golang.org/x/crypto v0.5.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
+
+replace nwssh v0.0.0 => ../nwssh
+
+replace nwnet v0.0.0 => ../nwnet
+
+replace nwparse v0.0.0 => ../nwparse
@@ -1,22 +1,18 @@
 package nwswdb
 
-import "strings"
+import (
+	"errors"
+	"strings"
+)
 
+// GetInterfaceSection returns the lines of the interface sections in config.
 func GetInterfaceSection(config []string) ([]string, error) {
-	var start, end int = 0, 0
-	matched := false
-	configlen := len(config)
-	for i, line := range config {
-		if strings.HasPrefix(line, "interface") {
-			start = i
-			matched = true
-			continue
-		}
-
-		if strings.HasPrefix(line, "#") && matched {
-			if i >= configlen {
-				return config[start:end]
-			}
-		}
+	var lines []string
+	for _, s := range ParseConfig(strings.Join(config, "\n")).Sections("interface") {
+		lines = append(lines, s.Lines()...)
+	}
+	if lines == nil {
+		return nil, errors.New("No interface section in the configuration.")
 	}
+	return lines, nil
 }
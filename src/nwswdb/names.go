@@ -0,0 +1,154 @@
+package nwswdb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	kindPhysical  = "physical"
+	kindAggregate = "aggregate"
+	kindVlan      = "vlan"
+	kindOther     = "other"
+)
+
+// ifType is a type of interfaces, the devices print either the short or the
+// full name of it.
+type ifType struct {
+	short string
+	full  string
+	kind  string
+}
+
+var ifTypes = map[string][]ifType{
+	"H3C": {
+		{"GE", "GigabitEthernet", kindPhysical},
+		{"XGE", "Ten-GigabitEthernet", kindPhysical},
+		{"WGE", "TwentyFiveGigE", kindPhysical},
+		{"FGE", "FortyGigE", kindPhysical},
+		{"HGE", "HundredGigE", kindPhysical},
+		{"BAGG", "Bridge-Aggregation", kindAggregate},
+		{"RAGG", "Route-Aggregation", kindAggregate},
+		{"Vlan", "Vlan-interface", kindVlan},
+		{"M-GE", "M-GigabitEthernet", kindOther},
+		{"Loop", "LoopBack", kindOther},
+		{"InLoop", "InLoopBack", kindOther},
+	},
+	"HUAWEI": {
+		{"GE", "GigabitEthernet", kindPhysical},
+		{"XGE", "XGigabitEthernet", kindPhysical},
+		{"10GE", "10GE", kindPhysical},
+		{"25GE", "25GE", kindPhysical},
+		{"40GE", "40GE", kindPhysical},
+		{"100GE", "100GE", kindPhysical},
+		{"Eth-Trunk", "Eth-Trunk", kindAggregate},
+		{"Vlanif", "Vlanif", kindVlan},
+		{"MEth", "MEth", kindOther},
+		{"Loop", "LoopBack", kindOther},
+	},
+	"CISCO": {
+		{"Fa", "FastEthernet", kindPhysical},
+		{"Gi", "GigabitEthernet", kindPhysical},
+		{"Te", "TenGigabitEthernet", kindPhysical},
+		{"Twe", "TwentyFiveGigE", kindPhysical},
+		{"Fo", "FortyGigabitEthernet", kindPhysical},
+		{"Hu", "HundredGigE", kindPhysical},
+		{"Po", "Port-channel", kindAggregate},
+		{"Vl", "Vlan", kindVlan},
+		{"Lo", "Loopback", kindOther},
+	},
+	"NEXUS": {
+		{"Eth", "Ethernet", kindPhysical},
+		{"Po", "port-channel", kindAggregate},
+		{"Vlan", "Vlan", kindVlan},
+		{"mgmt", "mgmt", kindOther},
+		{"Lo", "loopback", kindOther},
+	},
+	"RUIJIE": {
+		{"Gi", "GigabitEthernet", kindPhysical},
+		{"Te", "TenGigabitEthernet", kindPhysical},
+		{"TF", "TFGigabitEthernet", kindPhysical},
+		{"Fo", "FortyGigabitEthernet", kindPhysical},
+		{"Hu", "HundredGigabitEthernet", kindPhysical},
+		{"Ag", "AggregatePort", kindAggregate},
+		{"VLAN", "VLAN", kindVlan},
+		{"Mgmt", "Mgmt", kindOther},
+		{"Lo", "Loopback", kindOther},
+	},
+}
+
+// fullNameSeparators separate the type and the number in the full names,
+// such as 'TFGigabitEthernet 0/1' of Ruijie.
+var fullNameSeparators = map[string]string{
+	"RUIJIE": " ",
+}
+
+var ifNameParts = regexp.MustCompile(`^(\d*[A-Za-z][A-Za-z-]*)\s*(\d.*)$`)
+
+// ifName returns the full and the short name and the kind of the interface
+// name printed by the device of vendor. The unknown names are returned as
+// they are.
+func ifName(vendor, name string) (full, short, kind string) {
+	m := ifNameParts.FindStringSubmatch(strings.TrimSpace(name))
+	if m == nil {
+		return name, name, kindOther
+	}
+	for _, t := range ifTypes[vendor] {
+		if strings.EqualFold(m[1], t.short) || strings.EqualFold(m[1], t.full) {
+			return t.full + fullNameSeparators[vendor] + m[2], t.short + m[2], t.kind
+		}
+	}
+	return name, name, kindOther
+}
+
+// ifNumbers returns the numbers of the interface name like 'GE1/0/1', the
+// subinterface and the breakout number after '.' or ':' are ignored.
+func ifNumbers(name string) []int {
+	m := ifNameParts.FindStringSubmatch(strings.TrimSpace(name))
+	if m == nil {
+		return nil
+	}
+	var numbers []int
+	for _, s := range strings.Split(strings.FieldsFunc(m[2], func(r rune) bool { return r == '.' || r == ':' })[0], "/") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// portLocation is where a physical port is, member 0 means the first member
+// device.
+type portLocation struct {
+	member  int
+	slot    int
+	subslot int
+	port    int
+}
+
+// boxLocation locates the ports of the box devices and stacks, the first
+// number of 'GE1/0/1' is the member and its slot. 'GE1/3/0/1' of a chassis
+// has the member, the slot, the subslot and the port.
+func boxLocation(numbers []int) (portLocation, bool) {
+	switch len(numbers) {
+	case 2:
+		return portLocation{0, numbers[0], 0, numbers[1]}, true
+	case 3:
+		return portLocation{numbers[0], numbers[0], numbers[1], numbers[2]}, true
+	case 4:
+		return portLocation{numbers[0], numbers[1], numbers[2], numbers[3]}, true
+	}
+	return portLocation{}, false
+}
+
+// chassisLocation locates the ports of Nexus, 'Eth1/1' is the port 1 of the
+// line card in slot 1, 'Eth1/1/1' is a breakout of it.
+func chassisLocation(numbers []int) (portLocation, bool) {
+	if len(numbers) < 2 {
+		return portLocation{}, false
+	}
+	return portLocation{0, numbers[0], 0, numbers[1]}, true
+}
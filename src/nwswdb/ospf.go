@@ -0,0 +1,426 @@
+package nwswdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	nwnet "nwnet"
+	"nwparse"
+	"nwssh"
+	"strconv"
+	"strings"
+)
+
+/*
+CollectOSPF returns an OSPF per area of each OSPF process, the router ID, the
+imported routes and the ASBR summaries of the process are in all its areas.
+The areas are read from the running configuration:
+
+	H3C     ospf <process> router-id <id>
+	HUAWEI   import-route|asbr-summary
+	         area <area>
+	          network|nssa|abr-summary
+	        interface <name>
+	         ospf [enable] <process> area <area>
+	CISCO   router ospf <process>
+	RUIJIE   router-id|network .. area|area .. nssa|area .. range|
+	NEXUS    summary-address|redistribute
+	        interface <name>
+	         ip [router] ospf <process> area <area>
+
+The neighbors are read from 'display ospf peer' (H3C), 'display ospf peer
+brief' (HUAWEI), 'show ip ospf neighbors' (NEXUS) or 'show ip ospf neighbor'.
+The neighbors of Cisco and Ruijie are put in the area of their interfaces, or
+the area of the network containing their addresses.
+
+AggrNetwork are the ABR and the ASBR summaries together, the specific routes
+are always suppressed by them. The processes of the VPN instances (VRF) of
+Nexus are not read. It's run by the transaction 'ospf', which prints the
+OSPFs as JSON.
+*/
+
+var ospfNeighborCommands = map[string]string{
+	"H3C":    "display ospf peer",
+	"HUAWEI": "display ospf peer brief",
+	"CISCO":  "show ip ospf neighbor",
+	"NEXUS":  "show ip ospf neighbors",
+	"RUIJIE": "show ip ospf neighbor",
+}
+
+var routeTypeNames = []string{"", "ext-type1", "ext-type2", "ext-type12", "internal", "isis-level1", "isis-level2",
+	"nssa-ext-type1", "nssa-ext-type2", "nssa-ext-type12"}
+
+func (t ROUTETYPE) String() string {
+	if int(t) < len(routeTypeNames) {
+		return routeTypeNames[t]
+	}
+	return strconv.Itoa(int(t))
+}
+
+func (t ROUTETYPE) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *ROUTETYPE) UnmarshalText(text []byte) error {
+	for i, name := range routeTypeNames {
+		if name == string(text) {
+			*t = ROUTETYPE(i)
+			return nil
+		}
+	}
+	n, err := strconv.Atoi(string(text))
+	if err != nil {
+		return fmt.Errorf("Invalid route type '%s'.", text)
+	}
+	*t = ROUTETYPE(n)
+	return nil
+}
+
+func init() {
+	nwssh.Transactions["ospf"] = ospfTransaction
+}
+
+// ospfTransaction is the transaction 'ospf'.
+func ospfTransaction(dev nwssh.SSHBASE, vendor string) (string, error) {
+	ospf, err := CollectOSPF(dev, vendor, nil)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(ospf, "", "  ")
+	return string(data), err
+}
+
+// CollectOSPF returns the OSPF areas of dev, the session should be prepared
+// by SessionPreparation. The templates of index are used to parse the
+// neighbors, the builtin templates of nwparse are used if it's nil.
+func CollectOSPF(dev nwssh.SSHBASE, vendor string, index *nwparse.Index) ([]*OSPF, error) {
+	cmd, ok := ospfNeighborCommands[vendor]
+	if !ok {
+		return nil, fmt.Errorf("No OSPF collector for the vendor '%s'.", vendor)
+	}
+	var err error
+	if index == nil {
+		if index, err = nwparse.Builtin(); err != nil {
+			return nil, err
+		}
+	}
+	records, err := collectRecords(dev, index, vendor, cmd)
+	if err != nil {
+		return nil, err
+	}
+	config, err := dev.RunningConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the OSPF configuration. %v", err)
+	}
+	c := parseOSPFConfig(vendor, ParseConfig(config))
+	c.addNeighbors(records)
+	return c.result(), nil
+}
+
+// ospfProcess is the settings of a process shared by its areas.
+type ospfProcess struct {
+	id       string
+	routerID string
+	imports  []*RouteRedistribution
+	asbr     []*AsbrSummaryNetwork
+}
+
+type ospfConfig struct {
+	vendor     string
+	processes  []*ospfProcess
+	areas      []*OSPF
+	interfaces map[string]*OSPF // The areas by the full names of interfaces.
+}
+
+// areaID returns the area ID in the dotted format, '1' is '0.0.0.1'.
+func areaID(s string) string {
+	if strings.Contains(s, ".") {
+		return s
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return s
+	}
+	return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).String()
+}
+
+// v4Prefix returns the prefix of the address and the mask like '255.0.0.0'
+// or the wildcard like '0.255.255.255', the address may be like '10.0.0.0/8'
+// without mask. It's nil if they are invalid.
+func v4Prefix(address, mask string, wildcard bool) *nwnet.V4Prefix {
+	if strings.Contains(address, "/") {
+		p, err := nwnet.IPv4(address)
+		if err != nil {
+			return nil
+		}
+		return p
+	}
+	m := net.ParseIP(mask).To4()
+	if m == nil {
+		return nil
+	}
+	if wildcard {
+		m = net.IP{^m[0], ^m[1], ^m[2], ^m[3]}
+	}
+	ones, bits := net.IPMask(m).Size()
+	if bits == 0 {
+		return nil
+	}
+	p, err := nwnet.IPv4(address + "/" + strconv.Itoa(ones))
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+func (c *ospfConfig) process(id string) *ospfProcess {
+	for _, p := range c.processes {
+		if p.id == id {
+			return p
+		}
+	}
+	p := &ospfProcess{id: id}
+	c.processes = append(c.processes, p)
+	return p
+}
+
+// area returns the area of process, it's added if not found.
+func (c *ospfConfig) area(process, id string) *OSPF {
+	c.process(process)
+	for _, a := range c.areas {
+		if a.ProcessID == process && a.AreaID == id {
+			return a
+		}
+	}
+	a := &OSPF{ProcessID: process, AreaID: id}
+	c.areas = append(c.areas, a)
+	return a
+}
+
+// parseOSPFConfig reads the sections 'ospf <process>', 'router ospf
+// <process>' and the OSPF lines of the interfaces.
+func parseOSPFConfig(vendor string, root *Section) *ospfConfig {
+	c := &ospfConfig{vendor: vendor, interfaces: map[string]*OSPF{}}
+	for _, s := range root.Sections("ospf") {
+		words := strings.Fields(s.Line)
+		if len(words) < 2 {
+			continue
+		}
+		p := c.process(words[1])
+		for i := 2; i+1 < len(words); i++ {
+			if words[i] == "router-id" {
+				p.routerID = words[i+1]
+			}
+		}
+		c.readProcess(p, s)
+	}
+	for _, s := range root.Sections("router ospf") {
+		if words := strings.Fields(s.Line); len(words) > 2 {
+			c.readProcess(c.process(words[2]), s)
+		}
+	}
+	for _, s := range root.Sections("interface") {
+		words := strings.Fields(s.Line)
+		if len(words) < 2 {
+			continue
+		}
+		name, _, _ := ifName(vendor, strings.Join(words[1:], " "))
+		for _, child := range s.Children {
+			if area := c.interfaceArea(child.Line); area != nil {
+				area.ActiveInterface = append(area.ActiveInterface, name)
+				c.interfaces[name] = area
+			}
+		}
+	}
+	return c
+}
+
+// interfaceArea returns the area of a line like 'ospf 1 area 0.0.0.0', 'ospf
+// enable 1 area 0', 'ip ospf 1 area 0' or 'ip router ospf 1 area 0.0.0.0'.
+func (c *ospfConfig) interfaceArea(line string) *OSPF {
+	words := strings.Fields(line)
+	ospf := false
+	for i, w := range words {
+		if w == "ospf" {
+			ospf = true
+		}
+		if ospf && w == "area" && i >= 2 && i+1 < len(words) && words[i-1] != "ospf" {
+			return c.area(words[i-1], areaID(words[i+1]))
+		}
+	}
+	return nil
+}
+
+func (c *ospfConfig) readProcess(p *ospfProcess, s *Section) {
+	for _, child := range s.Children {
+		words := strings.Fields(child.Line)
+		switch {
+		case words[0] == "router-id" && len(words) > 1:
+			p.routerID = words[1]
+		case (words[0] == "import-route" || words[0] == "redistribute") && len(words) > 1:
+			p.imports = append(p.imports, redistribution(words[1:]))
+		case (words[0] == "asbr-summary" || words[0] == "summary-address") && len(words) > 1:
+			if summary := asbrSummary(words[1:]); summary != nil {
+				p.asbr = append(p.asbr, summary)
+			}
+		case words[0] == "area" && len(words) == 2:
+			a := c.area(p.id, areaID(words[1]))
+			for _, line := range child.Children {
+				c.readArea(a, strings.Fields(line.Line))
+			}
+		case words[0] == "area" && len(words) > 2:
+			c.readArea(c.area(p.id, areaID(words[1])), words[2:])
+		case words[0] == "network" && len(words) > 4 && words[3] == "area":
+			c.readArea(c.area(p.id, areaID(words[4])), words[:3])
+		}
+	}
+}
+
+// readArea reads a line of the area like 'network 10.0.0.0 0.0.0.255', 'nssa'
+// or 'abr-summary 10.0.0.0 255.0.0.0' ('range' of Cisco).
+func (c *ospfConfig) readArea(a *OSPF, words []string) {
+	switch {
+	case words[0] == "nssa":
+		a.Nssa = true
+	case words[0] == "network" && len(words) > 2:
+		if p := v4Prefix(words[1], words[2], true); p != nil {
+			a.ActiveNetwork = append(a.ActiveNetwork, p)
+		}
+	case (words[0] == "abr-summary" || words[0] == "range") && len(words) > 1:
+		mask := ""
+		if len(words) > 2 {
+			mask = words[2]
+		}
+		if p := v4Prefix(words[1], mask, false); p != nil {
+			s := &AbrSummaryNetwork{Network: p, Advertised: true}
+			s.Advertised, s.Cost = summaryOptions(words[2:])
+			a.AbrSummary = append(a.AbrSummary, s)
+		}
+	}
+}
+
+// summaryOptions returns the options of a summary like 'not-advertise' and
+// 'cost 10'.
+func summaryOptions(words []string) (advertised bool, cost int) {
+	advertised = true
+	for i, w := range words {
+		switch {
+		case w == "not-advertise":
+			advertised = false
+		case w == "cost" && i+1 < len(words):
+			cost = atoi(words[i+1])
+		}
+	}
+	return advertised, cost
+}
+
+func asbrSummary(words []string) *AsbrSummaryNetwork {
+	mask := ""
+	if len(words) > 1 {
+		mask = words[1]
+	}
+	p := v4Prefix(words[0], mask, false)
+	if p == nil {
+		return nil
+	}
+	s := &AsbrSummaryNetwork{Network: p}
+	s.Advertised, s.Cost = summaryOptions(words[1:])
+	return s
+}
+
+// redistribution reads the words after 'import-route' or 'redistribute',
+// such as 'bgp 65001 cost 10 type 1 route-policy P' or 'connected subnets
+// metric 10 metric-type 1 route-map P'. The routes are type 2 by default.
+func redistribution(words []string) *RouteRedistribution {
+	r := &RouteRedistribution{RouteProtocol: words[0], RouteType: EXT_TYPE2}
+	for i := 1; i < len(words); i++ {
+		if i+1 >= len(words) {
+			break
+		}
+		switch words[i] {
+		case "cost", "metric":
+			r.RouteCost = atoi(words[i+1])
+		case "type", "metric-type":
+			if words[i+1] == "1" {
+				r.RouteType = EXT_TYPE1
+			}
+		case "route-policy", "route-map":
+			r.RoutePolicy = &RoutePolicy{Name: words[i+1]}
+		default:
+			continue
+		}
+		i++
+	}
+	if len(words) > 1 {
+		if n, err := strconv.Atoi(words[1]); err == nil {
+			r.RouteProcess = n
+		}
+	}
+	return r
+}
+
+// addNeighbors puts the neighbors into their areas.
+func (c *ospfConfig) addNeighbors(records []record) {
+	for _, r := range records {
+		name, _, _ := ifName(c.vendor, str(r, "INTERFACE"))
+		n := &OSPFNeighbor{RouterID: str(r, "NEIGHBOR_ID"), Address: str(r, "ADDRESS"), Interface: name, State: str(r, "STATE")}
+		a := c.neighborArea(r, n)
+		a.Neighbors = append(a.Neighbors, n)
+	}
+}
+
+// neighborArea returns the area of the neighbor by the area in the record,
+// the area of the interface or the area of the network containing the
+// address, in order.
+func (c *ospfConfig) neighborArea(r record, n *OSPFNeighbor) *OSPF {
+	process := str(r, "PROCESS")
+	if area := str(r, "AREA"); area != "" {
+		return c.area(process, areaID(area))
+	}
+	if a, ok := c.interfaces[n.Interface]; ok && (process == "" || a.ProcessID == process) {
+		return a
+	}
+	if ip := net.ParseIP(n.Address); ip != nil {
+		for _, a := range c.areas {
+			for _, p := range a.ActiveNetwork {
+				ipnet := net.IPNet(*p)
+				if (process == "" || a.ProcessID == process) && ipnet.Contains(ip) {
+					return a
+				}
+			}
+		}
+	}
+	if process == "" && len(c.processes) == 1 {
+		process = c.processes[0].id
+	}
+	return c.area(process, "")
+}
+
+// result returns the areas with the settings of their processes, a process
+// without area has an area with empty ID.
+func (c *ospfConfig) result() []*OSPF {
+	for _, p := range c.processes {
+		found := false
+		for _, a := range c.areas {
+			found = found || a.ProcessID == p.id
+		}
+		if !found {
+			c.area(p.id, "")
+		}
+	}
+	for _, p := range c.processes {
+		for _, a := range c.areas {
+			if a.ProcessID != p.id {
+				continue
+			}
+			a.RouterID, a.ImportPolicy, a.AsbrSummary = p.routerID, p.imports, p.asbr
+			for _, s := range a.AbrSummary {
+				a.AggrNetwork = append(a.AggrNetwork, &AggregatedNetwork{Network: s.Network, DetailSuppressed: true})
+			}
+			for _, s := range p.asbr {
+				a.AggrNetwork = append(a.AggrNetwork, &AggregatedNetwork{Network: s.Network, DetailSuppressed: true})
+			}
+		}
+	}
+	return c.areas
+}
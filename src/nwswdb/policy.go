@@ -0,0 +1,462 @@
+package nwswdb
+
+import (
+	"fmt"
+	"net"
+	nwnet "nwnet"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ParsePolicies reads the routing policies in a configuration parsed by
+ParseConfig, and Evaluate tells whether a route is permitted by one of them,
+so the import and the export policies of BGP can be checked offline:
+
+	H3C     ip prefix-list <name> index <index> permit|deny <address> <length>
+	         [greater-equal <min>] [less-equal <max>]
+	        ip as-path <name> permit|deny <regexp>
+	        ip community-list <number>|basic <name>|advanced <name>
+	         permit|deny <communities>|<regexp>
+	        route-policy <name> permit|deny node <index>
+	         if-match ip address prefix-list|as-path|community <names>
+	         apply ..
+	HUAWEI  ip ip-prefix, ip as-path-filter and ip community-filter
+	         if-match ip-prefix|as-path-filter|community-filter <names>
+	CISCO   ip prefix-list <name> [seq <index>] permit|deny <prefix> [ge <min>]
+	RUIJIE   [le <max>]
+	NEXUS   ip as-path access-list <name> [seq <index>] permit|deny <regexp>
+	        ip community-list <number>|standard <name>|expanded <name>
+	         [seq <index>] permit|deny <communities>|<regexp>
+	        route-map <name> [permit|deny] [<index>]
+	         match ip address prefix-list|as-path|community <names>
+	         set ..
+
+The nodes of a policy are tried by their indexes, the first node whose
+conditions all match permits or denies the route. A condition of several
+filters matches if one of them permits the route, and a filter permits or
+denies by its first matching node too. The route is denied if no node matches.
+The communities of the standard filters all have to be in the route, the
+regular expressions of the expanded ones match the communities of the route
+separated by spaces, '_' matches a space, a comma, a brace, a parenthesis or
+either end.
+
+The other conditions (like 'if-match acl' or 'match community .. exact-match')
+are not supported, they are kept in Unsupported and Evaluate fails if it meets
+them. The actions are kept but not applied.
+*/
+
+// The default index steps of the lines without index.
+const (
+	prefixIndexStep = 5
+	filterIndexStep = 10
+)
+
+var wellKnownCommunities = map[string]string{
+	"no-export":           "65535:65281",
+	"no-advertise":        "65535:65282",
+	"no-export-subconfed": "65535:65283",
+	"local-as":            "65535:65283",
+}
+
+// Policies are the routing policies and the filters used by them, they are
+// indexed by their names.
+type Policies struct {
+	IPPrefixes    map[string]*IPPrefix
+	ASPaths       map[string]*ASPath
+	Communities   map[string]*Community
+	RoutePolicies map[string]*RoutePolicy
+}
+
+// Route is the route to evaluate. ASPath is like '65001 65002', Communities
+// are like '65001:100', 'no-export' or '4259905636'.
+type Route struct {
+	Prefix      nwnet.V4Prefix
+	ASPath      string
+	Communities []string
+}
+
+// ParsePolicies returns the routing policies in the configuration.
+func ParsePolicies(root *Section) (*Policies, error) {
+	p := &Policies{
+		IPPrefixes:    map[string]*IPPrefix{},
+		ASPaths:       map[string]*ASPath{},
+		Communities:   map[string]*Community{},
+		RoutePolicies: map[string]*RoutePolicy{},
+	}
+	for _, s := range root.Children {
+		words := strings.Fields(s.Line)
+		var err error
+		switch {
+		case hasWords(s.Line, "ip prefix-list") || hasWords(s.Line, "ip ip-prefix"):
+			err = p.addPrefix(words[2:])
+		case hasWords(s.Line, "ip as-path access-list"):
+			p.addASPath(words[3:])
+		case hasWords(s.Line, "ip as-path") || hasWords(s.Line, "ip as-path-filter"):
+			p.addASPath(words[2:])
+		case hasWords(s.Line, "ip community-list") || hasWords(s.Line, "ip community-filter"):
+			p.addCommunity(words[2:])
+		case words[0] == "route-policy" || words[0] == "route-map":
+			p.addRoutePolicy(s, words[1:])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid line '%s'. %v", s.Line, err)
+		}
+	}
+	for _, f := range p.IPPrefixes {
+		sort.SliceStable(f.Nodes, func(i, j int) bool { return f.Nodes[i].Index < f.Nodes[j].Index })
+	}
+	for _, f := range p.ASPaths {
+		sort.SliceStable(f.Nodes, func(i, j int) bool { return f.Nodes[i].Index < f.Nodes[j].Index })
+	}
+	for _, f := range p.Communities {
+		sort.SliceStable(f.Nodes, func(i, j int) bool { return f.Nodes[i].Index < f.Nodes[j].Index })
+	}
+	for _, r := range p.RoutePolicies {
+		sort.SliceStable(r.Nodes, func(i, j int) bool { return r.Nodes[i].Index < r.Nodes[j].Index })
+	}
+	return p, nil
+}
+
+// filterEntry splits the words after the name of a filter, like 'index 10
+// permit ..' or 'permit ..', into the index and the words after the action.
+// ok is false if it's not an entry, such as a description.
+func filterEntry(words []string, last, step int) (index int, permitted bool, rest []string, ok bool) {
+	index = last + step
+	if len(words) > 1 && (words[0] == "index" || words[0] == "seq") {
+		index, words = atoi(words[1]), words[2:]
+	}
+	if len(words) == 0 || (words[0] != "permit" && words[0] != "deny") {
+		return 0, false, nil, false
+	}
+	return index, words[0] == "permit", words[1:], true
+}
+
+// addPrefix adds the line of a prefix list, words start with the name.
+func (p *Policies) addPrefix(words []string) error {
+	if len(words) < 2 {
+		return nil
+	}
+	list, ok := p.IPPrefixes[words[0]]
+	if !ok {
+		list = &IPPrefix{Name: words[0]}
+		p.IPPrefixes[words[0]] = list
+	}
+	last := 0
+	if len(list.Nodes) > 0 {
+		last = list.Nodes[len(list.Nodes)-1].Index
+	}
+	index, permitted, rest, ok := filterEntry(words[1:], last, prefixIndexStep)
+	if !ok || len(rest) == 0 {
+		return nil
+	}
+	prefix := rest[0]
+	if !strings.Contains(prefix, "/") && len(rest) > 1 {
+		prefix, rest = prefix+"/"+rest[1], rest[1:]
+	}
+	address, err := nwnet.IPv4(prefix)
+	if err != nil {
+		return err
+	}
+	address.IP = address.NetAddress()
+	length, _ := address.Mask.Size()
+	node := &IPPrefixNode{Index: index, Permitted: permitted, Address: *address, MinMaskLen: uint8(length), MaxMaskLen: uint8(length)}
+	ranged := false
+	for i := 1; i+1 < len(rest); i++ {
+		switch rest[i] {
+		case "greater-equal", "ge":
+			node.MinMaskLen = uint8(atoi(rest[i+1]))
+			if !ranged {
+				node.MaxMaskLen = 32
+			}
+		case "less-equal", "le":
+			node.MaxMaskLen, ranged = uint8(atoi(rest[i+1])), true
+		}
+	}
+	list.Nodes = append(list.Nodes, node)
+	return nil
+}
+
+// addASPath adds the line of an AS path filter, words start with the name.
+func (p *Policies) addASPath(words []string) {
+	if len(words) < 2 {
+		return
+	}
+	list, ok := p.ASPaths[words[0]]
+	if !ok {
+		list = &ASPath{Name: words[0]}
+		p.ASPaths[words[0]] = list
+	}
+	last := 0
+	if len(list.Nodes) > 0 {
+		last = list.Nodes[len(list.Nodes)-1].Index
+	}
+	index, permitted, rest, ok := filterEntry(words[1:], last, filterIndexStep)
+	if !ok {
+		return
+	}
+	expr := strings.Trim(strings.Join(rest, " "), `"`)
+	list.Nodes = append(list.Nodes, &ASPathNode{Index: index, Permitted: permitted, ASNExpr: expr})
+}
+
+// addCommunity adds the line of a community filter, words start with the
+// name or the type and the name. The filters numbered from 100 are expanded.
+func (p *Policies) addCommunity(words []string) {
+	if len(words) < 2 {
+		return
+	}
+	expanded := false
+	switch words[0] {
+	case "basic", "standard":
+		words = words[1:]
+	case "advanced", "expanded":
+		expanded, words = true, words[1:]
+	default:
+		expanded = atoi(words[0]) >= 100
+	}
+	list, ok := p.Communities[words[0]]
+	if !ok {
+		list = &Community{Name: words[0]}
+		p.Communities[words[0]] = list
+	}
+	last := 0
+	if len(list.Nodes) > 0 {
+		last = list.Nodes[len(list.Nodes)-1].Index
+	}
+	index, permitted, rest, ok := filterEntry(words[1:], last, filterIndexStep)
+	if !ok {
+		return
+	}
+	node := &CommunityNode{Index: index, Permitted: permitted, Expanded: expanded}
+	if expanded {
+		node.Community = strings.Trim(strings.Join(rest, " "), `"`)
+	} else {
+		var values []string
+		for _, c := range rest {
+			values = append(values, communityValue(c))
+		}
+		node.Community = strings.Join(values, " ")
+	}
+	list.Nodes = append(list.Nodes, node)
+}
+
+// addRoutePolicy adds the node of a route policy, words are after
+// 'route-policy' or 'route-map'. The node of a route map is 'permit 10' by
+// default.
+func (p *Policies) addRoutePolicy(s *Section, words []string) {
+	if len(words) == 0 {
+		return
+	}
+	policy, ok := p.RoutePolicies[words[0]]
+	if !ok {
+		policy = &RoutePolicy{Name: words[0]}
+		p.RoutePolicies[words[0]] = policy
+	}
+	node := &RoutePolicyNode{Index: 10, Permitted: true}
+	for _, w := range words[1:] {
+		if n, err := strconv.Atoi(w); err == nil {
+			node.Index = n
+		} else if w == "deny" {
+			node.Permitted = false
+		}
+	}
+	for _, child := range s.Children {
+		fields := strings.Fields(child.Line)
+		switch fields[0] {
+		case "if-match", "match":
+			addCondition(node, child.Line, fields[1:])
+		case "apply", "set":
+			node.Actions = append(node.Actions, child.Line)
+		}
+	}
+	policy.Nodes = append(policy.Nodes, node)
+}
+
+// addCondition adds the condition after 'if-match' or 'match' to node.
+func addCondition(node *RoutePolicyNode, line string, words []string) {
+	var names []string
+	switch {
+	case hasWords(strings.Join(words, " "), "ip address prefix-list") && len(words) > 3:
+		names = words[3:]
+	case (words[0] == "ip-prefix" || words[0] == "as-path" || words[0] == "as-path-filter" ||
+		words[0] == "community" || words[0] == "community-filter") && len(words) > 1:
+		names = words[1:]
+	}
+	var filters []string
+	exact := false
+	for _, name := range names {
+		switch name {
+		case "name":
+		case "exact-match", "whole-match":
+			exact = true
+		default:
+			filters = append(filters, name)
+		}
+	}
+	switch {
+	case filters == nil || exact:
+		node.Unsupported = append(node.Unsupported, line)
+	case words[0] == "ip" || words[0] == "ip-prefix":
+		node.IPPrefix = append(node.IPPrefix, filters...)
+	case words[0] == "as-path" || words[0] == "as-path-filter":
+		node.ASPath = append(node.ASPath, filters...)
+	default:
+		node.Community = append(node.Community, filters...)
+	}
+}
+
+// communityValue returns the community like '65001:100', the numbers and
+// the well known names are converted to this format except 'internet'.
+func communityValue(c string) string {
+	c = strings.ToLower(c)
+	if v, ok := wellKnownCommunities[c]; ok {
+		return v
+	}
+	if n, err := strconv.ParseUint(c, 10, 32); err == nil {
+		return fmt.Sprintf("%d:%d", n>>16, n&0xffff)
+	}
+	return c
+}
+
+// asRegexp compiles the regular expression of Cisco, '_' is a delimiter.
+func asRegexp(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(strings.ReplaceAll(expr, "_", `(?:^|$|[ ,{}()])`))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regular expression '%s'. %v", expr, err)
+	}
+	return re, nil
+}
+
+// Permits reports whether the prefix is permitted by the list.
+func (list *IPPrefix) Permits(prefix nwnet.V4Prefix) bool {
+	length, _ := prefix.Mask.Size()
+	for _, n := range list.Nodes {
+		ones, _ := n.Address.Mask.Size()
+		network := net.IPNet(n.Address)
+		if length >= ones && length >= int(n.MinMaskLen) && length <= int(n.MaxMaskLen) && network.Contains(prefix.IP) {
+			return n.Permitted
+		}
+	}
+	return false
+}
+
+// Permits reports whether the AS path like '65001 65002' is permitted by the
+// list.
+func (list *ASPath) Permits(path string) (bool, error) {
+	for _, n := range list.Nodes {
+		re, err := asRegexp(n.ASNExpr)
+		if err != nil {
+			return false, err
+		}
+		if re.MatchString(path) {
+			return n.Permitted, nil
+		}
+	}
+	return false, nil
+}
+
+// Permits reports whether the communities are permitted by the list.
+func (list *Community) Permits(communities []string) (bool, error) {
+	values := map[string]bool{}
+	var normalized []string
+	for _, c := range communities {
+		values[communityValue(c)] = true
+		normalized = append(normalized, communityValue(c))
+	}
+	for _, n := range list.Nodes {
+		matched := true
+		if n.Expanded {
+			re, err := asRegexp(n.Community)
+			if err != nil {
+				return false, err
+			}
+			matched = re.MatchString(strings.Join(normalized, " "))
+		} else {
+			for _, c := range strings.Fields(n.Community) {
+				if c != "internet" && !values[c] {
+					matched = false
+				}
+			}
+		}
+		if matched {
+			return n.Permitted, nil
+		}
+	}
+	return false, nil
+}
+
+// Evaluate reports whether route is permitted by the route policy, node is
+// the node deciding it, which is nil if no node matches.
+func (p *Policies) Evaluate(policy string, route *Route) (permitted bool, node *RoutePolicyNode, err error) {
+	r, ok := p.RoutePolicies[policy]
+	if !ok {
+		return false, nil, fmt.Errorf("No route-policy '%s'.", policy)
+	}
+	for _, n := range r.Nodes {
+		if len(n.Unsupported) > 0 {
+			return false, n, fmt.Errorf("Unsupported condition '%s' of the node %d of route-policy '%s'.", n.Unsupported[0], n.Index, policy)
+		}
+		matched, err := p.matches(n, route)
+		if err != nil {
+			return false, n, fmt.Errorf("Failed to evaluate the node %d of route-policy '%s'. %v", n.Index, policy, err)
+		}
+		if matched {
+			return n.Permitted, n, nil
+		}
+	}
+	return false, nil, nil
+}
+
+// matches reports whether all the conditions of node match route.
+func (p *Policies) matches(node *RoutePolicyNode, route *Route) (bool, error) {
+	if len(node.IPPrefix) > 0 {
+		matched := false
+		for _, name := range node.IPPrefix {
+			list, ok := p.IPPrefixes[name]
+			if !ok {
+				return false, fmt.Errorf("No prefix list '%s'.", name)
+			}
+			matched = matched || list.Permits(route.Prefix)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if len(node.ASPath) > 0 {
+		matched := false
+		for _, name := range node.ASPath {
+			list, ok := p.ASPaths[name]
+			if !ok {
+				return false, fmt.Errorf("No AS path filter '%s'.", name)
+			}
+			permitted, err := list.Permits(route.ASPath)
+			if err != nil {
+				return false, err
+			}
+			matched = matched || permitted
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if len(node.Community) > 0 {
+		matched := false
+		for _, name := range node.Community {
+			list, ok := p.Communities[name]
+			if !ok {
+				return false, fmt.Errorf("No community filter '%s'.", name)
+			}
+			permitted, err := list.Permits(route.Communities)
+			if err != nil {
+				return false, err
+			}
+			matched = matched || permitted
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
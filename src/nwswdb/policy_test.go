@@ -0,0 +1,261 @@
+package nwswdb
+
+import (
+	nwnet "nwnet"
+	"strings"
+	"testing"
+)
+
+func parsePolicies(t *testing.T, config string) *Policies {
+	p, err := ParsePolicies(ParseConfig(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func routePrefix(t *testing.T, prefix string) nwnet.V4Prefix {
+	p, err := nwnet.IPv4(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *p
+}
+
+func TestIPPrefixPermits(t *testing.T) {
+	cases := []struct {
+		name, config, list string
+		prefixes           map[string]bool
+	}{
+		{
+			name: "h3c greater-equal and less-equal",
+			config: `ip prefix-list RANGE index 10 permit 10.0.0.0 8 greater-equal 16 less-equal 24
+ip prefix-list RANGE index 20 permit 192.168.0.0 16
+ip prefix-list RANGE index 30 permit 172.16.0.0 12 greater-equal 24
+ip prefix-list RANGE index 40 permit 100.64.0.0 10 less-equal 16
+ip prefix-list RANGE index 50 permit 198.18.0.0 15 less-equal 24 greater-equal 20`,
+			list: "RANGE",
+			prefixes: map[string]bool{
+				"10.1.0.0/16":    true,
+				"10.1.1.0/24":    true,
+				"10.0.0.0/8":     false,
+				"10.1.1.0/25":    false,
+				"192.168.0.0/16": true,
+				"192.168.1.0/24": false,
+				"172.16.1.0/24":  true,
+				"172.16.1.1/32":  true,
+				"172.16.0.0/16":  false,
+				"100.64.0.0/10":  true,
+				"100.65.0.0/16":  true,
+				"100.64.1.0/24":  false,
+				"198.18.0.0/20":  true,
+				"198.18.0.0/16":  false,
+				"198.18.0.0/25":  false,
+				"11.0.0.0/16":    false,
+			},
+		},
+		{
+			name: "huawei",
+			config: `ip ip-prefix HOSTS index 10 permit 10.0.0.0 8 greater-equal 24 less-equal 32
+ip ip-prefix HOSTS index 20 deny 0.0.0.0 0 less-equal 32`,
+			list: "HOSTS",
+			prefixes: map[string]bool{
+				"10.1.1.1/32": true,
+				"10.1.1.0/24": true,
+				"10.1.0.0/16": false,
+				"0.0.0.0/0":   false,
+			},
+		},
+		{
+			// The nodes are sorted by seq, the deny node shadows the default
+			// route of the permit node.
+			name: "cisco seq order",
+			config: `ip prefix-list NODEFAULT seq 10 permit 0.0.0.0/0 le 32
+ip prefix-list NODEFAULT seq 5 deny 0.0.0.0/0`,
+			list: "NODEFAULT",
+			prefixes: map[string]bool{
+				"0.0.0.0/0":  false,
+				"10.0.0.0/8": true,
+			},
+		},
+		{
+			name: "nexus ge only",
+			config: `ip prefix-list LONG permit 10.0.0.0/8 ge 25
+ip prefix-list LONG deny 10.0.0.0/8 le 32`,
+			list: "LONG",
+			prefixes: map[string]bool{
+				"10.1.1.128/25": true,
+				"10.1.1.1/32":   true,
+				"10.1.1.0/24":   false,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, ok := parsePolicies(t, c.config).IPPrefixes[c.list]
+			if !ok {
+				t.Fatalf("no prefix list '%s'", c.list)
+			}
+			for prefix, want := range c.prefixes {
+				if got := list.Permits(routePrefix(t, prefix)); got != want {
+					t.Errorf("%s: got %v, want %v", prefix, got, want)
+				}
+			}
+		})
+	}
+}
+
+const h3cPolicies = `ip prefix-list CUST index 10 permit 10.0.0.0 8 greater-equal 16 less-equal 24
+ip as-path FROM_65002 permit ^65002_
+ip community-list basic NOEXPORT permit no-export
+ip community-list advanced TAGGED permit _65001:1[0-9][0-9]_
+#
+route-policy IMPORT deny node 5
+ if-match community NOEXPORT
+route-policy IMPORT permit node 20
+ if-match ip address prefix-list CUST
+route-policy IMPORT permit node 10
+ if-match ip address prefix-list CUST
+ if-match as-path FROM_65002
+ apply local-preference 200
+route-policy IMPORT permit node 30
+ if-match community TAGGED`
+
+const ciscoPolicies = `ip prefix-list ALL seq 5 permit 0.0.0.0/0 le 32
+ip prefix-list CUST seq 5 permit 10.0.0.0/8 le 24
+ip prefix-list LOOPBACK seq 5 permit 10.255.0.0/16 ge 32
+ip as-path access-list 1 permit _65003$
+!
+route-map EXPORT permit 10
+ match ip address prefix-list ALL
+route-map EXPORT deny 20
+ match ip address prefix-list CUST
+route-map EXPORT permit 30
+ match as-path 1
+!
+route-map LOOPBACKS
+ match ip address prefix-list CUST LOOPBACK
+ set community no-export`
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		name, config, policy string
+		route                Route
+		permitted            bool
+		node                 int
+	}{
+		{
+			name:   "nodes by index",
+			config: h3cPolicies, policy: "IMPORT",
+			route:     Route{Prefix: routePrefix(t, "10.1.0.0/16"), ASPath: "65002 65010"},
+			permitted: true, node: 10,
+		},
+		{
+			name:   "all conditions of a node",
+			config: h3cPolicies, policy: "IMPORT",
+			route:     Route{Prefix: routePrefix(t, "10.1.0.0/16"), ASPath: "65020 65002"},
+			permitted: true, node: 20,
+		},
+		{
+			name:   "denied by well known community",
+			config: h3cPolicies, policy: "IMPORT",
+			route:     Route{Prefix: routePrefix(t, "10.1.0.0/16"), ASPath: "65002", Communities: []string{"65001:100", "no-export"}},
+			permitted: false, node: 5,
+		},
+		{
+			name:   "numeric community",
+			config: h3cPolicies, policy: "IMPORT",
+			route:     Route{Prefix: routePrefix(t, "10.1.0.0/16"), Communities: []string{"4294967041"}},
+			permitted: false, node: 5,
+		},
+		{
+			name:   "expanded community",
+			config: h3cPolicies, policy: "IMPORT",
+			route:     Route{Prefix: routePrefix(t, "20.0.0.0/8"), Communities: []string{"65001:150"}},
+			permitted: true, node: 30,
+		},
+		{
+			name:   "no node matches",
+			config: h3cPolicies, policy: "IMPORT",
+			route:     Route{Prefix: routePrefix(t, "20.0.0.0/8"), ASPath: "65002", Communities: []string{"65001:1500"}},
+			permitted: false, node: 0,
+		},
+		{
+			name:   "deny node shadowed",
+			config: ciscoPolicies, policy: "EXPORT",
+			route:     Route{Prefix: routePrefix(t, "10.1.0.0/16"), ASPath: "65003"},
+			permitted: true, node: 10,
+		},
+		{
+			name:   "one of the filters",
+			config: ciscoPolicies, policy: "LOOPBACKS",
+			route:     Route{Prefix: routePrefix(t, "10.255.0.1/32")},
+			permitted: true, node: 10,
+		},
+		{
+			name:   "none of the filters",
+			config: ciscoPolicies, policy: "LOOPBACKS",
+			route:     Route{Prefix: routePrefix(t, "10.1.1.0/25")},
+			permitted: false, node: 0,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			permitted, node, err := parsePolicies(t, c.config).Evaluate(c.policy, &c.route)
+			if err != nil {
+				t.Fatal(err)
+			}
+			index := 0
+			if node != nil {
+				index = node.Index
+			}
+			if permitted != c.permitted || index != c.node {
+				t.Errorf("got %v by node %d, want %v by node %d", permitted, index, c.permitted, c.node)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	cases := []struct {
+		name, config, policy, err string
+	}{
+		{
+			name: "unsupported condition",
+			config: `route-map A permit 10
+ match community 1 exact-match`,
+			policy: "A",
+			err:    "Unsupported condition 'match community 1 exact-match' of the node 10",
+		},
+		{
+			name: "no filter",
+			config: `route-policy A permit node 10
+ if-match ip address prefix-list NONE`,
+			policy: "A",
+			err:    "No prefix list 'NONE'.",
+		},
+		{
+			name: "invalid regular expression",
+			config: `ip as-path access-list BAD permit (65001
+route-map A permit 10
+ match as-path BAD`,
+			policy: "A",
+			err:    "Invalid regular expression '(65001'.",
+		},
+		{
+			name:   "no policy",
+			config: "route-map A permit 10",
+			policy: "B",
+			err:    "No route-policy 'B'.",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			route := &Route{Prefix: routePrefix(t, "10.0.0.0/8"), ASPath: "65001"}
+			_, _, err := parsePolicies(t, c.config).Evaluate(c.policy, route)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("error = %v, want %q", err, c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package nwswdb
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nwparse"
+	"nwssh"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+Store keeps the snapshots of the devices in a BoltDB file, a snapshot is the
+models collected from a device at a time:
+
+	snapshots
+	 <host>
+	  <time>  the snapshot as JSON
+
+The time is the big endian nanoseconds, so the snapshots of a host are in the
+order of time. Each snapshot has a digest of its models except the volatile
+states like the uptime of the BGP peers, two snapshots with the same digest
+mean the device has not changed. The queries are:
+
+	At           the snapshot of a host at a time, such as the end of a day.
+	ChangedSince the hosts changed since a time, such as 24 hours ago.
+*/
+
+var snapshotsBucket = []byte("snapshots")
+
+// Snapshot is the models of a device collected at Time. BGP and OSPF are nil
+// if they are not configured, or not supported by the device or the vendor.
+type Snapshot struct {
+	Host   string
+	Time   time.Time
+	Device *NetworkDevice
+	BGP    *BGP
+	OSPF   []*OSPF
+	Digest string
+}
+
+// CollectSnapshot collects the models of dev, the session should be prepared
+// by SessionPreparation. The builtin templates of nwparse are used if index is
+// nil.
+func CollectSnapshot(dev nwssh.SSHBASE, host string, index *nwparse.Index) (*Snapshot, error) {
+	var err error
+	if index == nil {
+		if index, err = nwparse.Builtin(); err != nil {
+			return nil, err
+		}
+	}
+	snapshot := &Snapshot{Host: host, Time: time.Now()}
+	if snapshot.Device, err = Collect(dev, index); err != nil {
+		return nil, err
+	}
+	vendor := snapshot.Device.Vendor
+	if _, ok := bgpPeerCommands[vendor]; ok {
+		if snapshot.BGP, err = CollectBGP(dev, vendor, index); err != nil && !rejected(err) {
+			return nil, err
+		}
+		if snapshot.BGP != nil && snapshot.BGP.ASNum == 0 && len(snapshot.BGP.Peers) == 0 {
+			snapshot.BGP = nil
+		}
+	}
+	if _, ok := ospfNeighborCommands[vendor]; ok {
+		if snapshot.OSPF, err = CollectOSPF(dev, vendor, index); err != nil && !rejected(err) {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// rejected reports whether err is a command rejected by the device, which
+// means the protocol is not running or not supported by the device.
+func rejected(err error) bool {
+	var r *nwssh.CommandRejected
+	return errors.As(err, &r)
+}
+
+// digest returns the digest of the models of s except the volatile states,
+// the operational states of the interfaces, the states, uptimes and received
+// prefixes of the BGP peers and the states of the OSPF neighbors. The dead
+// timers are not in the models.
+func (s *Snapshot) digest() (string, error) {
+	stable := struct {
+		Device *NetworkDevice
+		BGP    *BGP
+		OSPF   []*OSPF
+	}{stableDevice(s.Device), s.BGP, s.OSPF}
+	if s.BGP != nil {
+		bgp := *s.BGP
+		bgp.Peers = nil
+		for _, p := range s.BGP.Peers {
+			peer := *p
+			peer.Status, peer.Uptime, peer.PrefixReceived = 0, "", 0
+			bgp.Peers = append(bgp.Peers, &peer)
+		}
+		stable.BGP = &bgp
+	}
+	stable.OSPF = nil
+	for _, o := range s.OSPF {
+		ospf := *o
+		ospf.Neighbors = nil
+		for _, n := range o.Neighbors {
+			neighbor := *n
+			neighbor.State = ""
+			ospf.Neighbors = append(ospf.Neighbors, &neighbor)
+		}
+		stable.OSPF = append(stable.OSPF, &ospf)
+	}
+	data, err := json.Marshal(stable)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// stableDevice returns a copy of d with the operational states of the
+// interfaces cleared, so a link flap doesn't change the digest.
+func stableDevice(d *NetworkDevice) *NetworkDevice {
+	if d == nil {
+		return nil
+	}
+	ports := func(ps []*PhysicalInterface) []*PhysicalInterface {
+		var copied []*PhysicalInterface
+		for _, p := range ps {
+			port := *p
+			port.OperStatus = false
+			copied = append(copied, &port)
+		}
+		return copied
+	}
+	device := *d
+	device.MemberDevice = nil
+	for _, h := range d.MemberDevice {
+		hard := *h
+		hard.MemberLineCard = nil
+		for _, l := range h.MemberLineCard {
+			card := *l
+			card.MemberPort = ports(l.MemberPort)
+			hard.MemberLineCard = append(hard.MemberLineCard, &card)
+		}
+		device.MemberDevice = append(device.MemberDevice, &hard)
+	}
+	device.MemberVlanIf = nil
+	for _, v := range d.MemberVlanIf {
+		vlanif := *v
+		vlanif.OperStatus = false
+		device.MemberVlanIf = append(device.MemberVlanIf, &vlanif)
+	}
+	device.MemberAggr = nil
+	for _, a := range d.MemberAggr {
+		aggr := *a
+		aggr.OperStatus = false
+		aggr.MemberPort = ports(a.MemberPort)
+		device.MemberAggr = append(device.MemberAggr, &aggr)
+	}
+	return &device
+}
+
+// Store is a BoltDB file of snapshots, it's safe to save the snapshots of
+// different hosts at the same time.
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens the store at path, it's created if not exists.
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open the store '%s'. %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(snapshotsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to init the store '%s'. %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// Save saves the snapshot and sets its digest.
+func (s *Store) Save(snapshot *Snapshot) error {
+	digest, err := snapshot.digest()
+	if err != nil {
+		return err
+	}
+	snapshot.Digest = digest
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(snapshotsBucket).CreateBucketIfNotExists([]byte(snapshot.Host))
+		if err != nil {
+			return err
+		}
+		return b.Put(timeKey(snapshot.Time), data)
+	})
+}
+
+// Hosts returns the hosts in the store in order.
+func (s *Store) Hosts() ([]string, error) {
+	var hosts []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).ForEach(func(k, v []byte) error {
+			hosts = append(hosts, string(k))
+			return nil
+		})
+	})
+	sort.Strings(hosts)
+	return hosts, err
+}
+
+// History returns the times of the snapshots of host in order.
+func (s *Store) History(host string) ([]time.Time, error) {
+	var times []time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(snapshotsBucket).Bucket([]byte(host))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			times = append(times, time.Unix(0, int64(binary.BigEndian.Uint64(k))))
+			return nil
+		})
+	})
+	return times, err
+}
+
+// At returns the last snapshot of host taken at or before t, it's nil if
+// there's none.
+func (s *Store) At(host string, t time.Time) (*Snapshot, error) {
+	var snapshot *Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(snapshotsBucket).Bucket([]byte(host))
+		if b == nil {
+			return nil
+		}
+		data := lastBefore(b.Cursor(), t)
+		if data == nil {
+			return nil
+		}
+		snapshot = &Snapshot{}
+		return json.Unmarshal(data, snapshot)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the snapshot of '%s'. %v", host, err)
+	}
+	return snapshot, nil
+}
+
+// lastBefore returns the value of the last key not after t.
+func lastBefore(c *bolt.Cursor, t time.Time) []byte {
+	key := timeKey(t)
+	k, v := c.Seek(key)
+	if k == nil {
+		k, v = c.Last()
+	} else if string(k) != string(key) {
+		k, v = c.Prev()
+	}
+	if k == nil {
+		return nil
+	}
+	return v
+}
+
+// ChangedSince returns the hosts whose last snapshot differs from the one at
+// t, the hosts first collected after t are changed too.
+func (s *Store) ChangedSince(t time.Time) ([]string, error) {
+	var hosts []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).ForEach(func(host, _ []byte) error {
+			b := tx.Bucket(snapshotsBucket).Bucket(host)
+			_, last := b.Cursor().Last()
+			if last == nil {
+				return nil
+			}
+			before := lastBefore(b.Cursor(), t)
+			if before != nil {
+				var old, now Snapshot
+				if err := json.Unmarshal(before, &old); err != nil {
+					return err
+				}
+				if err := json.Unmarshal(last, &now); err != nil {
+					return err
+				}
+				if old.Digest == now.Digest {
+					return nil
+				}
+			}
+			hosts = append(hosts, string(host))
+			return nil
+		})
+	})
+	sort.Strings(hosts)
+	return hosts, err
+}
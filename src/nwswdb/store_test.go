@@ -0,0 +1,137 @@
+package nwswdb
+
+import (
+	nwnet "nwnet"
+	"testing"
+)
+
+// sampleSnapshot returns the models of a device with a BGP peer and an OSPF
+// neighbor, the states are given by the arguments. The interfaces are all up.
+func sampleSnapshot(status BGPSTATUS, uptime string, received int, state string) *Snapshot {
+	peer, _ := nwnet.IPv4("10.0.0.2/32")
+	network, _ := nwnet.IPv4("10.1.0.0/24")
+	port := &PhysicalInterface{Name: "Ten-GigabitEthernet1/0/1", ShortName: "XGE1/0/1", PortNum: 1, OperStatus: true, AdminStatus: true}
+	return &Snapshot{
+		Host: "10.10.10.10",
+		Device: &NetworkDevice{
+			Name:   "BJ-S6850",
+			Vendor: "H3C",
+			MemberDevice: []*HardDevice{
+				{ChassisNum: 1, MemberLineCard: []*LineCard{{Name: "S6850-56HF", SlotNum: 1, MemberPort: []*PhysicalInterface{port}}}},
+			},
+			MemberVlanIf: []*VlanInterface{
+				{Name: "Vlan-interface10", ShortName: "Vlan10", OperStatus: true, AdminStatus: true},
+			},
+			MemberAggr: []*AggregatedInterface{
+				{AggrNum: 1, ShortName: "BAGG1", OperStatus: true, AdminStatus: true, MemberPort: []*PhysicalInterface{port}},
+			},
+		},
+		BGP: &BGP{
+			ASNum:    65001,
+			RouterID: "1.1.1.1",
+			Peers: []*BGPPeer{
+				{Address: *peer, RemoteAS: 65002, Enabled: true, Status: status, Uptime: uptime, PrefixReceived: received},
+			},
+		},
+		OSPF: []*OSPF{
+			{
+				RouterID:      "1.1.1.1",
+				ProcessID:     "1",
+				AreaID:        "0.0.0.0",
+				ActiveNetwork: []*nwnet.V4Prefix{network},
+				Neighbors: []*OSPFNeighbor{
+					{RouterID: "2.2.2.2", Address: "10.1.0.2", Interface: "Vlan-interface10", State: state},
+				},
+			},
+		},
+	}
+}
+
+// sampleChanged returns the sample snapshot changed by change.
+func sampleChanged(change func(s *Snapshot)) *Snapshot {
+	s := sampleSnapshot(Established, "10d02h", 100, "Full/DR")
+	change(s)
+	return s
+}
+
+func TestDigestStable(t *testing.T) {
+	base := sampleSnapshot(Established, "10d02h", 100, "Full/DR")
+	want, err := base.digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	volatile := []struct {
+		name     string
+		snapshot *Snapshot
+	}{
+		{"uptime", sampleSnapshot(Established, "00:00:05", 100, "Full/DR")},
+		{"prefixes", sampleSnapshot(Established, "10d02h", 120, "Full/DR")},
+		{"bgp status", sampleSnapshot(Active, "10d02h", 100, "Full/DR")},
+		{"ospf state", sampleSnapshot(Established, "10d02h", 100, "Init/DROther")},
+		{"port down", sampleChanged(func(s *Snapshot) {
+			s.Device.MemberDevice[0].MemberLineCard[0].MemberPort[0].OperStatus = false
+		})},
+		{"vlan interface down", sampleChanged(func(s *Snapshot) { s.Device.MemberVlanIf[0].OperStatus = false })},
+		{"aggregation down", sampleChanged(func(s *Snapshot) { s.Device.MemberAggr[0].OperStatus = false })},
+	}
+	for _, c := range volatile {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.snapshot.digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("digest changed by %s", c.name)
+			}
+		})
+	}
+}
+
+func TestDigestChanged(t *testing.T) {
+	want, err := sampleSnapshot(Established, "10d02h", 100, "Full/DR").digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := []struct {
+		name   string
+		change func(s *Snapshot)
+	}{
+		{"remote as", func(s *Snapshot) { s.BGP.Peers[0].RemoteAS = 65003 }},
+		{"peer disabled", func(s *Snapshot) { s.BGP.Peers[0].Enabled = false }},
+		{"router id", func(s *Snapshot) { s.OSPF[0].RouterID = "3.3.3.3" }},
+		{"neighbor", func(s *Snapshot) { s.OSPF[0].Neighbors[0].Interface = "Vlan-interface20" }},
+		{"aggregation", func(s *Snapshot) { s.Device.MemberAggr = nil }},
+		{"port shutdown", func(s *Snapshot) { s.Device.MemberDevice[0].MemberLineCard[0].MemberPort[0].AdminStatus = false }},
+		{"vlan interface description", func(s *Snapshot) { s.Device.MemberVlanIf[0].Description = "servers" }},
+		{"no bgp", func(s *Snapshot) { s.BGP = nil }},
+	}
+	for _, c := range changes {
+		t.Run(c.name, func(t *testing.T) {
+			s := sampleSnapshot(Established, "10d02h", 100, "Full/DR")
+			c.change(s)
+			got, err := s.digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == want {
+				t.Errorf("digest not changed by %s", c.name)
+			}
+		})
+	}
+}
+
+// digest must not modify the snapshot saved with it.
+func TestDigestKeepsStates(t *testing.T) {
+	s := sampleSnapshot(Established, "10d02h", 100, "Full/DR")
+	if _, err := s.digest(); err != nil {
+		t.Fatal(err)
+	}
+	peer, neighbor := s.BGP.Peers[0], s.OSPF[0].Neighbors[0]
+	if peer.Status != Established || peer.Uptime != "10d02h" || peer.PrefixReceived != 100 || neighbor.State != "Full/DR" {
+		t.Errorf("states modified: %+v %+v", peer, neighbor)
+	}
+	port, vlanif, aggr := s.Device.MemberDevice[0].MemberLineCard[0].MemberPort[0], s.Device.MemberVlanIf[0], s.Device.MemberAggr[0]
+	if !port.OperStatus || !vlanif.OperStatus || !aggr.OperStatus || !aggr.MemberPort[0].OperStatus {
+		t.Errorf("interface states modified: %+v %+v %+v", port, vlanif, aggr)
+	}
+}
@@ -1,7 +1,7 @@
 package nwswdb
 
 import (
-	"nwnet"
+	nwnet "nwnet"
 )
 
 type NetworkDevice struct {
@@ -92,6 +92,7 @@ type BGP struct {
 type BGPPeerGroup struct {
 	Name         string
 	Type         string
+	RemoteAS     int
 	ConnectIf    string
 	Status       BGPSTATUS
 	ImportPolicy []string
@@ -99,18 +100,31 @@ type BGPPeerGroup struct {
 }
 
 type BGPPeer struct {
-	Address      nwnet.V4Prefix
-	Enabled      bool
-	ConnectIf    string
-	Status       BGPSTATUS
-	ImportPolicy []string
-	ExportPolicy []string
-	PeerGroup    *BGPPeerGroup
+	Address        nwnet.V4Prefix
+	RemoteAS       int
+	Enabled        bool
+	ConnectIf      string
+	Status         BGPSTATUS
+	Uptime         string
+	PrefixReceived int
+	ImportPolicy   []string
+	ExportPolicy   []string
+	PeerGroup      *BGPPeerGroup
 }
 
 type RoutePolicy struct {
-	Filter interface{}
-	Action interface{}
+	Name  string
+	Nodes []*RoutePolicyNode
+}
+
+type RoutePolicyNode struct {
+	Index       int
+	Permitted   bool
+	IPPrefix    []string
+	ASPath      []string
+	Community   []string
+	Unsupported []string
+	Actions     []string
 }
 
 type ACL struct {
@@ -121,14 +135,15 @@ type ACL struct {
 }
 
 type ACLNode struct {
-	Index      int
-	Permitted  bool
-	Protocol   string
-	SrcAddress nwnet.V4Prefix
-	SrcPort    string
-	DstAddress nwnet.V4Prefix
-	DstPort    string
-	VRF        string
+	Index       int
+	Permitted   bool
+	Protocol    string
+	SrcAddress  nwnet.V4Prefix
+	SrcPort     string
+	DstAddress  nwnet.V4Prefix
+	DstPort     string
+	VRF         string
+	Unsupported []string
 }
 
 type IPPrefix struct {
@@ -156,7 +171,15 @@ type ASPathNode struct {
 }
 
 type Community struct {
+	Name  string
+	Nodes []*CommunityNode
+}
+
+type CommunityNode struct {
+	Index     int
+	Permitted bool
 	Community string
+	Expanded  bool
 }
 
 type ROUTETYPE uint8
@@ -179,18 +202,28 @@ type RouteType struct {
 
 type OSPF struct {
 	RouterID        string
-	ProcessID       int8
+	ProcessID       string
 	AreaID          string
 	ActiveNetwork   []*nwnet.V4Prefix
-	ActiveInterface []*interface{}
+	ActiveInterface []string
 	AggrNetwork     []*AggregatedNetwork
+	AbrSummary      []*AbrSummaryNetwork
+	AsbrSummary     []*AsbrSummaryNetwork
 	Nssa            bool
 	ImportPolicy    []*RouteRedistribution
+	Neighbors       []*OSPFNeighbor
+}
+
+type OSPFNeighbor struct {
+	RouterID  string
+	Address   string
+	Interface string
+	State     string
 }
 
 type RouteRedistribution struct {
 	RouteProtocol string
-	RouteProcess  uint8
+	RouteProcess  int
 	RouteCost     int
 	RouteType     ROUTETYPE
 	RoutePolicy   *RoutePolicy
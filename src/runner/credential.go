@@ -0,0 +1,443 @@
+package runner
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nwssh"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+/*
+Credentials are referenced by name, such as the 'credential' variable of the
+inventory, the hosts without reference use the credential named 'default'.
+A credential is looked up from the sources in order until it's found:
+
+	env      SWSSH_<NAME>_USERNAME and SWSSH_<NAME>_PASSWORD, the name is
+	         uppercased and characters other than letters and digits are
+	         replaced by '_'. SWSSH_PASSWORD is used for 'default'.
+	netrc    netrc style file, 'machine <name> login <username> password
+	         <password>', 'default' entry matches any name. The file must
+	         not be accessible by group or others.
+	vault    encrypted vault file, see -vault and -vaultadd.
+	command  external command run by 'sh -c', the name is passed as $1 and
+	         SWSSH_CREDENTIAL, it prints 'username=xxx' and 'password=xxx'
+	         lines, or the password only.
+	prompt   read password from terminal without echo.
+*/
+
+const DefaultCredential = "default"
+
+// stdin is shared by all the readers of terminal, so no input is lost in
+// the buffer of another reader.
+var stdin = bufio.NewReader(os.Stdin)
+
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type CredentialProvider interface {
+	// Credential returns the credential named name, it's nil if the provider
+	// has no such credential.
+	Credential(name string) (*Credential, error)
+}
+
+// CredentialChain looks up credentials from providers in order and caches
+// the credentials found.
+type CredentialChain struct {
+	providers []CredentialProvider
+	cache     map[string]*Credential
+	mutex     sync.Mutex
+}
+
+func (c *CredentialChain) Lookup(name string) (*Credential, error) {
+	if name == "" {
+		name = DefaultCredential
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cred, ok := c.cache[name]; ok {
+		return cred, nil
+	}
+	for _, p := range c.providers {
+		cred, err := p.Credential(name)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get credential '%s'. %v", name, err)
+		}
+		if cred != nil {
+			c.cache[name] = cred
+			return cred, nil
+		}
+	}
+	return nil, fmt.Errorf("Credential '%s' is not found.", name)
+}
+
+// Resolve looks up the credentials named by names which are separated by
+// ',', username is used if the credential has no username.
+func (c *CredentialChain) Resolve(names, username string) ([]nwssh.Credential, error) {
+	var creds []nwssh.Credential
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" && len(creds) > 0 {
+			continue
+		}
+		cred, err := c.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		cr := nwssh.Credential{Name: name, Username: cred.Username, Password: cred.Password}
+		if cr.Name == "" {
+			cr.Name = DefaultCredential
+		}
+		if cr.Username == "" {
+			cr.Username = username
+		}
+		creds = append(creds, cr)
+	}
+	return creds, nil
+}
+
+// CredentialSources names the sources of credentials, Order lists the
+// sources separated by ',', the sources without file or command are skipped.
+type CredentialSources struct {
+	Order   string
+	Netrc   string
+	Vault   string
+	Command string
+}
+
+func NewCredentialChain(sources CredentialSources) (*CredentialChain, error) {
+	chain := &CredentialChain{cache: map[string]*Credential{}}
+	for _, source := range strings.Split(sources.Order, ",") {
+		switch strings.TrimSpace(source) {
+		case "env":
+			chain.providers = append(chain.providers, envProvider{})
+		case "netrc":
+			if sources.Netrc != "" {
+				chain.providers = append(chain.providers, netrcProvider{sources.Netrc})
+			}
+		case "vault":
+			if sources.Vault != "" {
+				chain.providers = append(chain.providers, &vaultProvider{filename: sources.Vault})
+			}
+		case "command":
+			if sources.Command != "" {
+				chain.providers = append(chain.providers, commandProvider{sources.Command})
+			}
+		case "prompt":
+			chain.providers = append(chain.providers, promptProvider{})
+		case "":
+		default:
+			return nil, fmt.Errorf("Unsupported credential source '%s'.", source)
+		}
+	}
+	return chain, nil
+}
+
+type envProvider struct{}
+
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'A' && r <= 'Z':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, name)
+}
+
+func (envProvider) Credential(name string) (*Credential, error) {
+	prefix := "SWSSH_" + envName(name) + "_"
+	if name == DefaultCredential {
+		prefix = "SWSSH_"
+	}
+	password, ok := os.LookupEnv(prefix + "PASSWORD")
+	if !ok {
+		return nil, nil
+	}
+	return &Credential{Username: os.Getenv(prefix + "USERNAME"), Password: password}, nil
+}
+
+type netrcProvider struct {
+	filename string
+}
+
+func (p netrcProvider) Credential(name string) (*Credential, error) {
+	info, err := os.Stat(p.filename)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("Permissions of '%s' are too open, it must not be accessible by group or others.", p.filename)
+	}
+	content, err := os.ReadFile(p.filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched, fallback *Credential
+	var current *Credential
+	fields := strings.Fields(string(content))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine", "default":
+			current = &Credential{}
+			if fields[i] == "default" {
+				fallback = current
+			} else if i+1 < len(fields) {
+				i++
+				if fields[i] == name && matched == nil {
+					matched = current
+				}
+			}
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("Invalid netrc file '%s', value of '%s' is missing.", p.filename, fields[i])
+			}
+			if current != nil && fields[i] == "login" {
+				current.Username = fields[i+1]
+			}
+			if current != nil && fields[i] == "password" {
+				current.Password = fields[i+1]
+			}
+			i++
+		}
+	}
+	if matched != nil {
+		return matched, nil
+	}
+	return fallback, nil
+}
+
+type commandProvider struct {
+	command string
+}
+
+func (p commandProvider) Credential(name string) (*Credential, error) {
+	cmd := exec.Command("sh", "-c", p.command, "sh", name)
+	cmd.Env = append(os.Environ(), "SWSSH_CREDENTIAL="+name)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Credential command failed. %v", err)
+	}
+
+	output := strings.TrimSpace(string(out))
+	if output == "" {
+		return nil, nil
+	}
+	if !strings.Contains(output, "=") {
+		return &Credential{Password: output}, nil
+	}
+
+	cred := &Credential{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "username":
+			cred.Username = strings.TrimSpace(kv[1])
+		case "password":
+			cred.Password = kv[1]
+		}
+	}
+	return cred, nil
+}
+
+type promptProvider struct{}
+
+func (promptProvider) Credential(name string) (*Credential, error) {
+	prompt := "Please input the password:"
+	if name != DefaultCredential {
+		prompt = fmt.Sprintf("Please input the password of credential '%s':", name)
+	}
+	password, err := ReadSecret(prompt)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{Password: password}, nil
+}
+
+// ReadSecret reads a line from terminal without echo, if stdin is not a
+// terminal, the line is read as it is.
+func ReadSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		return string(secret), err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+/*
+Vault is a json file encrypted by AES-256-GCM, the key is derived from the
+passphrase by scrypt. The passphrase is read from SWSSH_VAULT_PASSPHRASE or
+the terminal.
+*/
+type vaultFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+type vaultProvider struct {
+	filename    string
+	credentials map[string]*Credential
+	passphrase  string
+}
+
+func vaultKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func (v *vaultProvider) readPassphrase() error {
+	if v.passphrase != "" {
+		return nil
+	}
+	if passphrase, ok := os.LookupEnv("SWSSH_VAULT_PASSPHRASE"); ok {
+		v.passphrase = passphrase
+		return nil
+	}
+	passphrase, err := ReadSecret(fmt.Sprintf("Please input the passphrase of vault '%s':", v.filename))
+	if err != nil {
+		return err
+	}
+	v.passphrase = passphrase
+	return nil
+}
+
+func (v *vaultProvider) load() error {
+	if v.credentials != nil {
+		return nil
+	}
+	content, err := os.ReadFile(v.filename)
+	if os.IsNotExist(err) {
+		v.credentials = map[string]*Credential{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var vault vaultFile
+	if err = json.Unmarshal(content, &vault); err != nil {
+		return fmt.Errorf("Invalid vault file '%s'. %v", v.filename, err)
+	}
+	if err = v.readPassphrase(); err != nil {
+		return err
+	}
+	key, err := vaultKey(v.passphrase, vault.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	data, err := gcm.Open(nil, vault.Nonce, vault.Data, nil)
+	if err != nil {
+		return errors.New("Failed to decrypt vault, passphrase is wrong or the vault is broken.")
+	}
+
+	credentials := map[string]*Credential{}
+	if err = json.Unmarshal(data, &credentials); err != nil {
+		return fmt.Errorf("Invalid vault file '%s'. %v", v.filename, err)
+	}
+	v.credentials = credentials
+	return nil
+}
+
+func (v *vaultProvider) save() error {
+	if err := v.readPassphrase(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v.credentials)
+	if err != nil {
+		return err
+	}
+
+	vault := vaultFile{Salt: make([]byte, 16)}
+	if _, err = rand.Read(vault.Salt); err != nil {
+		return err
+	}
+	key, err := vaultKey(v.passphrase, vault.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	vault.Nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(vault.Nonce); err != nil {
+		return err
+	}
+	vault.Data = gcm.Seal(nil, vault.Nonce, data, nil)
+
+	content, err := json.Marshal(vault)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(v.filename, content, 0600)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *vaultProvider) Credential(name string) (*Credential, error) {
+	if err := v.load(); err != nil {
+		return nil, err
+	}
+	return v.credentials[name], nil
+}
+
+// AddVaultCredential reads username and password from terminal and saves
+// them into the vault as credential name.
+func AddVaultCredential(filename, name string) error {
+	v := &vaultProvider{filename: filename}
+	if err := v.load(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Username of credential '%s':", name)
+	username, err := stdin.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	password, err := ReadSecret(fmt.Sprintf("Password of credential '%s':", name))
+	if err != nil {
+		return err
+	}
+
+	v.credentials[name] = &Credential{Username: strings.TrimSpace(username), Password: password}
+	return v.save()
+}
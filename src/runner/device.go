@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"nwssh"
+	"strings"
+	"time"
+)
+
+// Vendors are the vendors supported by the drivers of nwssh.
+var Vendors = []string{"H3C", "HUAWEI", "NEXUS", "CISCO", "RUIJIE"}
+
+// NewDriver returns the driver of vendor, it's nil if the vendor is not
+// supported. ExecCommand* of the driver check the output of commands by the
+// error patterns of vendor.
+func NewDriver(vendor string, devssh *nwssh.SSHBase) nwssh.SSHBASE {
+	devssh.SetErrorPatterns(nwssh.ErrorPatterns(vendor))
+	switch vendor {
+	case "H3C":
+		return &nwssh.H3cSSH{SSHBase: devssh}
+	case "HUAWEI":
+		return &nwssh.HuaweiSSH{SSHBase: devssh}
+	case "NEXUS":
+		return &nwssh.NexusSSH{SSHBase: devssh}
+	case "CISCO":
+		return &nwssh.CiscoSSH{SSHBase: devssh}
+	case "RUIJIE":
+		return &nwssh.RuijieSSH{SSHBase: devssh}
+	}
+	return nil
+}
+
+// LoadErrorPatterns adds the error patterns of a file, one pattern per line
+// like 'H3C ^Error:', the pattern applies to all the vendors if the first
+// field is not a vendor.
+func LoadErrorPatterns(filename string) error {
+	lines, err := ReadLines(filename)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		vendor, pattern := "", line
+		if fields := strings.SplitN(line, " ", 2); len(fields) == 2 && contains(Vendors, strings.ToUpper(fields[0])) {
+			vendor, pattern = strings.ToUpper(fields[0]), strings.TrimSpace(fields[1])
+		}
+		if err := nwssh.AddErrorPattern(vendor, pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Target is a host with everything needed to login, the Vendor is detected
+// if it's empty, the Enable password is used to enter the privileged mode.
+type Target struct {
+	Host     string
+	Port     string
+	Vendor   string
+	Username string
+	Password string
+	Enable   string
+	Timeout  time.Duration
+	Options  nwssh.SSHOptions
+	Vars     map[string]string //Variables of the host, used by the group caps of the pool.
+	Cache    *nwssh.DetectCache
+}
+
+// Targets returns a target of each of hosts with the settings of base.
+func Targets(hosts []string, base Target) []*Target {
+	var targets []*Target
+	for _, host := range hosts {
+		t := base
+		t.Host = host
+		targets = append(targets, &t)
+	}
+	return targets
+}
+
+// Device is a logged in target, Driver is the driver of its vendor. Detection
+// is nil if the vendor is declared by the target.
+type Device struct {
+	Target    *Target
+	Vendor    string
+	Detection *nwssh.Detection
+	SSH       *nwssh.SSHBase
+	Driver    nwssh.SSHBASE
+}
+
+// Credential returns the name of the credential accepted by the device.
+func (d *Device) Credential() string {
+	if d == nil || d.SSH == nil {
+		return ""
+	}
+	return d.SSH.Credential()
+}
+
+func (d *Device) Close() {
+	if d != nil && d.SSH != nil {
+		d.SSH.Close()
+	}
+}
+
+// Connect logins to the target and returns the device with the driver of its
+// vendor. The returned device should be closed even if an error is returned.
+func Connect(t *Target) (*Device, error) {
+	var banner string
+	dev := &Device{Target: t, Vendor: strings.ToUpper(t.Vendor)}
+	if dev.Vendor == "" && t.Cache != nil {
+		if d, ok := t.Cache.Load(t.Host); ok {
+			dev.Vendor, dev.Detection = d.Vendor, &d
+		}
+	}
+	sshoptions := t.Options
+	if dev.Vendor == "" {
+		sshoptions.BannerCallback = func(message string) error {
+			banner = message
+			return nil
+		}
+	}
+
+	devssh, err := nwssh.SSH(t.Host, t.Port, t.Username, t.Password, t.Timeout, sshoptions)
+	if err != nil {
+		return dev, err
+	}
+	dev.SSH = devssh
+	if err = devssh.Connect(); err != nil {
+		return dev, err
+	}
+	if len(sshoptions.Credentials) > 1 {
+		log.Printf("[%s]Logged in with credential '%s'.\n", t.Host, devssh.Credential())
+	}
+
+	if dev.Vendor == "" {
+		d := devssh.Detect(banner)
+		dev.Detection = &d
+		if d.Vendor == "" {
+			return dev, fmt.Errorf("Failed to parse device's vendor automatically.")
+		}
+		if d.Confidence < nwssh.DetectMinConfidence {
+			return dev, fmt.Errorf("Failed to parse device's vendor automatically, '%s' is guessed with low confidence %.2f.", d.Vendor, d.Confidence)
+		}
+		dev.Vendor = d.Vendor
+		if t.Cache != nil {
+			if err = t.Cache.Store(t.Host, d); err != nil {
+				log.Printf("[%s]%v\n", t.Host, err)
+			}
+		}
+	}
+
+	dev.Driver = NewDriver(dev.Vendor, devssh)
+	if dev.Driver == nil {
+		return dev, fmt.Errorf("Unsupported vendor '%s'.", dev.Vendor)
+	}
+
+	if t.Enable != "" {
+		if err = dev.Driver.Enable(t.Enable); err != nil {
+			return dev, err
+		}
+	}
+	return dev, nil
+}
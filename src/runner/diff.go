@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// edit is a line of the edit script, op is ' ' for the common line, '-' for
+// the line deleted from a and '+' for the line inserted from b.
+type edit struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the shortest edit script from a to b by Myers' algorithm,
+// the common prefix and suffix are trimmed first since the changes of a
+// configuration are usually small.
+func diffLines(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{' ', line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k] is the furthest x on diagonal k, trace[d] keeps v[-d..d] before
+	// the round d for backtracking.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	var d int
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	var edits []edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		prev := func(k int) int {
+			return trace[d][k+d]
+		}
+		k := x - y
+		var pk int
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := prev(pk)
+		py := px - pk
+		for x > px && y > py {
+			edits = append(edits, edit{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == px {
+			edits = append(edits, edit{'+', b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// SplitLines splits text into the lines for UnifiedDiff, the line break at the
+// end is optional so the outputs with and without it are the same, and an
+// empty text has no line.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// UnifiedDiff returns the unified diff from a to b with context lines around
+// the changes, it's empty if a and b are the same.
+func UnifiedDiff(a, b []string, from, to string, context int) string {
+	edits := diffLines(a, b)
+
+	var changes []int
+	for i, e := range edits {
+		if e.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", from, to)
+
+	// aline and bline are the line numbers of edits[i] in a and b.
+	aline := make([]int, len(edits)+1)
+	bline := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aline[i+1], bline[i+1] = aline[i], bline[i]
+		if e.op != '+' {
+			aline[i+1]++
+		}
+		if e.op != '-' {
+			bline[i+1]++
+		}
+	}
+
+	for i := 0; i < len(changes); {
+		start := changes[i] - context
+		if start < 0 {
+			start = 0
+		}
+		j := i
+		// The hunks are merged if their context lines touch or overlap.
+		for j+1 < len(changes) && changes[j+1]-changes[j]-1 <= 2*context {
+			j++
+		}
+		end := changes[j] + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(aline[start], aline[end]-aline[start]), hunkRange(bline[start], bline[end]-bline[start]))
+		for _, e := range edits[start:end] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+			buf.WriteByte('\n')
+		}
+		i = j + 1
+	}
+	return buf.String()
+}
+
+// hunkRange formats the range of a hunk, start is the number of lines before
+// the hunk.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
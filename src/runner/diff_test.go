@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// numbers returns the lines '1' to 'n', the lines of replace are replaced
+// like {5: "X"}.
+func numbers(n int, replace map[int]string) []string {
+	var lines []string
+	for i := 1; i <= n; i++ {
+		line := strconv.Itoa(i)
+		if r, ok := replace[i]; ok {
+			line = r
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestSplitLines(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"\n", []string{""}},
+		{"a\nb", []string{"a", "b"}},
+		{"a\nb\n", []string{"a", "b"}},
+		{"a\r\nb\r\n", []string{"a", "b"}},
+		{"a\n\n", []string{"a", ""}},
+	}
+	for _, c := range cases {
+		if got := SplitLines(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("SplitLines(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+// The expected diffs are the same as 'diff -U<context>'.
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name    string
+		a, b    []string
+		context int
+		want    string
+	}{
+		{
+			name:    "same",
+			a:       numbers(5, nil),
+			b:       numbers(5, nil),
+			context: 3,
+		},
+		{
+			name:    "both empty",
+			a:       SplitLines(""),
+			b:       SplitLines(""),
+			context: 3,
+		},
+		{
+			name:    "from empty",
+			a:       SplitLines(""),
+			b:       SplitLines("a\nb\n"),
+			context: 3,
+			want:    "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "to empty",
+			a:       SplitLines("a\nb\n"),
+			b:       SplitLines(""),
+			context: 3,
+			want:    "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:    "no trailing newline",
+			a:       SplitLines("sysname SW1\nreturn"),
+			b:       SplitLines("sysname SW1\r\nreturn\r\n"),
+			context: 3,
+		},
+		{
+			name:    "one change",
+			a:       numbers(20, nil),
+			b:       numbers(20, map[int]string{10: "X"}),
+			context: 3,
+			want:    "@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+X\n 11\n 12\n 13\n",
+		},
+		{
+			name:    "contexts touching are merged",
+			a:       numbers(20, nil),
+			b:       numbers(20, map[int]string{5: "X", 12: "Y"}),
+			context: 3,
+			want:    "@@ -2,14 +2,14 @@\n 2\n 3\n 4\n-5\n+X\n 6\n 7\n 8\n 9\n 10\n 11\n-12\n+Y\n 13\n 14\n 15\n",
+		},
+		{
+			name:    "contexts apart",
+			a:       numbers(20, nil),
+			b:       numbers(20, map[int]string{5: "X", 13: "Y"}),
+			context: 3,
+			want:    "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+X\n 6\n 7\n 8\n@@ -10,7 +10,7 @@\n 10\n 11\n 12\n-13\n+Y\n 14\n 15\n 16\n",
+		},
+		{
+			name:    "no context",
+			a:       []string{"a", "b", "c", "d"},
+			b:       []string{"A", "B", "c", "D"},
+			context: 0,
+			want:    "@@ -1,2 +1,2 @@\n-a\n-b\n+A\n+B\n@@ -4 +4 @@\n-d\n+D\n",
+		},
+		{
+			name:    "insert at the end",
+			a:       numbers(3, nil),
+			b:       numbers(5, nil),
+			context: 3,
+			want:    "@@ -1,3 +1,5 @@\n 1\n 2\n 3\n+4\n+5\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			want := c.want
+			if want != "" {
+				want = "--- a\n+++ b\n" + want
+			}
+			if got := UnifiedDiff(c.a, c.b, "a", "b", c.context); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"nwssh"
+	"os"
+	"sort"
+	"sync"
+)
+
+// FactsReport collects the facts of hosts, they are written in the order of
+// the index given by Add, which is usually the order of the targets.
+type FactsReport struct {
+	facts []indexedFacts
+	mutex sync.Mutex
+}
+
+type indexedFacts struct {
+	index int
+	facts *nwssh.Facts
+}
+
+func (r *FactsReport) Add(index int, facts *nwssh.Facts) {
+	r.mutex.Lock()
+	r.facts = append(r.facts, indexedFacts{index, facts})
+	r.mutex.Unlock()
+}
+
+// sorted returns the facts in the order of index, the caller holds the lock.
+func (r *FactsReport) sorted() []*nwssh.Facts {
+	sort.SliceStable(r.facts, func(i, j int) bool { return r.facts[i].index < r.facts[j].index })
+	var facts []*nwssh.Facts
+	for _, f := range r.facts {
+		facts = append(facts, f.facts)
+	}
+	return facts
+}
+
+// WriteCSV writes the facts to filename with a title line, the serials are
+// joined like '1:SN1;2:SN2'.
+func (r *FactsReport) WriteCSV(filename string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"host", "hostname", "vendor", "model", "os_version", "patch", "serials", "uptime", "mgmt_ip"})
+	for _, f := range r.sorted() {
+		writer.Write([]string{f.Host, f.Hostname, f.Vendor, f.Model, f.OSVersion, f.Patch, f.SerialString(), f.Uptime, f.MgmtIP})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the facts to filename as a JSON array.
+func (r *FactsReport) WriteJSON(filename string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	facts := r.sorted()
+	if facts == nil {
+		facts = []*nwssh.Facts{}
+	}
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFile(filename, string(data)+"\n")
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	nwnet "nwnet"
+	"os"
+	"strings"
+)
+
+// ReadLines reads the lines of filename with the spaces trimmed.
+func ReadLines(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+
+	return lines, nil
+}
+
+func WriteFile(file, conntent string) error {
+	return os.WriteFile(file, []byte(conntent), 0666)
+}
+
+func pathIsExist(path string) bool {
+	_, err := os.Stat(path)
+	if err != nil {
+		if os.IsExist(err) {
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func CreatePath(path string) error {
+	path = strings.TrimRight(path, "/")
+
+	paths := strings.Split(path, "/")
+	if len(paths) == 0 {
+		return fmt.Errorf("Invalied file path '%s'.", path)
+	}
+
+	pathToCreate := ""
+	for _, dir := range paths[0:len(paths)] {
+		pathToCreate += dir + "/"
+		if !pathIsExist(pathToCreate) {
+			if err := os.Mkdir(pathToCreate, 0777); err != nil {
+				return err
+			}
+			if err := os.Chmod(pathToCreate, 0777); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LogFileName returns the name of the log file of host by nwnet.FileName, for
+// example, the log of '2001:db8::1' is written to '2001_db8__1'.
+func LogFileName(host string) string {
+	return nwnet.FileName(host)
+}
+
+// ParseHosts validates hosts and drops the invalid ones, empty lines are
+// ignored.
+func ParseHosts(hosts []string) []string {
+	var parsed []string
+	for _, host := range hosts {
+		if strings.TrimSpace(host) == "" {
+			continue
+		}
+		h, err := nwnet.ParseHost(host)
+		if err != nil {
+			log.Printf("Skip host. %v\n", err)
+			continue
+		}
+		parsed = append(parsed, h)
+	}
+	return parsed
+}
+
+// ReadHosts reads the hosts list file, each host on a separate line.
+func ReadHosts(filename string) ([]string, error) {
+	lines, err := ReadLines(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHosts(lines), nil
+}
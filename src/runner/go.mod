@@ -0,0 +1,16 @@
+module runner
+
+go 1.19
+
+require (
+	golang.org/x/crypto v0.5.0
+	golang.org/x/term v0.10.0
+	nwnet v0.0.0
+	nwssh v0.0.0
+)
+
+require golang.org/x/sys v0.10.0 // indirect
+
+replace nwssh v0.0.0 => ../nwssh
+
+replace nwnet v0.0.0 => ../nwnet
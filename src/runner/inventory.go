@@ -0,0 +1,529 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	nwnet "nwnet"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Inventory file is an INI file like ansible's, hosts are listed under the group
+sections, variables of a group are listed under the '[<group>:vars]' section,
+and the sub groups are listed under the '[<group>:children]' section.
+For example:
+
+	[all:vars]
+	port=22
+
+	[bj_tor]
+	10.10.10.10 hostname=BJ_YF_305-A-15_CE5810 vendor=HUAWEI
+	10.10.10.11 hostname=BJ_YF_305-A-16_CE5810 vendor=HUAWEI tags=canary
+
+	[bj_tor:vars]
+	role=tor
+	credential=tacacs
+
+	[bj:children]
+	bj_tor
+
+	[bj:vars]
+	dc=bj
+	jumphost=admin@10.10.1.1:22
+
+Variables are inherited from the group 'all', the parent groups, the groups
+of the host and the host itself in order, the latter overrides the former,
+except 'tags' which are merged.
+
+The following variables are used to connect the hosts, the others are template
+variables:
+
+	vendor, port, username, password, credential, key, enable, jumphost,
+	transport, tags
+*/
+
+const allGroup = "all"
+
+type InventoryHost struct {
+	Address string
+	Groups  []string
+	Vars    map[string]string
+	Line    int //Line number in the inventory file where the host first appeared.
+}
+
+func (h *InventoryHost) Var(name string) string {
+	return h.Vars[name]
+}
+
+func (h *InventoryHost) Tags() []string {
+	return splitTags(h.Vars["tags"])
+}
+
+type inventoryGroup struct {
+	name     string
+	vars     map[string]string
+	children []string
+}
+
+type Inventory struct {
+	Hosts    []*InventoryHost
+	hosts    map[string]*InventoryHost
+	hostvars map[string]map[string]string
+	groups   map[string]*inventoryGroup
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func mergeTags(a, b string) string {
+	tags := splitTags(a)
+	for _, tag := range splitTags(b) {
+		if !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func (inv *Inventory) group(name string) *inventoryGroup {
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &inventoryGroup{name: name, vars: map[string]string{}}
+		inv.groups[name] = g
+	}
+	return g
+}
+
+func (inv *Inventory) addHost(address, group string, vars map[string]string, line int) {
+	h, ok := inv.hosts[address]
+	if !ok {
+		h = &InventoryHost{Address: address, Line: line}
+		inv.hosts[address] = h
+		inv.hostvars[address] = map[string]string{}
+		inv.Hosts = append(inv.Hosts, h)
+	}
+	if group != "" && !contains(h.Groups, group) {
+		h.Groups = append(h.Groups, group)
+	}
+	for k, v := range vars {
+		inv.hostvars[address][k] = v
+	}
+}
+
+func parseKeyValues(fields []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Invalid variable '%s', 'name=value' is expected.", field)
+		}
+		vars[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return vars, nil
+}
+
+func LoadInventory(filename string) (*Inventory, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	inv := &Inventory{
+		hosts:    map[string]*InventoryHost{},
+		hostvars: map[string]map[string]string{},
+		groups:   map[string]*inventoryGroup{},
+	}
+	inv.group(allGroup)
+
+	group, kind := "", ""
+	scanner := bufio.NewScanner(file)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: Invalid section '%s'.", filename, lineno, line)
+			}
+			section := strings.SplitN(strings.Trim(line, "[]"), ":", 2)
+			group, kind = strings.TrimSpace(section[0]), ""
+			if len(section) == 2 {
+				kind = strings.TrimSpace(section[1])
+			}
+			if group == "" || (kind != "" && kind != "vars" && kind != "children") {
+				return nil, fmt.Errorf("%s:%d: Invalid section '%s'.", filename, lineno, line)
+			}
+			inv.group(group)
+			continue
+		}
+
+		switch kind {
+		case "vars":
+			vars, err := parseKeyValues([]string{line})
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+			}
+			for k, v := range vars {
+				inv.group(group).vars[k] = v
+			}
+		case "children":
+			g := inv.group(group)
+			if !contains(g.children, line) {
+				g.children = append(g.children, line)
+			}
+			inv.group(line)
+		default:
+			fields := strings.Fields(line)
+			vars, err := parseKeyValues(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+			}
+			inv.addHost(fields[0], group, vars, lineno)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, h := range inv.Hosts {
+		h.Vars = inv.resolveVars(h)
+	}
+	if err := validateHosts(filename, inv.Hosts); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// validateHosts checks the variables used to connect of each host, all the
+// malformed hosts are reported with line numbers.
+func validateHosts(filename string, hosts []*InventoryHost) error {
+	var errs []string
+	for _, h := range hosts {
+		if err := validateHost(h); err != nil {
+			errs = append(errs, fmt.Sprintf("%s:%d: [%s]%v", filename, h.Line, h.Address, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// validateHost checks the host and normalizes its address.
+func validateHost(h *InventoryHost) error {
+	addr, err := nwnet.ParseHost(h.Address)
+	if err != nil {
+		return err
+	}
+	h.Address = addr
+	if port := h.Var("port"); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("Invalid port '%s'.", port)
+		}
+	}
+	if vendor := h.Var("vendor"); vendor != "" && !contains(Vendors, strings.ToUpper(vendor)) {
+		return fmt.Errorf("Unsupported vendor '%s'.", vendor)
+	}
+	return nil
+}
+
+/*
+CSV inventory has a title line which names the columns, 'host' is required,
+'port', 'vendor', 'username', 'password', 'credential', 'key', 'enable',
+'jumphost', 'transport' and 'tags' are applied to the host, the other columns
+are template variables. The names are case insensitive, and must be unique
+variable names of letters, digits and '_'.
+For example:
+
+	host,vendor,username,password,vlan
+	10.10.10.10,H3C,admin,xxxxxx,100
+
+The first line is the title line if it has the column 'host' or starts with
+any other column applied to the host, it's an error if 'host' is missing. The
+csv file without the title line is read in the old layout: host, vendor,
+username, password and the template variables like 'vlan=100'.
+*/
+func LoadCSVInventory(filename string) ([]*InventoryHost, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var title []string
+	var hosts []*InventoryHost
+	var errs []string
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+
+		if first && isCSVTitle(record) {
+			if title, err = parseCSVTitle(record); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+			}
+			continue
+		}
+
+		h := &InventoryHost{Line: line, Vars: map[string]string{}}
+		if len(title) > 0 {
+			err = parseCSVRecord(h, title, record)
+		} else {
+			err = parseLegacyCSVRecord(h, record)
+		}
+		if err == nil {
+			h.Address = h.Vars["host"]
+			delete(h.Vars, "host")
+			err = validateHost(h)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s:%d: %v", filename, line, err))
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return hosts, nil
+}
+
+// hostColumns are the columns applied to the host.
+var hostColumns = []string{"host", "port", "vendor", "username", "password", "credential", "key", "enable", "jumphost", "transport", "tags"}
+
+var columnName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// isCSVTitle reports whether record is the title line, which has a 'host'
+// column, or starts with a column applied to the host where a line in the old
+// layout has the address.
+func isCSVTitle(record []string) bool {
+	for _, field := range record {
+		if strings.ToLower(field) == "host" {
+			return true
+		}
+	}
+	return contains(hostColumns, strings.ToLower(record[0]))
+}
+
+// parseCSVTitle returns the lowercase column names of the title line.
+func parseCSVTitle(record []string) ([]string, error) {
+	var title []string
+	for _, field := range record {
+		column := strings.ToLower(field)
+		if !columnName.MatchString(column) {
+			return nil, fmt.Errorf("Invalid column '%s', a variable name like 'vlan_id' is expected.", field)
+		}
+		if contains(title, column) {
+			return nil, fmt.Errorf("Duplicate column '%s'.", field)
+		}
+		title = append(title, column)
+	}
+	if !contains(title, "host") {
+		return nil, fmt.Errorf("Column 'host' is expected by the title line.")
+	}
+	return title, nil
+}
+
+func parseCSVRecord(h *InventoryHost, title, record []string) error {
+	if len(record) != len(title) {
+		return fmt.Errorf("%d fields are expected but got %d.", len(title), len(record))
+	}
+	for i, column := range title {
+		if record[i] != "" {
+			h.Vars[column] = record[i]
+		}
+	}
+	return nil
+}
+
+func parseLegacyCSVRecord(h *InventoryHost, record []string) error {
+	if len(record) < 4 {
+		return fmt.Errorf("host, vendor, username and password are expected but got %d fields.", len(record))
+	}
+	for i, column := range []string{"host", "vendor", "username", "password"} {
+		if record[i] != "" {
+			h.Vars[column] = record[i]
+		}
+	}
+	vars, err := parseKeyValues(record[4:])
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		h.Vars[k] = v
+	}
+	return nil
+}
+
+// parents returns the groups which have group as a child.
+func (inv *Inventory) parents(group string) []string {
+	var parents []string
+	for name, g := range inv.groups {
+		if contains(g.children, group) {
+			parents = append(parents, name)
+		}
+	}
+	sort.Strings(parents)
+	return parents
+}
+
+// applyGroup applies vars of the parent groups first, and then vars of the
+// group itself, each group is applied once.
+func (inv *Inventory) applyGroup(vars map[string]string, group string, applied map[string]bool) {
+	if applied[group] {
+		return
+	}
+	applied[group] = true
+	for _, parent := range inv.parents(group) {
+		inv.applyGroup(vars, parent, applied)
+	}
+	applyVars(vars, inv.groups[group].vars)
+}
+
+func applyVars(dst, src map[string]string) {
+	for k, v := range src {
+		if k == "tags" {
+			dst[k] = mergeTags(dst[k], v)
+		} else {
+			dst[k] = v
+		}
+	}
+}
+
+func (inv *Inventory) resolveVars(h *InventoryHost) map[string]string {
+	vars := map[string]string{}
+	applied := map[string]bool{}
+	inv.applyGroup(vars, allGroup, applied)
+	for _, group := range h.Groups {
+		inv.applyGroup(vars, group, applied)
+	}
+	applyVars(vars, inv.hostvars[h.Address])
+	return vars
+}
+
+// memberOf reports whether h belongs to group directly or by the children of
+// the group.
+func (inv *Inventory) memberOf(h *InventoryHost, group string) bool {
+	if group == allGroup {
+		return true
+	}
+	visited := map[string]bool{}
+	var walk func(string) bool
+	walk = func(name string) bool {
+		if name == group {
+			return true
+		}
+		if visited[name] {
+			return false
+		}
+		visited[name] = true
+		for _, parent := range inv.parents(name) {
+			if walk(parent) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, g := range h.Groups {
+		if walk(g) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Select returns the hosts matching the limit expression in inventory order.
+The expression is a list of terms separated by ',', all terms must be matched:
+
+	dc=bj      variable 'dc' is 'bj'
+	role!=tor  variable 'role' is not 'tor'
+	bj_tor     host belongs to group 'bj_tor' or is tagged with 'bj_tor'
+	!canary    host neither belongs to group 'canary' nor is tagged with it
+
+An empty expression matches all hosts.
+*/
+func (inv *Inventory) Select(limit string) ([]*InventoryHost, error) {
+	var terms []string
+	for _, term := range strings.Split(limit, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	var hosts []*InventoryHost
+	for _, h := range inv.Hosts {
+		matched := true
+		for _, term := range terms {
+			ok, err := inv.matchTerm(h, term)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
+func (inv *Inventory) matchTerm(h *InventoryHost, term string) (bool, error) {
+	if kv := strings.SplitN(term, "!=", 2); len(kv) == 2 {
+		return h.Var(strings.TrimSpace(kv[0])) != strings.TrimSpace(kv[1]), nil
+	}
+	if kv := strings.SplitN(term, "=", 2); len(kv) == 2 {
+		return h.Var(strings.TrimSpace(kv[0])) == strings.TrimSpace(kv[1]), nil
+	}
+
+	negative := strings.HasPrefix(term, "!")
+	name := strings.TrimSpace(strings.TrimPrefix(term, "!"))
+	if name == "" {
+		return false, fmt.Errorf("Invalid limit term '%s'.", term)
+	}
+	matched := inv.memberOf(h, name) || contains(h.Tags(), name)
+	return matched != negative, nil
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "hosts.csv")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadCSVInventory(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		address string
+		vars    map[string]string
+	}{
+		{
+			name:    "title",
+			content: "host,vendor,username,password,vlan\n10.10.10.10,H3C,admin,xxxxxx,100\n",
+			address: "10.10.10.10",
+			vars:    map[string]string{"vendor": "H3C", "username": "admin", "password": "xxxxxx", "vlan": "100"},
+		},
+		{
+			name:    "host not first",
+			content: "Vendor,HOST,vlan_id\nH3C,10.10.10.10,100\n",
+			address: "10.10.10.10",
+			vars:    map[string]string{"vendor": "H3C", "vlan_id": "100"},
+		},
+		{
+			name:    "old layout",
+			content: "10.10.10.10,H3C,admin,password,vlan=100\n",
+			address: "10.10.10.10",
+			vars:    map[string]string{"vendor": "H3C", "username": "admin", "password": "password", "vlan": "100"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hosts, err := LoadCSVInventory(writeCSV(t, c.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hosts) != 1 {
+				t.Fatalf("got %d hosts, want 1", len(hosts))
+			}
+			if hosts[0].Address != c.address {
+				t.Errorf("address = %q, want %q", hosts[0].Address, c.address)
+			}
+			if len(hosts[0].Vars) != len(c.vars) {
+				t.Errorf("vars = %v, want %v", hosts[0].Vars, c.vars)
+			}
+			for k, v := range c.vars {
+				if hosts[0].Vars[k] != v {
+					t.Errorf("var %s = %q, want %q", k, hosts[0].Vars[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCSVInventoryTitleErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"no host", "vendor,username,password\nH3C,admin,xxxxxx\n", "Column 'host'"},
+		{"duplicate", "host,vlan,VLAN\n10.10.10.10,100,200\n", "Duplicate column 'VLAN'"},
+		{"empty", "host,,vlan\n10.10.10.10,x,100\n", "Invalid column ''"},
+		{"invalid", "host,vlan-id\n10.10.10.10,100\n", "Invalid column 'vlan-id'"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := LoadCSVInventory(writeCSV(t, c.content))
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("error = %v, want %q", err, c.err)
+			}
+		})
+	}
+}
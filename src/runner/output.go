@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+/*
+Output multiplexes the outputs of the hosts running concurrently, each block
+is written at once, so the outputs of hosts and the log lines written by
+LogWriter are never mixed.
+
+If ordered, the blocks are written in the order of tickets instead of the
+order they completed, a ticket is reserved for each host before it's started
+and released by Write even if the host has nothing to print. The blocks
+written by Print are not ordered.
+*/
+type Output struct {
+	w       io.Writer
+	ordered bool
+	tickets int
+	next    int
+	pending map[int]string
+	mutex   sync.Mutex
+}
+
+func NewOutput(w io.Writer, ordered bool) *Output {
+	return &Output{w: w, ordered: ordered, pending: map[int]string{}}
+}
+
+// Block formats the output of a host with a header line.
+func Block(header, text string) string {
+	return fmt.Sprintf("--------%s-------\n%s\n", header, text)
+}
+
+// Reserve returns the next ticket.
+func (o *Output) Reserve() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.tickets++
+	return o.tickets - 1
+}
+
+// Write writes the block of ticket, the empty block only releases the ticket.
+func (o *Output) Write(ticket int, block string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if !o.ordered {
+		o.write(block)
+		return
+	}
+	o.pending[ticket] = block
+	for {
+		block, ok := o.pending[o.next]
+		if !ok {
+			break
+		}
+		delete(o.pending, o.next)
+		o.write(block)
+		o.next++
+	}
+}
+
+// Print writes block immediately.
+func (o *Output) Print(block string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.write(block)
+}
+
+func (o *Output) write(block string) {
+	if block != "" {
+		io.WriteString(o.w, block)
+	}
+}
+
+// LogWriter returns a writer to w which never writes in the middle of a
+// block, it's used by log.SetOutput.
+func (o *Output) LogWriter(w io.Writer) io.Writer {
+	return &logWriter{w: w, output: o}
+}
+
+type logWriter struct {
+	w      io.Writer
+	output *Output
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	l.output.mutex.Lock()
+	defer l.output.mutex.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pool runs tasks concurrently with limits:
+//
+//	concurrency  the max number of tasks running at the same time.
+//	rate         the max number of tasks started per second, 0 means no limit.
+//	groupcaps    the max number of tasks running at the same time for each
+//	             value of a variable, such as at most 20 per 'site'.
+type Pool struct {
+	slots     chan struct{}
+	limiter   *rateLimiter
+	groupcaps map[string]int
+	groups    map[string]chan struct{}
+	mutex     sync.Mutex
+	wait      sync.WaitGroup
+}
+
+func NewPool(concurrency int, rate float64, groupcaps map[string]int) *Pool {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	p := &Pool{
+		slots:     make(chan struct{}, concurrency),
+		groupcaps: groupcaps,
+		groups:    map[string]chan struct{}{},
+	}
+	if rate > 0 {
+		p.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+	}
+	return p
+}
+
+// ParseGroupCaps parses caps like 'site=20,dc=50'.
+func ParseGroupCaps(s string) (map[string]int, error) {
+	caps := map[string]int{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid group cap '%s', '<variable>=<number>' is expected.", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("Invalid group cap '%s', '<variable>=<number>' is expected.", item)
+		}
+		caps[strings.TrimSpace(kv[0])] = n
+	}
+	return caps, nil
+}
+
+// groupSlots returns the semaphores of the groups which vars belongs to, in
+// a fixed order, so that tasks never wait for each other in a cycle.
+func (p *Pool) groupSlots(vars map[string]string) []chan struct{} {
+	var keys []string
+	for name := range p.groupcaps {
+		if value, ok := vars[name]; ok && value != "" {
+			keys = append(keys, name+"="+value)
+		}
+	}
+	sort.Strings(keys)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var slots []chan struct{}
+	for _, key := range keys {
+		ch, ok := p.groups[key]
+		if !ok {
+			ch = make(chan struct{}, p.groupcaps[strings.SplitN(key, "=", 2)[0]])
+			p.groups[key] = ch
+		}
+		slots = append(slots, ch)
+	}
+	return slots
+}
+
+// Go runs task in a new goroutine when all limits allow, vars are the
+// variables of the host used by the group caps, it can be nil.
+func (p *Pool) Go(vars map[string]string, task func()) {
+	groups := p.groupSlots(vars)
+	p.wait.Add(1)
+	go func() {
+		defer p.wait.Done()
+		// Group slots are taken before the global slot, so a task waiting
+		// for its group doesn't hold a global slot.
+		for _, ch := range groups {
+			ch <- struct{}{}
+		}
+		p.slots <- struct{}{}
+		if p.limiter != nil {
+			p.limiter.Wait()
+		}
+
+		task()
+
+		<-p.slots
+		for _, ch := range groups {
+			<-ch
+		}
+	}()
+}
+
+func (p *Pool) Wait() {
+	p.wait.Wait()
+}
+
+// rateLimiter lets one caller pass per interval.
+type rateLimiter struct {
+	interval time.Duration
+	next     time.Time
+	mutex    sync.Mutex
+}
+
+func (l *rateLimiter) Wait() {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+
+	time.Sleep(delay)
+}
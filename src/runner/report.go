@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"encoding/csv"
+	"os"
+	"sync"
+)
+
+const (
+	StatusOK         = "ok"
+	StatusFailed     = "failed"
+	StatusSkipped    = "skipped"
+	StatusRolledBack = "rolledback"
+)
+
+// HostReport is the result of a host, Credential is the name of the
+// credential accepted by the host, it's empty if no credential is named.
+type HostReport struct {
+	Host       string
+	Vendor     string
+	Credential string
+	Status     string
+	Error      string
+}
+
+type Report struct {
+	hosts []*HostReport
+	mutex sync.Mutex
+}
+
+func (r *Report) Add(h *HostReport) {
+	r.mutex.Lock()
+	r.hosts = append(r.hosts, h)
+	r.mutex.Unlock()
+}
+
+// Count returns the number of hosts in status.
+func (r *Report) Count(status string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	n := 0
+	for _, h := range r.hosts {
+		if h.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the number of hosts failed, including the ones rolled back
+// after the failures.
+func (r *Report) Failed() int {
+	return r.Count(StatusFailed) + r.Count(StatusRolledBack)
+}
+
+// WriteCSV writes the report to filename with a title line.
+func (r *Report) WriteCSV(filename string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"host", "vendor", "credential", "status", "error"})
+	for _, h := range r.hosts {
+		writer.Write([]string{h.Host, h.Vendor, h.Credential, h.Status, h.Error})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Write adds the result to the report, so the report can be used as a sink.
+func (r *Report) Write(result *Result, err error) {
+	h := &HostReport{Host: result.Host, Vendor: result.Vendor, Credential: result.Credential, Status: StatusOK}
+	if err != nil {
+		h.Status = StatusFailed
+		h.Error = err.Error()
+	}
+	r.Add(h)
+}
@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Size is a number of hosts or a percentage of all hosts.
+type Size struct {
+	N       int
+	Percent bool
+}
+
+// ParseSize parses sizes like '10' or '10%', the empty string is zero.
+func ParseSize(s string) (Size, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Size{}, nil
+	}
+	size := Size{Percent: strings.HasSuffix(s, "%")}
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || n < 0 || (size.Percent && n > 100) {
+		return Size{}, fmt.Errorf("Invalid size '%s', a number or a percentage like '10%%' is expected.", s)
+	}
+	size.N = n
+	return size, nil
+}
+
+// Of returns the number of hosts of total, a non-zero percentage is at least
+// one host.
+func (s Size) Of(total int) int {
+	if !s.Percent {
+		return s.N
+	}
+	n := (total*s.N + 99) / 100
+	if n > total {
+		n = total
+	}
+	return n
+}
+
+/*
+Rollout runs the hosts in waves, the canary wave is run first, then the waves
+of Wave hosts, the rest hosts are run in one wave if Wave is zero. After each
+wave, the failures of all finished hosts are checked against MaxFail, a
+number of hosts or a percentage of the finished hosts. If the failures exceed
+it, the rollout is aborted, or paused for confirmation if Confirm is set.
+Once the operator continues, the failures so far are acknowledged, the
+threshold is checked against the failures and the hosts finished after that,
+so the operator is asked again only if the later waves exceed it too.
+If Confirm is set, the confirmation is also required before each wave after
+the first one.
+*/
+type Rollout struct {
+	Canary  Size
+	Wave    Size
+	MaxFail Size
+	Confirm bool
+}
+
+var ErrRolloutAborted = errors.New("Rollout aborted.")
+
+// Enabled reports whether the hosts are run in waves.
+func (r *Rollout) Enabled() bool {
+	return r.Canary.N > 0 || r.Wave.N > 0
+}
+
+// Waves returns the end index of each wave of total hosts.
+func (r *Rollout) Waves(total int) []int {
+	var ends []int
+	end := 0
+	if n := r.Canary.Of(total); n > 0 {
+		end = min(n, total)
+		ends = append(ends, end)
+	}
+	size := r.Wave.Of(total)
+	for end < total {
+		if size <= 0 {
+			end = total
+		} else {
+			end = min(end+size, total)
+		}
+		ends = append(ends, end)
+	}
+	return ends
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// exceeded reports whether failed of done hosts exceed the threshold.
+func (r *Rollout) exceeded(failed, done int) bool {
+	if r.MaxFail.Percent {
+		return failed*100 > r.MaxFail.N*done
+	}
+	return failed > r.MaxFail.N
+}
+
+// Run runs the waves of total hosts, wave runs the hosts [start, end) and
+// returns the number of the failed. It returns the number of hosts started,
+// and ErrRolloutAborted if the rollout is stopped.
+func (r *Rollout) Run(total int, wave func(start, end int) int) (int, error) {
+	waves := r.Waves(total)
+	start, failed := 0, 0
+	// The failures and the hosts acknowledged by the operator.
+	ackFailed, ackDone := 0, 0
+	for i, end := range waves {
+		if i > 0 && r.Confirm {
+			ok, err := Confirm(fmt.Sprintf("Wave %d/%d: %d hosts (%d-%d of %d). Continue? [y/N]", i+1, len(waves), end-start, start+1, end, total))
+			if err != nil || !ok {
+				return start, ErrRolloutAborted
+			}
+		}
+
+		failed += wave(start, end)
+		start = end
+		if start < total && r.exceeded(failed-ackFailed, start-ackDone) {
+			msg := fmt.Sprintf("%d of %d hosts failed, exceeded the threshold.", failed, start)
+			if ackDone > 0 {
+				msg = fmt.Sprintf("%d of %d hosts failed, %d of %d since the last confirmation, exceeded the threshold.", failed, start, failed-ackFailed, start-ackDone)
+			}
+			if !r.Confirm {
+				return start, fmt.Errorf("%s %w", msg, ErrRolloutAborted)
+			}
+			ok, err := Confirm(msg + " Continue anyway? [y/N]")
+			if err != nil || !ok {
+				return start, ErrRolloutAborted
+			}
+			ackFailed, ackDone = failed, start
+		}
+	}
+	return start, nil
+}
+
+// Confirm asks the question on terminal, it's true only if 'y' or 'yes' is
+// answered.
+func Confirm(question string) (bool, error) {
+	fmt.Fprint(os.Stderr, question+" ")
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return false, err
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRolloutRun(t *testing.T) {
+	cases := []struct {
+		name    string
+		rollout Rollout
+		total   int
+		failed  []int
+		answers string
+		started int
+		aborted bool
+	}{
+		{
+			name:    "acknowledged failures",
+			rollout: Rollout{Wave: Size{N: 2}, MaxFail: Size{N: 1}, Confirm: true},
+			total:   8,
+			failed:  []int{2, 0, 1, 0},
+			// The threshold after the first wave and the 3 waves after it.
+			answers: "y\ny\ny\ny\n",
+			started: 8,
+		},
+		{
+			name:    "threshold exceeded again",
+			rollout: Rollout{Wave: Size{N: 2}, MaxFail: Size{N: 1}, Confirm: true},
+			total:   8,
+			failed:  []int{2, 0, 2, 0},
+			answers: "y\ny\ny\nn\n",
+			started: 6,
+			aborted: true,
+		},
+		{
+			name:    "acknowledged percentage",
+			rollout: Rollout{Wave: Size{N: 2}, MaxFail: Size{N: 50, Percent: true}, Confirm: true},
+			total:   6,
+			failed:  []int{2, 1, 0},
+			answers: "y\ny\ny\n",
+			started: 6,
+		},
+		{
+			name:    "aborted without confirmation",
+			rollout: Rollout{Canary: Size{N: 1}, MaxFail: Size{N: 0}},
+			total:   5,
+			failed:  []int{1, 0},
+			started: 1,
+			aborted: true,
+		},
+		{
+			name:    "under threshold",
+			rollout: Rollout{Canary: Size{N: 1}, Wave: Size{N: 50, Percent: true}, MaxFail: Size{N: 1}},
+			total:   5,
+			failed:  []int{0, 1, 0},
+			started: 5,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			saved := stdin
+			defer func() { stdin = saved }()
+			stdin = bufio.NewReader(strings.NewReader(c.answers))
+
+			i := 0
+			started, err := c.rollout.Run(c.total, func(start, end int) int {
+				n := c.failed[i]
+				i++
+				return n
+			})
+			if started != c.started || errors.Is(err, ErrRolloutAborted) != c.aborted {
+				t.Errorf("got %d started, error %v, want %d started, aborted %v", started, err, c.started, c.aborted)
+			}
+			if rest, _ := stdin.ReadString('\n'); rest != "" {
+				t.Errorf("answer %q is not asked", rest)
+			}
+		})
+	}
+}
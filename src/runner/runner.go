@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"nwssh"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+Runner runs a task on each of the targets in the pool, the targets are logged
+in before the task is called, and the results are written to the sinks.
+A tool only defines its flags and the task, for example:
+
+	r := &runner.Runner{
+		Pool:  runner.NewPool(50, 0, nil),
+		Sinks: []runner.Sink{&runner.StdoutSink{}},
+	}
+	r.Run(context.Background(), targets, func(ctx context.Context, dev *runner.Device) (runner.Result, error) {
+		output, err := dev.Driver.ExecCommandExpectPrompt("display version", time.Second*5)
+		return runner.Result{Output: output}, err
+	})
+*/
+
+// Result is the result of a target, Index, Host, Vendor and Credential are
+// filled by the runner, Index is the position of the target in the targets.
+type Result struct {
+	Index      int
+	Host       string
+	Vendor     string
+	Credential string
+	Output     string
+}
+
+// Task is called with the logged in device of each target.
+type Task func(ctx context.Context, dev *Device) (Result, error)
+
+// Sink receives the results, err is the error of login or the task. It's
+// called concurrently.
+type Sink interface {
+	Write(result *Result, err error)
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(result *Result, err error)
+
+func (f SinkFunc) Write(result *Result, err error) {
+	f(result, err)
+}
+
+// StdoutSink prints the output of each target under a header line, the output
+// of a target is never mixed with another's. If Ordered, the outputs are
+// printed in the order of the targets instead of the order they completed.
+type StdoutSink struct {
+	Ordered bool
+	output  *Output
+	once    sync.Once
+}
+
+func (s *StdoutSink) Write(result *Result, err error) {
+	s.once.Do(func() {
+		s.output = NewOutput(os.Stdout, s.Ordered)
+	})
+	s.output.Write(result.Index, Block(result.Host, result.Output))
+}
+
+// DirSink writes the output of each target to the file named by the target
+// in Dir.
+type DirSink struct {
+	Dir string
+}
+
+func (s *DirSink) Write(result *Result, err error) {
+	filename := s.Dir + string(os.PathSeparator) + LogFileName(result.Host)
+	if e := WriteFile(filename, result.Output); e != nil {
+		log.Printf("[%s]Failed to write output file '%s'. Error: %v\n", result.Host, filename, e)
+	}
+}
+
+// OutputSink returns DirSink if dir is given, otherwise StdoutSink.
+func OutputSink(dir string) Sink {
+	if dir != "" {
+		return &DirSink{Dir: dir}
+	}
+	return &StdoutSink{}
+}
+
+// DefaultSSHOptions returns the options shared by the tools, readwaittime is
+// the timeout of reading data from a ssh channel.
+func DefaultSSHOptions(readwaittime time.Duration) nwssh.SSHOptions {
+	return nwssh.SSHOptions{
+		IgnorHostKey: true,
+		BannerCallback: func(msg string) error {
+			return nil
+		},
+		TermType:     "vt100",
+		TermHeight:   560,
+		TermWidht:    480,
+		ReadWaitTime: readwaittime,
+	}
+}
+
+type Runner struct {
+	Pool  *Pool
+	Sinks []Sink
+}
+
+// Run runs task on each of targets and waits for all of them finished, the
+// targets not started yet are skipped once ctx is done. Sinks are expected
+// to be used by one Run.
+func (r *Runner) Run(ctx context.Context, targets []*Target, task Task) {
+	for i, t := range targets {
+		i, t := i, t
+		r.Pool.Go(t.Vars, func() {
+			result := &Result{Index: i, Host: t.Host, Vendor: t.Vendor}
+			err := ctx.Err()
+			if err == nil {
+				err = r.runTarget(ctx, t, task, result)
+			}
+			if err != nil {
+				log.Printf("[%s]%v\n", t.Host, err)
+			}
+			for _, sink := range r.Sinks {
+				sink.Write(result, err)
+			}
+		})
+	}
+	r.Pool.Wait()
+}
+
+func (r *Runner) runTarget(ctx context.Context, t *Target, task Task, result *Result) error {
+	dev, err := Connect(t)
+	defer dev.Close()
+	result.Vendor = dev.Vendor
+	result.Credential = dev.Credential()
+	if err != nil {
+		return err
+	}
+
+	res, err := task(ctx, dev)
+	result.Output = res.Output
+	return err
+}
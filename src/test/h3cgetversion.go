@@ -1,16 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"nwssh"
 	"os"
-	"regexp"
-	"strings"
-	"sync"
+	"runner"
 	"time"
 )
 
@@ -33,69 +29,18 @@ func initflag() {
 	flag.Parse()
 }
 
-func run(host, port string, sshoptions nwssh.SSHOptions, args Args) (string, string, bool) {
-
-	var devssh *nwssh.SSHBase
-	var err error
-
-	username := args.username
-	password := args.password
-
-	devssh, err = nwssh.SSH(host, port, username, password, time.Duration(10)*time.Second, sshoptions)
-	defer devssh.Close()
-	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return "", "", false
-	}
-	if err = devssh.Connect(); err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return "", "", false
-	}
-
-	var device nwssh.SSHBASE
-
-	device = &nwssh.H3cSSH{devssh}
-
-	if !device.SessionPreparation() {
-		log.Printf("[%s]Failed init execute envirment. Try to exectue command directly.", host)
-	}
-
-	cmd := "display device"
-
-	output, err := device.ExecCommandExpectPrompt(cmd, time.Second*5)
-	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return "", "", false
-	}
-
-	r := regexp.MustCompile(`Master\s{2,}\d\s{2,}(\S+\s?\S*)\s{2,}(\S+\s?\S*)\s+`)
-	matched := r.FindStringSubmatch(output)
-	if matched == nil {
-		return "", "", false
+// getVersion returns the OS version and the patch of the device, separated by
+// tab.
+func getVersion(ctx context.Context, dev *runner.Device) (runner.Result, error) {
+	if !dev.Driver.SessionPreparation() {
+		log.Printf("[%s]Failed init execute envirment. Try to exectue command directly.", dev.Target.Host)
 	}
-	return matched[1], matched[2], true
-}
 
-func readlines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+	facts, err := dev.Driver.GetFacts()
 	if err != nil {
-		return nil, err
-	}
-	reader := bufio.NewReader(file)
-	var lines []string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		lines = append(lines, strings.TrimSpace(line))
+		return runner.Result{}, err
 	}
-
-	return lines, nil
-}
-
-func writefile(file, conntent string) error {
-	return ioutil.WriteFile(file, []byte(conntent), 0666)
+	return runner.Result{Output: facts.OSVersion + "\t" + facts.Patch}, nil
 }
 
 func main() {
@@ -105,44 +50,36 @@ func main() {
 		os.Exit(0)
 	}
 
+	var err error
 	if args.password == "" {
-		fmt.Printf("Please input the password:")
-		fmt.Scanf("%s", &args.password)
-	}
-
-	sshoptions := nwssh.SSHOptions{
-		IgnorHostKey: true,
-		BannerCallback: func(msg string) error {
-			return nil
-		},
-		TermType:     "vt100",
-		TermHeight:   560,
-		TermWidht:    480,
-		ReadWaitTime: time.Duration(500) * time.Millisecond, //Read data from a ssh channel timeout
+		args.password, err = runner.ReadSecret("Please input the password:")
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
 	}
 
-	var err error
-
-	hostfile := args.hostfile
-	hosts, err := readlines(hostfile)
+	hosts, err := runner.ReadHosts(args.hostfile)
 	if err != nil {
-		log.Fatal("%v", err)
+		log.Fatalf("%v", err)
 	}
 
-	maxThread := 500
-	threadchan := make(chan struct{}, maxThread)
-
-	wait := sync.WaitGroup{}
-
-	for _, host := range hosts {
-		wait.Add(1)
-		go func(host string) {
-			threadchan <- struct{}{}
-			ver, patch, _ := run(host, "22", sshoptions, args)
-			fmt.Printf("%s\t%s\t%s\n", host, ver, patch)
-			<-threadchan
-			wait.Done()
-		}(host)
+	targets := runner.Targets(hosts, runner.Target{
+		Port:     "22",
+		Vendor:   "H3C",
+		Username: args.username,
+		Password: args.password,
+		Timeout:  time.Duration(10) * time.Second,
+		Options:  runner.DefaultSSHOptions(time.Duration(500) * time.Millisecond),
+	})
+
+	r := &runner.Runner{
+		Pool: runner.NewPool(500, 0, nil),
+		Sinks: []runner.Sink{runner.SinkFunc(func(result *runner.Result, err error) {
+			if err != nil {
+				result.Output = "\t"
+			}
+			fmt.Printf("%s\t%s\n", result.Host, result.Output)
+		})},
 	}
-	wait.Wait()
+	r.Run(context.Background(), targets, getVersion)
 }
@@ -1,15 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"nwssh"
 	"os"
-	"strings"
-	"sync"
+	"runner"
 	"time"
 )
 
@@ -41,28 +38,9 @@ func initflag() {
 	flag.StringVar(&args.logdir, "logpath", "", "Log command output to /<path>/<ip_addr> instead of stdout.")
 	flag.Parse()
 }
-func run(host, port string, sshoptions nwssh.SSHOptions, args Args) (string, bool) {
-
-	var devssh *nwssh.SSHBase
-	var err error
-
-	username := args.username
-	password := args.password
-
-	devssh, err = nwssh.SSH(host, port, username, password, time.Duration(10)*time.Second, sshoptions)
-	defer devssh.Close()
-	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return "", false
-	}
-	if err = devssh.Connect(); err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return "", false
-	}
-
-	var device nwssh.SSHBASE
-
-	device = &nwssh.H3cSSH{devssh}
+func uploadFile(ctx context.Context, dev *runner.Device) (runner.Result, error) {
+	host := dev.Target.Host
+	device := dev.Driver
 
 	if !device.SessionPreparation() {
 		log.Printf("[%s]Failed init execute envirment. Try to exectue command directly.", host)
@@ -77,63 +55,35 @@ func run(host, port string, sshoptions nwssh.SSHOptions, args Args) (string, boo
 	output := ""
 	o, err := device.ExecCommandExpect(ftp_cmd, "none)):", time.Second*5)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
-	device.ExecCommandExpect(args.ftpuser, "assword:", time.Second*5)
+	o, err = device.ExecCommandExpect(args.ftpuser, "assword:", time.Second*5)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
 	o, err = device.ExecCommandExpect(args.ftppassword, "ftp>", time.Second*5)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
 	o, err = device.ExecCommandExpect("binary", "ftp>", time.Second*5)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
 	o, err = device.ExecCommandExpectPrompt("get "+args.filename, time.Second*160)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
 	o, err = device.ExecCommandExpectPrompt("quit", time.Second*3)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
-	return output, false
-}
-
-func readlines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	reader := bufio.NewReader(file)
-	var lines []string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		lines = append(lines, strings.TrimSpace(line))
-	}
-
-	return lines, nil
-}
-
-func writefile(file, conntent string) error {
-	return ioutil.WriteFile(file, []byte(conntent), 0666)
+	return runner.Result{Output: output}, nil
 }
 
 func main() {
@@ -154,55 +104,38 @@ func main() {
 		os.Exit(0)
 	}
 
+	var err error
 	if args.ftppassword == "" {
-		fmt.Println("Please input the ftp server password:")
-		fmt.Scanf("%s", &args.ftppassword)
+		args.ftppassword, err = runner.ReadSecret("Please input the ftp server password:")
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
 	}
 
 	if args.password == "" {
-		fmt.Printf("Please input the password:")
-		fmt.Scanf("%s", &args.password)
-	}
-
-	sshoptions := nwssh.SSHOptions{
-		IgnorHostKey: true,
-		BannerCallback: func(msg string) error {
-			return nil
-		},
-		TermType:     "vt100",
-		TermHeight:   560,
-		TermWidht:    480,
-		ReadWaitTime: time.Duration(500) * time.Millisecond, //Read data from a ssh channel timeout
+		args.password, err = runner.ReadSecret("Please input the password:")
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
 	}
 
-	var err error
-
-	hostfile := args.hostfile
-	hosts, err := readlines(hostfile)
+	hosts, err := runner.ReadHosts(args.hostfile)
 	if err != nil {
-		log.Fatal("%v", err)
+		log.Fatalf("%v", err)
 	}
 
-	maxThread := 50
-	threadchan := make(chan struct{}, maxThread)
+	targets := runner.Targets(hosts, runner.Target{
+		Port:     "22",
+		Vendor:   "H3C",
+		Username: args.username,
+		Password: args.password,
+		Timeout:  time.Duration(10) * time.Second,
+		Options:  runner.DefaultSSHOptions(time.Duration(500) * time.Millisecond),
+	})
 
-	wait := sync.WaitGroup{}
-
-	for _, host := range hosts {
-		wait.Add(1)
-		go func(host string) {
-			threadchan <- struct{}{}
-			output, _ := run(host, "22", sshoptions, args)
-			if args.logdir == "" {
-				fmt.Printf("--------%s-------\n%s\n", host, output)
-			} else {
-				writefile(args.logdir+host, output)
-			}
-
-			<-threadchan
-			wait.Done()
-		}(host)
+	r := &runner.Runner{
+		Pool:  runner.NewPool(50, 0, nil),
+		Sinks: []runner.Sink{runner.OutputSink(args.logdir)},
 	}
-	wait.Wait()
-
+	r.Run(context.Background(), targets, uploadFile)
 }
@@ -1,15 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"nwssh"
 	"os"
-	"strings"
-	"sync"
+	"runner"
 	"time"
 )
 
@@ -34,125 +31,72 @@ func initflag() {
 	flag.Parse()
 }
 
-func run(host, port string, sshoptions nwssh.SSHOptions, args Args) (string, bool) {
-
-	var devssh *nwssh.SSHBase
-	var err error
-
-	username := args.username
-	password := args.password
-
-	devssh, err = nwssh.SSH(host, port, username, password, time.Duration(10)*time.Second, sshoptions)
-	defer devssh.Close()
-	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return "", false
-	}
-	if err = devssh.Connect(); err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return "", false
-	}
-
-	var device nwssh.SSHBASE
-
-	device = &nwssh.H3cSSH{devssh}
+func upgradePatch(ctx context.Context, dev *runner.Device) (runner.Result, error) {
+	device := dev.Driver
 
 	if !device.SessionPreparation() {
-		log.Printf("[%s]Failed init execute envirment. Try to exectue command directly.", host)
+		log.Printf("[%s]Failed init execute envirment. Try to exectue command directly.", dev.Target.Host)
 	}
 
 	upgrade_cmd := `install activate patch flash:/` + args.patchname + ` all`
 	output := ""
 	o, err := device.ExecCommandExpect(upgrade_cmd, "[Y/N]", time.Second*5)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
-	device.ExecCommandExpect("Y", "assword:", time.Second*5)
+	o, err = device.ExecCommandExpect("Y", "assword:", time.Second*5)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
 	o, err = device.ExecCommandTiming("Y", time.Second*120)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
+	output += o
 	o, err = device.ExecCommandTiming("install commit", time.Second*120)
 	if err != nil {
-		log.Printf("[%s]%v\n", host, err)
-		return output + o, false
+		return runner.Result{Output: output + o}, err
 	}
 	output += o
-	return output, false
-}
-
-func readlines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	reader := bufio.NewReader(file)
-	var lines []string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		lines = append(lines, strings.TrimSpace(line))
-	}
-
-	return lines, nil
-}
-
-func writefile(file, conntent string) error {
-	return ioutil.WriteFile(file, []byte(conntent), 0666)
+	return runner.Result{Output: output}, nil
 }
 
 func main() {
 
 	initflag()
 
-	sshoptions := nwssh.SSHOptions{
-		IgnorHostKey: true,
-		BannerCallback: func(msg string) error {
-			return nil
-		},
-		TermType:     "vt100",
-		TermHeight:   560,
-		TermWidht:    480,
-		ReadWaitTime: time.Duration(500) * time.Millisecond, //Read data from a ssh channel timeout
+	if args.username == "" {
+		fmt.Println("Username is expected but got none. See help docs.")
+		os.Exit(0)
 	}
 
 	var err error
+	if args.password == "" {
+		args.password, err = runner.ReadSecret("Please input the password:")
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
 
-	hostfile := args.hostfile
-	hosts, err := readlines(hostfile)
+	hosts, err := runner.ReadHosts(args.hostfile)
 	if err != nil {
-		log.Fatal("%v", err)
+		log.Fatalf("%v", err)
 	}
 
-	maxThread := 500
-	threadchan := make(chan struct{}, maxThread)
-
-	wait := sync.WaitGroup{}
-
-	for _, host := range hosts {
-		wait.Add(1)
-		go func(host string) {
-			threadchan <- struct{}{}
-			output, _ := run(host, "22", sshoptions, args)
-			if args.logdir != "" {
-				writefile(args.logdir+host, output)
-			} else {
-				fmt.Printf("--------%s-------\n%s\n", host, output)
-			}
-			<-threadchan
-			wait.Done()
-		}(host)
+	targets := runner.Targets(hosts, runner.Target{
+		Port:     "22",
+		Vendor:   "H3C",
+		Username: args.username,
+		Password: args.password,
+		Timeout:  time.Duration(10) * time.Second,
+		Options:  runner.DefaultSSHOptions(time.Duration(500) * time.Millisecond),
+	})
+
+	r := &runner.Runner{
+		Pool:  runner.NewPool(500, 0, nil),
+		Sinks: []runner.Sink{runner.OutputSink(args.logdir)},
 	}
-	wait.Wait()
-
+	r.Run(context.Background(), targets, upgradePatch)
 }